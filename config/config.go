@@ -0,0 +1,30 @@
+// Package config holds the settings shared by the command handlers and the servers.
+package config
+
+import "path/filepath"
+
+// The settings are set once on startup, before the servers run.
+var (
+	// Dir is the directory the RDB file is saved to and loaded from.
+	Dir = "/tmp/redis-data"
+	// DBFilename is the name of the RDB file in Dir.
+	DBFilename = "rdbfile"
+	// AppendOnly enables the append-only file, which logs every write command to replay them on startup.
+	// The keyspace is then loaded from the append-only file rather than from the RDB file.
+	AppendOnly = false
+	// AppendFsync is how often the append-only file is flushed to disk: always, everysec or no.
+	AppendFsync = "everysec"
+)
+
+// AppendFilename is the name of the append-only file in Dir.
+const AppendFilename = "appendonly.aof"
+
+// RDBPath returns the path of the RDB file.
+func RDBPath() string {
+	return filepath.Join(Dir, DBFilename)
+}
+
+// AOFPath returns the path of the append-only file.
+func AOFPath() string {
+	return filepath.Join(Dir, AppendFilename)
+}
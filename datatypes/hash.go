@@ -0,0 +1,137 @@
+package datatypes
+
+const (
+	// hashMaxCompactEntries is the number of fields past which a Hash converts to its map encoding,
+	// like hash-max-listpack-entries in Redis.
+	hashMaxCompactEntries = 128
+	// hashMaxCompactValue is the length of a field or value past which a Hash converts to its map encoding,
+	// like hash-max-listpack-value in Redis.
+	hashMaxCompactValue = 64
+)
+
+// Hash is the value of the Redis hash type. Small hashes are stored compactly as a slice of field-value
+// pairs, which costs less memory than a map and is as fast to search for a handful of fields, and are
+// converted to a map once they hold more than hashMaxCompactEntries fields or a field or value longer
+// than hashMaxCompactValue. Like in Redis, a hash never converts back to its compact encoding.
+type Hash struct {
+	// pairs holds the fields and values interleaved in both encodings, so that an entry can be picked by
+	// its position in constant time.
+	pairs []string
+	// dict maps each field to its index in pairs once the hash is a map, and is nil while it is compact.
+	dict map[string]int
+}
+
+// NewHash creates an empty Hash in its compact encoding.
+func NewHash() *Hash {
+	return &Hash{}
+}
+
+// Compact reports whether the hash is in its compact encoding.
+func (h *Hash) Compact() bool {
+	return h.dict == nil
+}
+
+// Len returns the number of fields of the hash.
+func (h *Hash) Len() int {
+	return len(h.pairs) / 2
+}
+
+// Get returns the value of the field.
+func (h *Hash) Get(field string) (string, bool) {
+	if i := h.find(field); i >= 0 {
+		return h.pairs[i+1], true
+	}
+	return "", false
+}
+
+// Set sets the value of the field and reports whether the field is new.
+func (h *Hash) Set(field, value string) bool {
+	if h.dict == nil && (len(field) > hashMaxCompactValue || len(value) > hashMaxCompactValue) {
+		h.convert()
+	}
+	if i := h.find(field); i >= 0 {
+		h.pairs[i+1] = value
+		return false
+	}
+	h.pairs = append(h.pairs, field, value)
+	if h.dict != nil {
+		h.dict[field] = len(h.pairs) - 2
+	} else if h.Len() > hashMaxCompactEntries {
+		h.convert()
+	}
+	return true
+}
+
+// Delete removes the field and reports whether it existed.
+func (h *Hash) Delete(field string) bool {
+	i := h.find(field)
+	if i < 0 {
+		return false
+	}
+	if h.dict == nil {
+		// Compact hashes keep their insertion order
+		h.pairs = append(h.pairs[:i], h.pairs[i+2:]...)
+		return true
+	}
+
+	// The last entry takes the place of the deleted one
+	last := len(h.pairs) - 2
+	if i != last {
+		h.pairs[i], h.pairs[i+1] = h.pairs[last], h.pairs[last+1]
+		h.dict[h.pairs[i]] = i
+	}
+	h.pairs = h.pairs[:last]
+	delete(h.dict, field)
+	return true
+}
+
+// Iterate calls fn with every field and its value until fn returns false. Compact hashes are iterated in
+// insertion order, the others in no particular order.
+func (h *Hash) Iterate(fn func(field, value string) bool) {
+	for i := 0; i < len(h.pairs); i += 2 {
+		if !fn(h.pairs[i], h.pairs[i+1]) {
+			return
+		}
+	}
+}
+
+// Entry returns the field and value at position i, between 0 and Len()-1, in the order of Iterate.
+func (h *Hash) Entry(i int) (string, string) {
+	return h.pairs[2*i], h.pairs[2*i+1]
+}
+
+// Clone returns a deep copy of the hash.
+func (h *Hash) Clone() *Hash {
+	clone := &Hash{pairs: append([]string(nil), h.pairs...)}
+	if h.dict != nil {
+		clone.dict = make(map[string]int, len(h.dict))
+		for field, i := range h.dict {
+			clone.dict[field] = i
+		}
+	}
+	return clone
+}
+
+// find returns the index in pairs of the field, or -1 when the hash does not hold it.
+func (h *Hash) find(field string) int {
+	if h.dict != nil {
+		if i, ok := h.dict[field]; ok {
+			return i
+		}
+		return -1
+	}
+	for i := 0; i < len(h.pairs); i += 2 {
+		if h.pairs[i] == field {
+			return i
+		}
+	}
+	return -1
+}
+
+// convert indexes the fields of a compact hash by a map.
+func (h *Hash) convert() {
+	h.dict = make(map[string]int, len(h.pairs)/2)
+	for i := 0; i < len(h.pairs); i += 2 {
+		h.dict[h.pairs[i]] = i
+	}
+}
@@ -0,0 +1,59 @@
+package datatypes_test
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/datatypes"
+)
+
+func TestHashEncoding(t *testing.T) {
+	hash := datatypes.NewHash()
+	for i := 0; i < 128; i++ {
+		hash.Set("field:"+strconv.Itoa(i), strconv.Itoa(i))
+	}
+	if !hash.Compact() {
+		t.Fatalf("a hash of 128 fields is not compact")
+	}
+
+	hash.Set("field:128", "128")
+	if hash.Compact() {
+		t.Errorf("a hash of 129 fields is still compact")
+	}
+	if hash.Len() != 129 {
+		t.Errorf("Len() = %d, want 129", hash.Len())
+	}
+	for i := 0; i <= 128; i++ {
+		if value, ok := hash.Get("field:" + strconv.Itoa(i)); !ok || value != strconv.Itoa(i) {
+			t.Errorf("Get(field:%d) = %q, %v after the conversion", i, value, ok)
+		}
+	}
+
+	for i := 0; i <= 128; i += 2 {
+		if !hash.Delete("field:" + strconv.Itoa(i)) {
+			t.Errorf("Delete(field:%d) = false", i)
+		}
+	}
+	if hash.Len() != 64 {
+		t.Errorf("Len() = %d after the deletes, want 64", hash.Len())
+	}
+	for i := 0; i < hash.Len(); i++ {
+		field, value := hash.Entry(i)
+		if got, ok := hash.Get(field); !ok || got != value || field != "field:"+value {
+			t.Errorf("Entry(%d) = %q, %q does not match Get", i, field, value)
+		}
+	}
+
+	small := datatypes.NewHash()
+	small.Set("f", "v")
+	if small.Set("f", strings.Repeat("x", 65)) || small.Compact() {
+		t.Errorf("a hash holding a long value is still compact")
+	}
+
+	clone := small.Clone()
+	clone.Delete("f")
+	if _, ok := small.Get("f"); !ok || clone.Len() != 0 {
+		t.Errorf("the clone of a hash shares its fields")
+	}
+}
@@ -0,0 +1,358 @@
+package datatypes
+
+// listNodeSize is the maximum number of elements held by a node of a List.
+const listNodeSize = 128
+
+// List is the value of the Redis list type. Like Redis's quicklist, it is a doubly linked list of nodes that
+// each hold a chunk of up to listNodeSize elements, which keeps pushes and pops at both ends O(1) while
+// using far less memory, and far fewer pointers, than a node per element.
+type List struct {
+	head   *listNode
+	tail   *listNode
+	length int
+}
+
+// listNode is a chunk of consecutive elements of a List.
+type listNode struct {
+	prev    *listNode
+	next    *listNode
+	entries []string
+}
+
+// NewList creates an empty List.
+func NewList() *List {
+	return &List{}
+}
+
+// Len returns the number of elements of the list.
+func (l *List) Len() int {
+	return l.length
+}
+
+// PushFront inserts the value at the head of the list.
+func (l *List) PushFront(value string) {
+	if l.head == nil || len(l.head.entries) >= listNodeSize {
+		l.insertNodeAfter(nil, &listNode{})
+	}
+	l.head.entries = append(l.head.entries, "")
+	copy(l.head.entries[1:], l.head.entries)
+	l.head.entries[0] = value
+	l.length++
+}
+
+// PushBack inserts the value at the tail of the list.
+func (l *List) PushBack(value string) {
+	if l.tail == nil || len(l.tail.entries) >= listNodeSize {
+		l.insertNodeAfter(l.tail, &listNode{})
+	}
+	l.tail.entries = append(l.tail.entries, value)
+	l.length++
+}
+
+// PopFront removes and returns the value at the head of the list.
+func (l *List) PopFront() (string, bool) {
+	if l.length == 0 {
+		return "", false
+	}
+	value := l.head.entries[0]
+	l.removeAt(l.head, 0)
+	return value, true
+}
+
+// PopBack removes and returns the value at the tail of the list.
+func (l *List) PopBack() (string, bool) {
+	if l.length == 0 {
+		return "", false
+	}
+	value := l.tail.entries[len(l.tail.entries)-1]
+	l.removeAt(l.tail, len(l.tail.entries)-1)
+	return value, true
+}
+
+// Index returns the value at the given index. Negative indexes count from the tail, -1 being the last element.
+func (l *List) Index(index int) (string, bool) {
+	node, offset, ok := l.locate(index)
+	if !ok {
+		return "", false
+	}
+	return node.entries[offset], true
+}
+
+// Set replaces the value at the given index and reports whether the index is in range.
+// Negative indexes count from the tail.
+func (l *List) Set(index int, value string) bool {
+	node, offset, ok := l.locate(index)
+	if !ok {
+		return false
+	}
+	node.entries[offset] = value
+	return true
+}
+
+// Range returns the values between start and stop inclusive. Negative indexes count from the tail and
+// out of range indexes are clamped to the list, the way LRANGE interprets them.
+func (l *List) Range(start, stop int) []string {
+	start, stop, ok := l.clamp(start, stop)
+	if !ok {
+		return []string{}
+	}
+
+	values := make([]string, 0, stop-start+1)
+	node, offset, _ := l.locate(start)
+	for node != nil && len(values) < cap(values) {
+		for ; offset < len(node.entries) && len(values) < cap(values); offset++ {
+			values = append(values, node.entries[offset])
+		}
+		node, offset = node.next, 0
+	}
+	return values
+}
+
+// Trim keeps the values between start and stop inclusive and removes the others, the way LTRIM interprets
+// the indexes.
+func (l *List) Trim(start, stop int) {
+	start, stop, ok := l.clamp(start, stop)
+	if !ok {
+		l.head, l.tail, l.length = nil, nil, 0
+		return
+	}
+
+	l.removeFront(start)
+	l.removeBack(l.length - (stop - start + 1))
+}
+
+// Remove removes the elements equal to value and returns how many were removed. A positive count removes
+// at most count elements starting from the head, a negative count at most -count elements starting from
+// the tail, and a count of 0 removes them all.
+func (l *List) Remove(value string, count int) int {
+	limit := count
+	if limit < 0 {
+		limit = -limit
+	}
+
+	removed := 0
+	if count >= 0 {
+		for node := l.head; node != nil; {
+			next := node.next
+			for i := 0; i < len(node.entries) && (limit == 0 || removed < limit); {
+				if node.entries[i] == value {
+					if l.removeAt(node, i) {
+						removed++
+						break
+					}
+					removed++
+					continue
+				}
+				i++
+			}
+			node = next
+		}
+		return removed
+	}
+
+	for node := l.tail; node != nil && removed < limit; {
+		prev := node.prev
+		for i := len(node.entries) - 1; i >= 0 && removed < limit; i-- {
+			if node.entries[i] == value {
+				removed++
+				if l.removeAt(node, i) {
+					break
+				}
+			}
+		}
+		node = prev
+	}
+	return removed
+}
+
+// Insert inserts value just before or just after the first element equal to pivot.
+// It returns false when the pivot is not found.
+func (l *List) Insert(pivot, value string, before bool) bool {
+	for node := l.head; node != nil; node = node.next {
+		for i, entry := range node.entries {
+			if entry != pivot {
+				continue
+			}
+			if !before {
+				i++
+			}
+			l.insertAt(node, i, value)
+			return true
+		}
+	}
+	return false
+}
+
+// Iterate calls fn with the index and value of every element, from the head to the tail, or from the tail
+// to the head when reverse is set, until fn returns false.
+func (l *List) Iterate(reverse bool, fn func(index int, value string) bool) {
+	if !reverse {
+		index := 0
+		for node := l.head; node != nil; node = node.next {
+			for _, entry := range node.entries {
+				if !fn(index, entry) {
+					return
+				}
+				index++
+			}
+		}
+		return
+	}
+
+	index := l.length - 1
+	for node := l.tail; node != nil; node = node.prev {
+		for i := len(node.entries) - 1; i >= 0; i-- {
+			if !fn(index, node.entries[i]) {
+				return
+			}
+			index--
+		}
+	}
+}
+
+// Clone returns a deep copy of the list.
+func (l *List) Clone() *List {
+	clone := NewList()
+	for node := l.head; node != nil; node = node.next {
+		clone.insertNodeAfter(clone.tail, &listNode{entries: append([]string(nil), node.entries...)})
+		clone.length += len(node.entries)
+	}
+	return clone
+}
+
+// clamp converts start and stop into valid indexes of the list, counting negative indexes from the tail.
+// It reports false when the range is empty.
+func (l *List) clamp(start, stop int) (int, int, bool) {
+	if start < 0 {
+		start = max(l.length+start, 0)
+	}
+	if stop < 0 {
+		stop = l.length + stop
+	}
+	stop = min(stop, l.length-1)
+	if start > stop || start >= l.length {
+		return 0, 0, false
+	}
+	return start, stop, true
+}
+
+// locate returns the node holding the element at index and the offset of the element in the node.
+// It walks from whichever end of the list is closer.
+func (l *List) locate(index int) (*listNode, int, bool) {
+	if index < 0 {
+		index += l.length
+	}
+	if index < 0 || index >= l.length {
+		return nil, 0, false
+	}
+
+	if index < l.length/2 {
+		for node := l.head; node != nil; node = node.next {
+			if index < len(node.entries) {
+				return node, index, true
+			}
+			index -= len(node.entries)
+		}
+	}
+
+	fromTail := l.length - 1 - index
+	for node := l.tail; node != nil; node = node.prev {
+		if fromTail < len(node.entries) {
+			return node, len(node.entries) - 1 - fromTail, true
+		}
+		fromTail -= len(node.entries)
+	}
+	return nil, 0, false
+}
+
+// insertAt inserts value at offset in node, splitting the node in two halves when it is full.
+func (l *List) insertAt(node *listNode, offset int, value string) {
+	if len(node.entries) >= listNodeSize {
+		half := len(node.entries) / 2
+		second := &listNode{entries: append([]string(nil), node.entries[half:]...)}
+		node.entries = node.entries[:half:half]
+		l.insertNodeAfter(node, second)
+		if offset > half {
+			node, offset = second, offset-half
+		}
+	}
+
+	node.entries = append(node.entries, "")
+	copy(node.entries[offset+1:], node.entries[offset:])
+	node.entries[offset] = value
+	l.length++
+}
+
+// removeAt removes the element at offset in node, and the node itself once it is empty.
+// It reports whether the node was removed.
+func (l *List) removeAt(node *listNode, offset int) bool {
+	node.entries = append(node.entries[:offset], node.entries[offset+1:]...)
+	l.length--
+	if len(node.entries) == 0 {
+		l.unlinkNode(node)
+		return true
+	}
+	return false
+}
+
+// removeFront removes the first n elements, dropping whole nodes where possible.
+func (l *List) removeFront(n int) {
+	for n > 0 && l.head != nil {
+		if n >= len(l.head.entries) {
+			n -= len(l.head.entries)
+			l.length -= len(l.head.entries)
+			l.unlinkNode(l.head)
+			continue
+		}
+		l.head.entries = append([]string(nil), l.head.entries[n:]...)
+		l.length -= n
+		n = 0
+	}
+}
+
+// removeBack removes the last n elements, dropping whole nodes where possible.
+func (l *List) removeBack(n int) {
+	for n > 0 && l.tail != nil {
+		if n >= len(l.tail.entries) {
+			n -= len(l.tail.entries)
+			l.length -= len(l.tail.entries)
+			l.unlinkNode(l.tail)
+			continue
+		}
+		l.tail.entries = l.tail.entries[:len(l.tail.entries)-n]
+		l.length -= n
+		n = 0
+	}
+}
+
+// insertNodeAfter links node after prev, or at the head of the list when prev is nil.
+func (l *List) insertNodeAfter(prev, node *listNode) {
+	node.prev = prev
+	if prev == nil {
+		node.next = l.head
+		l.head = node
+	} else {
+		node.next = prev.next
+		prev.next = node
+	}
+	if node.next == nil {
+		l.tail = node
+	} else {
+		node.next.prev = node
+	}
+}
+
+// unlinkNode removes the node from the list.
+func (l *List) unlinkNode(node *listNode) {
+	if node.prev == nil {
+		l.head = node.next
+	} else {
+		node.prev.next = node.next
+	}
+	if node.next == nil {
+		l.tail = node.prev
+	} else {
+		node.next.prev = node.prev
+	}
+	node.prev, node.next = nil, nil
+}
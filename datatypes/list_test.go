@@ -0,0 +1,85 @@
+package datatypes_test
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/datatypes"
+)
+
+// newList returns a list of n elements, "0" to "n-1", spread over several nodes.
+func newList(n int) *datatypes.List {
+	list := datatypes.NewList()
+	for i := 0; i < n; i++ {
+		list.PushBack(strconv.Itoa(i))
+	}
+	return list
+}
+
+// expected returns the elements between from and to inclusive, as held by newList.
+func expected(from, to int) []string {
+	values := []string{}
+	for i := from; i <= to; i++ {
+		values = append(values, strconv.Itoa(i))
+	}
+	return values
+}
+
+func TestListAcrossNodes(t *testing.T) {
+	list := newList(1000)
+
+	if got := list.Len(); got != 1000 {
+		t.Fatalf("Len() = %d, want 1000", got)
+	}
+	for _, index := range []int{0, 127, 128, 500, 999, -1, -1000} {
+		want := index
+		if want < 0 {
+			want += 1000
+		}
+		if got, ok := list.Index(index); !ok || got != strconv.Itoa(want) {
+			t.Errorf("Index(%d) = %q, %v, want %q", index, got, ok, strconv.Itoa(want))
+		}
+	}
+	if got := list.Range(120, 140); !reflect.DeepEqual(got, expected(120, 140)) {
+		t.Errorf("Range(120, 140) = %v", got)
+	}
+
+	list.Trim(100, -101)
+	if got := list.Range(0, -1); !reflect.DeepEqual(got, expected(100, 899)) {
+		t.Errorf("Range(0, -1) after Trim(100, -101) = %v", got)
+	}
+
+	for i := 0; i < 300; i++ {
+		if !list.Insert("500", "x", true) {
+			t.Fatalf("Insert() did not find the pivot")
+		}
+	}
+	if got, _ := list.Index(399); got != "499" {
+		t.Errorf("Index(399) after Insert() = %q, want 499", got)
+	}
+	if got, _ := list.Index(700); got != "500" {
+		t.Errorf("Index(700) after Insert() = %q, want 500", got)
+	}
+	if removed := list.Remove("x", 0); removed != 300 {
+		t.Errorf("Remove() = %d, want 300", removed)
+	}
+	if got := list.Range(0, -1); !reflect.DeepEqual(got, expected(100, 899)) {
+		t.Errorf("Range(0, -1) after Remove() = %v", got)
+	}
+
+	clone := list.Clone()
+	clone.PushFront("y")
+	if list.Len() != 800 || clone.Len() != 801 {
+		t.Errorf("Len() of the list and its clone = %d, %d, want 800, 801", list.Len(), clone.Len())
+	}
+
+	for i := 100; i < 900; i++ {
+		if got, ok := list.PopFront(); !ok || got != strconv.Itoa(i) {
+			t.Fatalf("PopFront() = %q, %v, want %q", got, ok, strconv.Itoa(i))
+		}
+	}
+	if _, ok := list.PopBack(); ok || list.Len() != 0 {
+		t.Errorf("PopBack() of an empty list succeeded")
+	}
+}
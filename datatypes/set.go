@@ -0,0 +1,182 @@
+package datatypes
+
+import (
+	"slices"
+	"strconv"
+)
+
+// setMaxIntsetEntries is the number of members past which a Set converts to its map encoding,
+// like set-max-intset-entries in Redis.
+const setMaxIntsetEntries = 512
+
+// Set is the value of the Redis set type. Like Redis's intset, small sets whose members are all integers
+// are stored compactly as a sorted slice of integers, searched by binary search, and are converted to a map
+// once they hold a member that is not an integer or more than setMaxIntsetEntries members. Like in Redis,
+// a set never converts back to its intset encoding.
+type Set struct {
+	// ints holds the members while the set is an intset, and is unused once it is a map.
+	ints []int64
+	// members holds the members once the set is a map, so that a member can be picked by its position in
+	// constant time, and dict maps each of them to its index in members.
+	members []string
+	dict    map[string]int
+}
+
+// NewSet creates an empty Set in its intset encoding.
+func NewSet() *Set {
+	return &Set{}
+}
+
+// parseSetInteger parses the member as an integer when it is the canonical representation of one, so that
+// converting it back gives the same member.
+func parseSetInteger(member string) (int64, bool) {
+	n, err := strconv.ParseInt(member, 10, 64)
+	if err != nil || strconv.FormatInt(n, 10) != member {
+		return 0, false
+	}
+	return n, true
+}
+
+// Intset reports whether the set is in its intset encoding.
+func (s *Set) Intset() bool {
+	return s.dict == nil
+}
+
+// Len returns the number of members of the set.
+func (s *Set) Len() int {
+	if s.dict != nil {
+		return len(s.members)
+	}
+	return len(s.ints)
+}
+
+// Contains reports whether the member is in the set.
+func (s *Set) Contains(member string) bool {
+	if s.dict != nil {
+		_, ok := s.dict[member]
+		return ok
+	}
+	n, ok := parseSetInteger(member)
+	if !ok {
+		return false
+	}
+	_, found := slices.BinarySearch(s.ints, n)
+	return found
+}
+
+// Add adds the member and reports whether it is new.
+func (s *Set) Add(member string) bool {
+	if s.dict == nil {
+		n, ok := parseSetInteger(member)
+		if ok {
+			i, found := slices.BinarySearch(s.ints, n)
+			if found {
+				return false
+			}
+			if len(s.ints) < setMaxIntsetEntries {
+				s.ints = slices.Insert(s.ints, i, n)
+				return true
+			}
+		}
+		s.convert()
+	}
+
+	if _, exists := s.dict[member]; exists {
+		return false
+	}
+	s.dict[member] = len(s.members)
+	s.members = append(s.members, member)
+	return true
+}
+
+// Remove removes the member and reports whether it existed.
+func (s *Set) Remove(member string) bool {
+	if s.dict != nil {
+		i, exists := s.dict[member]
+		if !exists {
+			return false
+		}
+		// The last member takes the place of the removed one
+		last := len(s.members) - 1
+		if i != last {
+			s.members[i] = s.members[last]
+			s.dict[s.members[i]] = i
+		}
+		s.members[last] = ""
+		s.members = s.members[:last]
+		delete(s.dict, member)
+		return true
+	}
+
+	n, ok := parseSetInteger(member)
+	if !ok {
+		return false
+	}
+	i, found := slices.BinarySearch(s.ints, n)
+	if found {
+		s.ints = slices.Delete(s.ints, i, i+1)
+	}
+	return found
+}
+
+// Iterate calls fn with every member until fn returns false. Intsets are iterated in ascending order, the
+// others in no particular order.
+func (s *Set) Iterate(fn func(member string) bool) {
+	if s.dict != nil {
+		for _, member := range s.members {
+			if !fn(member) {
+				return
+			}
+		}
+		return
+	}
+	for _, n := range s.ints {
+		if !fn(strconv.FormatInt(n, 10)) {
+			return
+		}
+	}
+}
+
+// Members returns all the members of the set, in the order of Iterate.
+func (s *Set) Members() []string {
+	members := make([]string, 0, s.Len())
+	s.Iterate(func(member string) bool {
+		members = append(members, member)
+		return true
+	})
+	return members
+}
+
+// Member returns the member at position i, between 0 and Len()-1, in the order of Iterate.
+func (s *Set) Member(i int) string {
+	if s.dict != nil {
+		return s.members[i]
+	}
+	return strconv.FormatInt(s.ints[i], 10)
+}
+
+// Clone returns a deep copy of the set.
+func (s *Set) Clone() *Set {
+	clone := &Set{}
+	if s.dict != nil {
+		clone.members = slices.Clone(s.members)
+		clone.dict = make(map[string]int, len(s.dict))
+		for member, i := range s.dict {
+			clone.dict[member] = i
+		}
+		return clone
+	}
+	clone.ints = slices.Clone(s.ints)
+	return clone
+}
+
+// convert moves the members of an intset to a map.
+func (s *Set) convert() {
+	s.dict = make(map[string]int, len(s.ints))
+	s.members = make([]string, len(s.ints))
+	for i, n := range s.ints {
+		s.members[i] = strconv.FormatInt(n, 10)
+		s.dict[s.members[i]] = i
+	}
+	s.ints = nil
+}
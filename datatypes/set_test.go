@@ -0,0 +1,70 @@
+package datatypes_test
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/datatypes"
+)
+
+func TestSetEncoding(t *testing.T) {
+	set := datatypes.NewSet()
+	for _, member := range []string{"30", "-5", "10", "10"} {
+		set.Add(member)
+	}
+	if !set.Intset() {
+		t.Fatalf("a set of integers is not an intset")
+	}
+	if got := set.Members(); !reflect.DeepEqual(got, []string{"-5", "10", "30"}) {
+		t.Errorf("Members() = %v, want the integers in ascending order", got)
+	}
+
+	// Integers not in their canonical form are kept as strings
+	for _, member := range []string{"010", "+1", " 1", "9223372036854775808"} {
+		if set.Contains(member) {
+			t.Errorf("Contains(%q) of an intset", member)
+		}
+	}
+	if !set.Add("010") || set.Intset() {
+		t.Errorf("a set holding 010 is still an intset")
+	}
+	if !set.Contains("10") || !set.Contains("010") || set.Len() != 4 {
+		t.Errorf("the members of the set changed in the conversion")
+	}
+
+	large := datatypes.NewSet()
+	for i := 0; i < 512; i++ {
+		large.Add(strconv.Itoa(i))
+	}
+	if !large.Intset() {
+		t.Fatalf("a set of 512 integers is not an intset")
+	}
+	large.Add("512")
+	if large.Intset() || large.Len() != 513 {
+		t.Errorf("a set of 513 integers is still an intset")
+	}
+
+	for i := 0; i < 513; i += 2 {
+		if !large.Remove(strconv.Itoa(i)) {
+			t.Errorf("Remove(%d) = false", i)
+		}
+	}
+	if large.Len() != 256 {
+		t.Errorf("Len() = %d after the removals, want 256", large.Len())
+	}
+	for i := 0; i < large.Len(); i++ {
+		if member := large.Member(i); !large.Contains(member) || member[len(member)-1]%2 == 0 {
+			t.Errorf("Member(%d) = %q is not a remaining member", i, member)
+		}
+	}
+
+	clone := large.Clone()
+	clone.Remove("1")
+	members := clone.Members()
+	sort.Strings(members)
+	if !large.Contains("1") || clone.Contains("1") || len(members) != 255 {
+		t.Errorf("the clone of a set shares its members")
+	}
+}
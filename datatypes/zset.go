@@ -0,0 +1,419 @@
+package datatypes
+
+import "math/rand/v2"
+
+const (
+	// skiplistMaxLevel is the maximum number of levels of a skiplist node, enough for 2^64 elements.
+	skiplistMaxLevel = 32
+	// skiplistP is the probability for a node to have one more level.
+	skiplistP = 0.25
+)
+
+// ZMember is a member of a SortedSet along with its score.
+type ZMember struct {
+	Member string
+	Score  float64
+}
+
+// ScoreBound is the minimum or maximum of a range of scores, as given to ZRANGE BYSCORE or ZCOUNT.
+type ScoreBound struct {
+	Value     float64
+	Exclusive bool
+}
+
+// LexBound is the minimum or maximum of a range of members, as given to ZRANGE BYLEX.
+// Inf is -1 for the "-" bound and 1 for the "+" bound, which are lower and greater than any member.
+type LexBound struct {
+	Value     string
+	Exclusive bool
+	Inf       int
+}
+
+// SortedSet is the value of the Redis sorted set type. Like in Redis, members are kept both in a map,
+// which finds the score of a member in O(1), and in a skiplist ordered by score then member, which finds
+// members by rank or score in O(log N).
+type SortedSet struct {
+	dict map[string]float64
+	list *skiplist
+}
+
+// NewSortedSet creates an empty SortedSet.
+func NewSortedSet() *SortedSet {
+	return &SortedSet{dict: make(map[string]float64), list: newSkiplist()}
+}
+
+// Len returns the number of members of the sorted set.
+func (z *SortedSet) Len() int {
+	return len(z.dict)
+}
+
+// Score returns the score of the member.
+func (z *SortedSet) Score(member string) (float64, bool) {
+	score, ok := z.dict[member]
+	return score, ok
+}
+
+// Add sets the score of the member and reports whether the member is new.
+func (z *SortedSet) Add(member string, score float64) bool {
+	old, exists := z.dict[member]
+	if exists {
+		if old == score {
+			return false
+		}
+		z.list.delete(old, member)
+	}
+	z.dict[member] = score
+	z.list.insert(score, member)
+	return !exists
+}
+
+// Remove removes the member and reports whether it existed.
+func (z *SortedSet) Remove(member string) bool {
+	score, exists := z.dict[member]
+	if !exists {
+		return false
+	}
+	delete(z.dict, member)
+	z.list.delete(score, member)
+	return true
+}
+
+// Rank returns the 0-based rank of the member, ordered by ascending scores or by descending scores when
+// reverse is set.
+func (z *SortedSet) Rank(member string, reverse bool) (int, bool) {
+	score, exists := z.dict[member]
+	if !exists {
+		return 0, false
+	}
+	rank := z.list.rank(score, member) - 1
+	if reverse {
+		rank = z.Len() - 1 - rank
+	}
+	return rank, true
+}
+
+// RangeByRank returns the members between the ranks start and stop inclusive, ordered by ascending scores
+// or by descending scores when reverse is set. Negative ranks count from the end and out of range ranks
+// are clamped, the way ZRANGE interprets them.
+func (z *SortedSet) RangeByRank(start, stop int, reverse bool) []ZMember {
+	length := z.Len()
+	if start < 0 {
+		start = max(length+start, 0)
+	}
+	if stop < 0 {
+		stop = length + stop
+	}
+	stop = min(stop, length-1)
+	if start > stop || start >= length {
+		return []ZMember{}
+	}
+
+	members := make([]ZMember, 0, stop-start+1)
+	var node *skiplistNode
+	if reverse {
+		node = z.list.byRank(length - start)
+	} else {
+		node = z.list.byRank(start + 1)
+	}
+	for ; node != nil && len(members) < cap(members); node = node.next(reverse) {
+		members = append(members, ZMember{Member: node.member, Score: node.score})
+	}
+	return members
+}
+
+// RangeByScore returns the members whose score is within min and max, ordered by ascending scores or by
+// descending scores when reverse is set. The first offset members in range are skipped, and at most
+// count members are returned unless count is negative.
+func (z *SortedSet) RangeByScore(min, max ScoreBound, reverse bool, offset, count int) []ZMember {
+	var node *skiplistNode
+	if reverse {
+		node = z.list.last(func(n *skiplistNode) bool { return n.lteScore(max) })
+	} else {
+		node = z.list.first(func(n *skiplistNode) bool { return n.gteScore(min) })
+	}
+	inRange := func(n *skiplistNode) bool { return n.gteScore(min) && n.lteScore(max) }
+	return collect(node, inRange, reverse, offset, count)
+}
+
+// RangeByLex returns the members within min and max, ordered by member or by descending member when
+// reverse is set. It is only meaningful when all the members have the same score. offset and count work
+// like for RangeByScore.
+func (z *SortedSet) RangeByLex(min, max LexBound, reverse bool, offset, count int) []ZMember {
+	var node *skiplistNode
+	if reverse {
+		node = z.list.last(func(n *skiplistNode) bool { return n.lteLex(max) })
+	} else {
+		node = z.list.first(func(n *skiplistNode) bool { return n.gteLex(min) })
+	}
+	inRange := func(n *skiplistNode) bool { return n.gteLex(min) && n.lteLex(max) }
+	return collect(node, inRange, reverse, offset, count)
+}
+
+// CountByScore returns the number of members whose score is within min and max.
+func (z *SortedSet) CountByScore(min, max ScoreBound) int {
+	first := z.list.first(func(n *skiplistNode) bool { return n.gteScore(min) })
+	if first == nil || !first.lteScore(max) {
+		return 0
+	}
+	last := z.list.last(func(n *skiplistNode) bool { return n.lteScore(max) })
+	return z.list.rank(last.score, last.member) - z.list.rank(first.score, first.member) + 1
+}
+
+// Iterate calls fn with every member and its score, by ascending scores, until fn returns false.
+func (z *SortedSet) Iterate(fn func(member string, score float64) bool) {
+	for node := z.list.header.levels[0].forward; node != nil; node = node.levels[0].forward {
+		if !fn(node.member, node.score) {
+			return
+		}
+	}
+}
+
+// Clone returns a deep copy of the sorted set.
+func (z *SortedSet) Clone() *SortedSet {
+	clone := NewSortedSet()
+	z.Iterate(func(member string, score float64) bool {
+		clone.Add(member, score)
+		return true
+	})
+	return clone
+}
+
+// collect walks from node while the nodes are in range, skipping offset nodes and returning at most count
+// members unless count is negative.
+func collect(node *skiplistNode, inRange func(*skiplistNode) bool, reverse bool, offset, count int) []ZMember {
+	members := []ZMember{}
+	for ; node != nil && offset > 0 && inRange(node); offset-- {
+		node = node.next(reverse)
+	}
+	for ; node != nil && count != 0 && inRange(node); node = node.next(reverse) {
+		members = append(members, ZMember{Member: node.member, Score: node.score})
+		count--
+	}
+	return members
+}
+
+// skiplist is a skiplist of members ordered by score then member. Each level of a node records its span,
+// the number of nodes it skips, which lets the skiplist find nodes by rank.
+type skiplist struct {
+	header *skiplistNode
+	tail   *skiplistNode
+	length int
+	level  int
+}
+
+type skiplistNode struct {
+	member   string
+	score    float64
+	backward *skiplistNode
+	levels   []skiplistLevel
+}
+
+type skiplistLevel struct {
+	forward *skiplistNode
+	span    int
+}
+
+func newSkiplist() *skiplist {
+	return &skiplist{header: &skiplistNode{levels: make([]skiplistLevel, skiplistMaxLevel)}, level: 1}
+}
+
+// randomLevel returns the number of levels of a new node, each level being skiplistP times less likely
+// than the previous one.
+func randomLevel() int {
+	level := 1
+	for level < skiplistMaxLevel && rand.Float64() < skiplistP {
+		level++
+	}
+	return level
+}
+
+// before reports whether the node is ordered before the given score and member.
+func (n *skiplistNode) before(score float64, member string) bool {
+	return n.score < score || (n.score == score && n.member < member)
+}
+
+// next returns the following node, or the previous one when reverse is set.
+func (n *skiplistNode) next(reverse bool) *skiplistNode {
+	if reverse {
+		return n.backward
+	}
+	return n.levels[0].forward
+}
+
+func (n *skiplistNode) gteScore(min ScoreBound) bool {
+	if min.Exclusive {
+		return n.score > min.Value
+	}
+	return n.score >= min.Value
+}
+
+func (n *skiplistNode) lteScore(max ScoreBound) bool {
+	if max.Exclusive {
+		return n.score < max.Value
+	}
+	return n.score <= max.Value
+}
+
+func (n *skiplistNode) gteLex(min LexBound) bool {
+	switch {
+	case min.Inf != 0:
+		return min.Inf < 0
+	case min.Exclusive:
+		return n.member > min.Value
+	default:
+		return n.member >= min.Value
+	}
+}
+
+func (n *skiplistNode) lteLex(max LexBound) bool {
+	switch {
+	case max.Inf != 0:
+		return max.Inf > 0
+	case max.Exclusive:
+		return n.member < max.Value
+	default:
+		return n.member <= max.Value
+	}
+}
+
+// insert inserts a member that is not in the skiplist.
+func (l *skiplist) insert(score float64, member string) {
+	var update [skiplistMaxLevel]*skiplistNode
+	var rank [skiplistMaxLevel]int
+
+	// Find the last node before the new one on every level, and its rank
+	x := l.header
+	for i := l.level - 1; i >= 0; i-- {
+		if i < l.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.levels[i].forward != nil && x.levels[i].forward.before(score, member) {
+			rank[i] += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		update[i] = x
+	}
+
+	level := randomLevel()
+	if level > l.level {
+		for i := l.level; i < level; i++ {
+			update[i] = l.header
+			update[i].levels[i].span = l.length
+		}
+		l.level = level
+	}
+
+	x = &skiplistNode{member: member, score: score, levels: make([]skiplistLevel, level)}
+	for i := 0; i < level; i++ {
+		x.levels[i].forward = update[i].levels[i].forward
+		update[i].levels[i].forward = x
+		x.levels[i].span = update[i].levels[i].span - (rank[0] - rank[i])
+		update[i].levels[i].span = rank[0] - rank[i] + 1
+	}
+	// The levels above the new node now skip one more node
+	for i := level; i < l.level; i++ {
+		update[i].levels[i].span++
+	}
+
+	if update[0] != l.header {
+		x.backward = update[0]
+	}
+	if x.levels[0].forward != nil {
+		x.levels[0].forward.backward = x
+	} else {
+		l.tail = x
+	}
+	l.length++
+}
+
+// delete removes the member with the given score from the skiplist.
+func (l *skiplist) delete(score float64, member string) {
+	var update [skiplistMaxLevel]*skiplistNode
+	x := l.header
+	for i := l.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && x.levels[i].forward.before(score, member) {
+			x = x.levels[i].forward
+		}
+		update[i] = x
+	}
+
+	x = x.levels[0].forward
+	if x == nil || x.score != score || x.member != member {
+		return
+	}
+
+	for i := 0; i < l.level; i++ {
+		if update[i].levels[i].forward == x {
+			update[i].levels[i].span += x.levels[i].span - 1
+			update[i].levels[i].forward = x.levels[i].forward
+		} else {
+			update[i].levels[i].span--
+		}
+	}
+	if x.levels[0].forward != nil {
+		x.levels[0].forward.backward = x.backward
+	} else {
+		l.tail = x.backward
+	}
+	for l.level > 1 && l.header.levels[l.level-1].forward == nil {
+		l.level--
+	}
+	l.length--
+}
+
+// rank returns the 1-based rank of the member with the given score, or 0 when it is not in the skiplist.
+func (l *skiplist) rank(score float64, member string) int {
+	rank := 0
+	x := l.header
+	for i := l.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && !(score < x.levels[i].forward.score || (score == x.levels[i].forward.score && member < x.levels[i].forward.member)) {
+			rank += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		if x != l.header && x.member == member {
+			return rank
+		}
+	}
+	return 0
+}
+
+// byRank returns the node with the given 1-based rank.
+func (l *skiplist) byRank(rank int) *skiplistNode {
+	traversed := 0
+	x := l.header
+	for i := l.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && traversed+x.levels[i].span <= rank {
+			traversed += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		if traversed == rank {
+			return x
+		}
+	}
+	return nil
+}
+
+// first returns the first node for which gteMin holds. gteMin must hold for every node after it.
+func (l *skiplist) first(gteMin func(*skiplistNode) bool) *skiplistNode {
+	x := l.header
+	for i := l.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && !gteMin(x.levels[i].forward) {
+			x = x.levels[i].forward
+		}
+	}
+	return x.levels[0].forward
+}
+
+// last returns the last node for which lteMax holds. lteMax must hold for every node before it.
+func (l *skiplist) last(lteMax func(*skiplistNode) bool) *skiplistNode {
+	x := l.header
+	for i := l.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && lteMax(x.levels[i].forward) {
+			x = x.levels[i].forward
+		}
+	}
+	if x == l.header {
+		return nil
+	}
+	return x
+}
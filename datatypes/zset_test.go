@@ -0,0 +1,118 @@
+package datatypes_test
+
+import (
+	"math/rand/v2"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/datatypes"
+)
+
+// sortedMembers returns the members of the model ordered by score then member, as a SortedSet orders them.
+func sortedMembers(model map[string]float64) []datatypes.ZMember {
+	members := make([]datatypes.ZMember, 0, len(model))
+	for member, score := range model {
+		members = append(members, datatypes.ZMember{Member: member, Score: score})
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].Score != members[j].Score {
+			return members[i].Score < members[j].Score
+		}
+		return members[i].Member < members[j].Member
+	})
+	return members
+}
+
+func TestSortedSetAgainstModel(t *testing.T) {
+	zset := datatypes.NewSortedSet()
+	model := make(map[string]float64)
+
+	// Random adds, score updates and removals, with many equal scores to exercise the order by member
+	for i := 0; i < 5000; i++ {
+		member := "m" + strconv.Itoa(rand.IntN(500))
+		if rand.IntN(4) == 0 {
+			_, exists := model[member]
+			if zset.Remove(member) != exists {
+				t.Fatalf("Remove(%s) disagrees with the model", member)
+			}
+			delete(model, member)
+			continue
+		}
+		score := float64(rand.IntN(50))
+		_, exists := model[member]
+		if zset.Add(member, score) == exists {
+			t.Fatalf("Add(%s) disagrees with the model", member)
+		}
+		model[member] = score
+	}
+
+	want := sortedMembers(model)
+	if got := zset.RangeByRank(0, -1, false); !reflect.DeepEqual(got, want) {
+		t.Fatalf("RangeByRank(0, -1) disagrees with the model")
+	}
+	for i, m := range want {
+		if rank, ok := zset.Rank(m.Member, false); !ok || rank != i {
+			t.Fatalf("Rank(%s) = %d, %v, want %d", m.Member, rank, ok, i)
+		}
+		if rank, ok := zset.Rank(m.Member, true); !ok || rank != len(want)-1-i {
+			t.Fatalf("Rank(%s, reverse) = %d, %v, want %d", m.Member, rank, ok, len(want)-1-i)
+		}
+	}
+
+	reversed := zset.RangeByRank(5, 9, true)
+	for i, m := range reversed {
+		if m != want[len(want)-6-i] {
+			t.Errorf("RangeByRank(5, 9, reverse)[%d] = %v, want %v", i, m, want[len(want)-6-i])
+		}
+	}
+
+	min, max := datatypes.ScoreBound{Value: 10}, datatypes.ScoreBound{Value: 20, Exclusive: true}
+	var inRange []datatypes.ZMember
+	for _, m := range want {
+		if m.Score >= 10 && m.Score < 20 {
+			inRange = append(inRange, m)
+		}
+	}
+	if got := zset.CountByScore(min, max); got != len(inRange) {
+		t.Errorf("CountByScore([10, 20)) = %d, want %d", got, len(inRange))
+	}
+	if got := zset.RangeByScore(min, max, false, 2, 3); !reflect.DeepEqual(got, inRange[2:5]) {
+		t.Errorf("RangeByScore([10, 20), LIMIT 2 3) = %v, want %v", got, inRange[2:5])
+	}
+	if got := zset.RangeByScore(min, max, true, 0, 1); !reflect.DeepEqual(got, inRange[len(inRange)-1:]) {
+		t.Errorf("RangeByScore([10, 20), REV, LIMIT 0 1) = %v, want %v", got, inRange[len(inRange)-1:])
+	}
+}
+
+func TestSortedSetRangeByLex(t *testing.T) {
+	zset := datatypes.NewSortedSet()
+	for _, member := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		zset.Add(member, 0)
+	}
+
+	members := func(zm []datatypes.ZMember) []string {
+		values := []string{}
+		for _, m := range zm {
+			values = append(values, m.Member)
+		}
+		return values
+	}
+	tests := []struct {
+		min, max datatypes.LexBound
+		reverse  bool
+		want     []string
+	}{
+		{datatypes.LexBound{Inf: -1}, datatypes.LexBound{Value: "c"}, false, []string{"a", "b", "c"}},
+		{datatypes.LexBound{Inf: -1}, datatypes.LexBound{Value: "c", Exclusive: true}, false, []string{"a", "b"}},
+		{datatypes.LexBound{Value: "aaa"}, datatypes.LexBound{Value: "g", Exclusive: true}, false, []string{"b", "c", "d", "e", "f"}},
+		{datatypes.LexBound{Value: "e"}, datatypes.LexBound{Inf: 1}, true, []string{"g", "f", "e"}},
+		{datatypes.LexBound{Inf: 1}, datatypes.LexBound{Inf: -1}, false, []string{}},
+	}
+	for _, tt := range tests {
+		if got := members(zset.RangeByLex(tt.min, tt.max, tt.reverse, 0, -1)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("RangeByLex(%v, %v, %v) = %v, want %v", tt.min, tt.max, tt.reverse, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/keyspace"
+)
+
+// flagNames maps the command flags to the names reported by COMMAND.
+var flagNames = []struct {
+	flag commandFlag
+	name string
+}{
+	{flagWrite, "write"},
+	{flagReadonly, "readonly"},
+	{flagAdmin, "admin"},
+	{flagFast, "fast"},
+	{flagMovableKeys, "movablekeys"},
+}
+
+func commandCommand(args []string, cache keyspace.Keyspace) []byte {
+	// COMMAND
+	if len(args) == 1 {
+		return commandInfoReply(sortedCommands())
+	}
+
+	switch strings.ToUpper(args[1]) {
+	case "COUNT":
+		// COMMAND COUNT
+		if len(args) != 2 {
+			return wrongNumberOfArguments("COMMAND|COUNT")
+		}
+		return integer(int64(len(commands)))
+	case "INFO":
+		// COMMAND INFO [command-name ...]
+		if len(args) == 2 {
+			return commandInfoReply(sortedCommands())
+		}
+		cmds := make([]*command, len(args)-2)
+		for i, name := range args[2:] {
+			// Unknown commands are reported as nil entries
+			cmds[i] = commands[strings.ToUpper(name)]
+		}
+		return commandInfoReply(cmds)
+	case "GETKEYS":
+		// COMMAND GETKEYS <command> [arg ...]
+		if len(args) < 3 {
+			return wrongNumberOfArguments("COMMAND|GETKEYS")
+		}
+		cmd, ok := commands[strings.ToUpper(args[2])]
+		if !ok {
+			return errorReply("ERR Invalid command specified")
+		}
+		cmdArgs := args[2:]
+		if !cmd.checkArity(len(cmdArgs)) {
+			return errorReply("ERR Invalid number of arguments specified for command")
+		}
+		keys := cmd.keys(cmdArgs)
+		if len(keys) == 0 {
+			if cmd.flags&flagMovableKeys != 0 {
+				return errorReply("ERR Invalid arguments specified for command")
+			}
+			return errorReply("ERR The command has no key arguments")
+		}
+		return bulkStringArray(keys)
+	case "DOCS":
+		// COMMAND DOCS [command-name ...]
+		if len(args) == 2 {
+			return commandDocsReply(sortedCommands())
+		}
+		var cmds []*command
+		for _, name := range args[2:] {
+			// Unknown commands are left out of the reply
+			if cmd, ok := commands[strings.ToUpper(name)]; ok {
+				cmds = append(cmds, cmd)
+			}
+		}
+		return commandDocsReply(cmds)
+	}
+
+	return errorReply("ERR unknown subcommand '" + args[1] + "'. Try COMMAND HELP.")
+}
+
+// sortedCommands returns every command of the command table ordered by name.
+func sortedCommands() []*command {
+	cmds := make([]*command, 0, len(commands))
+	for _, cmd := range commands {
+		cmds = append(cmds, cmd)
+	}
+	sort.Slice(cmds, func(i, j int) bool {
+		return cmds[i].name < cmds[j].name
+	})
+	return cmds
+}
+
+// commandInfoReply encodes the COMMAND INFO entries of the commands. A nil command is encoded as a null entry.
+func commandInfoReply(cmds []*command) []byte {
+	reply := arrayHeader(len(cmds))
+	for _, cmd := range cmds {
+		if cmd == nil {
+			reply = append(reply, "*-1\r\n"...)
+			continue
+		}
+		reply = append(reply, cmd.info()...)
+	}
+	return reply
+}
+
+// info encodes the command the way COMMAND INFO describes it: name, arity, flags, first key, last key, step,
+// ACL categories, tips, key specifications and subcommands.
+func (cmd *command) info() []byte {
+	return array(
+		bulkString(strings.ToLower(cmd.name)),
+		integer(int64(cmd.arity)),
+		simpleStringArray(cmd.flagNames()),
+		integer(int64(cmd.firstKey)),
+		integer(int64(cmd.lastKey)),
+		integer(int64(cmd.step)),
+		simpleStringArray(cmd.aclCategories()),
+		arrayHeader(0),
+		arrayHeader(0),
+		arrayHeader(0),
+	)
+}
+
+// keys returns the keys among the arguments of the command, starting with its name.
+func (cmd *command) keys(args []string) []string {
+	if cmd.getKeys != nil {
+		return cmd.getKeys(args)
+	}
+	if cmd.firstKey == 0 {
+		return nil
+	}
+	last := cmd.lastKey
+	if last < 0 {
+		last += len(args)
+	}
+	var keys []string
+	for i := cmd.firstKey; i <= last && i < len(args); i += cmd.step {
+		keys = append(keys, args[i])
+	}
+	return keys
+}
+
+// flagNames returns the names of the flags set on the command.
+func (cmd *command) flagNames() []string {
+	var names []string
+	for _, f := range flagNames {
+		if cmd.flags&f.flag != 0 {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
+
+// aclCategories returns the ACL categories of the command, derived from its flags and group.
+func (cmd *command) aclCategories() []string {
+	var categories []string
+	if cmd.flags&flagWrite != 0 {
+		categories = append(categories, "@write")
+	}
+	if cmd.flags&flagReadonly != 0 {
+		categories = append(categories, "@read")
+	}
+	if cmd.group != "" {
+		categories = append(categories, "@"+cmd.group)
+	}
+	if cmd.flags&flagAdmin != 0 {
+		categories = append(categories, "@admin", "@dangerous")
+	}
+	if cmd.flags&flagFast != 0 {
+		categories = append(categories, "@fast")
+	} else {
+		categories = append(categories, "@slow")
+	}
+	return categories
+}
+
+// commandDocsReply encodes the COMMAND DOCS reply of the commands: a flat array alternating
+// each command name with a map of its documentation fields.
+func commandDocsReply(cmds []*command) []byte {
+	reply := arrayHeader(2 * len(cmds))
+	for _, cmd := range cmds {
+		reply = append(reply, bulkString(strings.ToLower(cmd.name))...)
+		reply = append(reply, bulkStringArray([]string{
+			"summary", cmd.summary,
+			"since", cmd.since,
+			"group", cmd.group,
+			"complexity", cmd.complexity,
+		})...)
+	}
+	return reply
+}
+
+// simpleStringArray encodes a RESP array of simple strings, which is how COMMAND reports flags and categories.
+func simpleStringArray(values []string) []byte {
+	reply := arrayHeader(len(values))
+	for _, value := range values {
+		reply = append(reply, simpleString(value)...)
+	}
+	return reply
+}
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/keyspace"
+)
+
+// commandFlag describes a property of a command, as reported to clients by COMMAND.
+type commandFlag uint
+
+const (
+	// flagWrite marks commands that may modify the keyspace
+	flagWrite commandFlag = 1 << iota
+	// flagReadonly marks commands that only read the keyspace
+	flagReadonly
+	// flagFast marks commands that run in constant or logarithmic time
+	flagFast
+	// flagAdmin marks administrative commands
+	flagAdmin
+	// flagMovableKeys marks commands whose keys are found by getKeys, since their positions depend on the arguments
+	flagMovableKeys
+)
+
+// command describes a command HandleCommands knows how to dispatch.
+type command struct {
+	// name is the upper case name of the command. Lookups are case-insensitive.
+	name string
+	// arity is the number of arguments including the command name itself.
+	// A negative arity means the command takes at least -arity arguments.
+	arity int
+	flags commandFlag
+	// firstKey, lastKey and step give the positions of the key arguments.
+	// A negative lastKey counts from the end of the arguments. Commands without keys leave all three at 0.
+	firstKey int
+	lastKey  int
+	step     int
+	// getKeys returns the keys of a command flagged flagMovableKeys, or nil when the arguments are invalid.
+	// firstKey, lastKey and step then only describe the keys at fixed positions, if any.
+	getKeys func(args []string) []string
+	// group, summary, since and complexity document the command for COMMAND DOCS.
+	group      string
+	summary    string
+	since      string
+	complexity string
+	// handler executes the command. args holds the command name followed by its arguments,
+	// and has already been checked against the arity.
+	handler func(args []string, cache keyspace.Keyspace) []byte
+}
+
+// commands is the command table, keyed by the upper case name of the commands.
+var commands = map[string]*command{}
+
+// numKeysArgs returns the keys that follow the numkeys argument at index pos, as in SINTERCARD <numkeys> <key> [key ...],
+// or nil when numkeys is invalid.
+func numKeysArgs(args []string, pos int) []string {
+	if pos >= len(args) {
+		return nil
+	}
+	n, ok := parseInteger(args[pos])
+	if !ok || n < 1 || n > int64(len(args)-pos-1) {
+		return nil
+	}
+	return args[pos+1 : pos+1+int(n)]
+}
+
+// register adds the commands to the command table.
+func register(cmds ...*command) {
+	for _, cmd := range cmds {
+		commands[cmd.name] = cmd
+	}
+}
+
+func init() {
+	register(
+		&command{
+			name: "PING", arity: -1, flags: flagFast,
+			group: "connection", summary: "Returns the server's liveliness response.", since: "1.0.0", complexity: "O(1)",
+			handler: pingCommand,
+		},
+		&command{
+			name: "ECHO", arity: 2, flags: flagFast,
+			group: "connection", summary: "Returns the given string.", since: "1.0.0", complexity: "O(1)",
+			handler: echoCommand,
+		},
+		&command{
+			name: "SET", arity: -3, flags: flagWrite, firstKey: 1, lastKey: 1, step: 1,
+			group: "string", summary: "Sets the string value of a key, ignoring its type. The key is created if it doesn't exist.", since: "1.0.0", complexity: "O(1)",
+			handler: setCommand,
+		},
+		&command{
+			name: "GET", arity: 2, flags: flagReadonly | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "string", summary: "Returns the string value of a key.", since: "1.0.0", complexity: "O(1)",
+			handler: getCommand,
+		},
+		&command{
+			name: "CONFIG", arity: -2, flags: flagAdmin,
+			group: "server", summary: "Returns the effective values of configuration parameters.", since: "2.0.0", complexity: "O(N) when N is the number of configuration parameters provided",
+			handler: configCommand,
+		},
+		&command{
+			name: "SAVE", arity: 1, flags: flagAdmin,
+			group: "server", summary: "Synchronously saves the database(s) to disk.", since: "1.0.0", complexity: "O(N) where N is the total number of keys in all databases",
+			handler: saveCommand,
+		},
+		&command{
+			name: "COMMAND", arity: -1,
+			group: "server", summary: "Returns detailed information about all commands.", since: "2.8.13", complexity: "O(N) where N is the total number of Redis commands",
+			handler: commandCommand,
+		},
+	)
+}
+
+// checkArity reports whether the number of arguments, including the command name, matches the arity of the command.
+func (cmd *command) checkArity(argc int) bool {
+	if cmd.arity >= 0 {
+		return argc == cmd.arity
+	}
+	return argc >= -cmd.arity
+}
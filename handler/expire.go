@@ -0,0 +1,215 @@
+package handler
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/keyspace"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/persistence"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/types"
+)
+
+func init() {
+	register(
+		&command{
+			name: "EXPIRE", arity: -3, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "generic", summary: "Sets the expiration time of a key in seconds.", since: "1.0.0", complexity: "O(1)",
+			handler: expireCommand,
+		},
+		&command{
+			name: "PEXPIRE", arity: -3, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "generic", summary: "Sets the expiration time of a key in milliseconds.", since: "2.6.0", complexity: "O(1)",
+			handler: expireCommand,
+		},
+		&command{
+			name: "EXPIREAT", arity: -3, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "generic", summary: "Sets the expiration time of a key to a Unix timestamp.", since: "1.2.0", complexity: "O(1)",
+			handler: expireCommand,
+		},
+		&command{
+			name: "PEXPIREAT", arity: -3, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "generic", summary: "Sets the expiration time of a key to a Unix milliseconds timestamp.", since: "2.6.0", complexity: "O(1)",
+			handler: expireCommand,
+		},
+		&command{
+			name: "TTL", arity: 2, flags: flagReadonly | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "generic", summary: "Returns the expiration time in seconds of a key.", since: "1.0.0", complexity: "O(1)",
+			handler: ttlCommand,
+		},
+		&command{
+			name: "PTTL", arity: 2, flags: flagReadonly | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "generic", summary: "Returns the expiration time in milliseconds of a key.", since: "2.6.0", complexity: "O(1)",
+			handler: ttlCommand,
+		},
+		&command{
+			name: "EXPIRETIME", arity: 2, flags: flagReadonly | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "generic", summary: "Returns the expiration time of a key as a Unix timestamp.", since: "7.0.0", complexity: "O(1)",
+			handler: ttlCommand,
+		},
+		&command{
+			name: "PEXPIRETIME", arity: 2, flags: flagReadonly | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "generic", summary: "Returns the expiration time of a key as a Unix milliseconds timestamp.", since: "7.0.0", complexity: "O(1)",
+			handler: ttlCommand,
+		},
+		&command{
+			name: "PERSIST", arity: 2, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "generic", summary: "Removes the expiration time of a key.", since: "2.2.0", complexity: "O(1)",
+			handler: persistCommand,
+		},
+	)
+}
+
+// expireOptions holds the NX, XX, GT and LT options of the EXPIRE family.
+type expireOptions struct {
+	nx bool // only set the expiration time when the key has none
+	xx bool // only set the expiration time when the key has one
+	gt bool // only set the expiration time when it is greater than the current one
+	lt bool // only set the expiration time when it is less than the current one
+}
+
+// parseExpireOptions parses the options following the time of the EXPIRE family.
+// It returns the error reply to send when the options are invalid.
+func parseExpireOptions(options []string) (expireOptions, []byte) {
+	var opts expireOptions
+	for _, option := range options {
+		switch strings.ToUpper(option) {
+		case "NX":
+			opts.nx = true
+		case "XX":
+			opts.xx = true
+		case "GT":
+			opts.gt = true
+		case "LT":
+			opts.lt = true
+		default:
+			return opts, errorReply("ERR Unsupported option " + option)
+		}
+	}
+
+	if opts.nx && (opts.xx || opts.gt || opts.lt) {
+		return opts, errorReply("ERR NX and XX, GT or LT options at the same time are not compatible")
+	}
+	if opts.gt && opts.lt {
+		return opts, errorReply("ERR GT and LT options at the same time are not compatible")
+	}
+	return opts, nil
+}
+
+// allows reports whether the options allow replacing the expiration time of value with at.
+// A key without an expiration time is considered to have an infinite one by GT and LT.
+func (opts expireOptions) allows(value types.CustomValue, at int64) bool {
+	hasExpiration := value.HasExpiration()
+	switch {
+	case opts.nx && hasExpiration:
+		return false
+	case opts.xx && !hasExpiration:
+		return false
+	case opts.gt && (!hasExpiration || at <= value.ValueExpiration):
+		return false
+	case opts.lt && hasExpiration && at >= value.ValueExpiration:
+		return false
+	}
+	return true
+}
+
+// absoluteExpireTime converts the time given to EXPIRE, PEXPIRE, EXPIREAT or PEXPIREAT into an absolute
+// unix time in milliseconds. It reports false when the conversion overflows.
+func absoluteExpireTime(name string, n, now int64) (int64, bool) {
+	// Seconds are converted to milliseconds
+	if name == "EXPIRE" || name == "EXPIREAT" {
+		if n > math.MaxInt64/1000 || n < math.MinInt64/1000 {
+			return 0, false
+		}
+		n *= 1000
+	}
+	// Relative times are made absolute. now is positive, so only positive times can overflow
+	if name == "EXPIRE" || name == "PEXPIRE" {
+		if n > math.MaxInt64-now {
+			return 0, false
+		}
+		n += now
+	}
+	return n, true
+}
+
+func expireCommand(args []string, cache keyspace.Keyspace) []byte {
+	// EXPIRE <key> <seconds> [NX | XX | GT | LT]
+	// PEXPIRE, EXPIREAT and PEXPIREAT take the same options with a time in milliseconds or a unix timestamp
+	name := strings.ToUpper(args[0])
+	key := args[1]
+
+	n, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return errorReply(errNotInteger)
+	}
+	opts, errReply := parseExpireOptions(args[3:])
+	if errReply != nil {
+		return errReply
+	}
+
+	now := time.Now().UnixMilli()
+	at, ok := absoluteExpireTime(name, n, now)
+	if !ok {
+		return errorReply(fmt.Sprintf("ERR invalid expire time in '%s' command", strings.ToLower(name)))
+	}
+
+	var reply []byte
+	var changes int64
+	cache.Atomically([]string{key}, func(tx keyspace.Tx) {
+		value, exists := tx.Get(key)
+		if !exists || !opts.allows(value, at) {
+			reply = integer(0)
+			return
+		}
+
+		if at <= now && !cache.Loading() {
+			// An expiration time in the past deletes the key right away. While the append-only file is loaded,
+			// the key keeps it like the other expired keys, for the commands that follow to find the key.
+			tx.Delete(key)
+		} else {
+			value.ValueExpiration = at
+			tx.Set(key, value)
+		}
+		changes = 1
+		reply = integer(1)
+	})
+
+	persistence.AddChanges(changes)
+	return reply
+}
+
+func ttlCommand(args []string, cache keyspace.Keyspace) []byte {
+	// TTL <key>, PTTL <key>, EXPIRETIME <key> or PEXPIRETIME <key>
+	// -2 is replied when the key does not exist, and -1 when it has no expiration time
+	at, ok := cache.ExpireTime(args[1])
+	if !ok {
+		return integer(-2)
+	}
+	if at == -1 {
+		return integer(-1)
+	}
+
+	remaining := max(at-time.Now().UnixMilli(), 0)
+	switch strings.ToUpper(args[0]) {
+	case "TTL":
+		// The remaining time is rounded to the closest second
+		return integer((remaining + 500) / 1000)
+	case "PTTL":
+		return integer(remaining)
+	case "EXPIRETIME":
+		return integer(at / 1000)
+	}
+	return integer(at)
+}
+
+func persistCommand(args []string, cache keyspace.Keyspace) []byte {
+	// PERSIST <key>
+	if cache.Persist(args[1]) {
+		persistence.AddChanges(1)
+		return integer(1)
+	}
+	return integer(0)
+}
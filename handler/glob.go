@@ -0,0 +1,100 @@
+package handler
+
+// matchPattern reports whether s matches the glob-style pattern the way Redis matches KEYS and SCAN patterns:
+// '*' matches any sequence of bytes, '?' matches a single byte, '[...]' matches a byte of the set, where
+// '^' negates the set and 'a-z' is a range, and '\' escapes the next byte.
+//
+// The match is iterative with a single backtrack point at the last '*' seen: when the rest of the pattern fails,
+// that star absorbs one more byte and matching resumes after it. An earlier star never needs to be revisited,
+// since whatever it could absorb the last one can too, so patterns full of stars cannot make matching exponential.
+func matchPattern(pattern, s string) bool {
+	// starPattern is the pattern after the last '*' and starS the position in s it was last tried at
+	starPattern, starS := -1, 0
+	p, i := 0, 0
+	for i < len(s) {
+		if p < len(pattern) {
+			switch pattern[p] {
+			case '*':
+				starPattern, starS = p+1, i
+				p++
+				continue
+			case '?':
+				p++
+				i++
+				continue
+			case '[':
+				if matched, rest := matchSet(pattern[p+1:], s[i]); matched {
+					p = len(pattern) - len(rest)
+					i++
+					continue
+				}
+			default:
+				c := pattern[p]
+				next := p + 1
+				if c == '\\' && p+1 < len(pattern) {
+					c = pattern[p+1]
+					next = p + 2
+				}
+				if c == s[i] {
+					p = next
+					i++
+					continue
+				}
+			}
+		}
+		// Mismatch: let the last star absorb one more byte, or fail if there is none
+		if starPattern == -1 {
+			return false
+		}
+		starS++
+		p, i = starPattern, starS
+	}
+
+	// The whole of s was consumed, so only stars may be left in the pattern
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// matchSet matches c against the set that starts at the beginning of pattern, just after its opening '['.
+// It returns whether c is in the set and the pattern following the closing ']'.
+// An unterminated set extends to the end of the pattern.
+func matchSet(pattern string, c byte) (bool, string) {
+	negate := false
+	if len(pattern) > 0 && pattern[0] == '^' {
+		negate = true
+		pattern = pattern[1:]
+	}
+
+	matched := false
+	for len(pattern) > 0 && pattern[0] != ']' {
+		switch {
+		case pattern[0] == '\\' && len(pattern) >= 2:
+			if pattern[1] == c {
+				matched = true
+			}
+			pattern = pattern[2:]
+		case len(pattern) >= 3 && pattern[1] == '-':
+			start, end := pattern[0], pattern[2]
+			if start > end {
+				start, end = end, start
+			}
+			if c >= start && c <= end {
+				matched = true
+			}
+			pattern = pattern[3:]
+		default:
+			if pattern[0] == c {
+				matched = true
+			}
+			pattern = pattern[1:]
+		}
+	}
+	if len(pattern) > 0 {
+		// Skip the closing ']'
+		pattern = pattern[1:]
+	}
+
+	return matched != negate, pattern
+}
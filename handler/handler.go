@@ -1,147 +1,308 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/config"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/keyspace"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/persistence"
 	"github.com/Himanshu-Negi8/build-your-own-redis-server/types"
-	"os"
+	"log"
+	"math"
 	"strconv"
+	"strings"
 	"time"
 )
 
-const (
-	dir        = "/tmp/redis-data"
-	dbfilename = "rdbfile"
-)
-
-func HandleCommands(commandTokens interface{}, respType types.RESPType, cache map[string]types.CustomValue) []byte {
-	if respType == types.RESPTypeSimpleString {
-		if commandTokens.(string) == "PING" {
+func HandleCommands(commandTokens interface{}, respType types.RESPType, cache keyspace.Keyspace) []byte {
+	if command, ok := commandTokens.(string); ok {
+		if strings.EqualFold(command, "PING") {
 			return []byte("+PONG\r\n")
 		}
 		return []byte("+OK\r\n")
 	}
 
-	arr := commandTokens.([]interface{})
-	// Process the command based on its type
-	switch respType {
-	case types.RESPTypeArray:
-		switch arr[0].(string) {
-		case "ECHO":
-			return echoCommand(arr)
-		case "SET":
-			return setCommand(arr, cache)
-		case "GET":
-			return getCommand(arr, cache)
-		case "CONFIG":
-			return configCommand(arr)
-		case "SAVE":
-			return saveCommand(arr, cache)
+	arr, ok := commandTokens.([]interface{})
+	if !ok || len(arr) == 0 {
+		return []byte("-ERR unknown command\r\n")
+	}
+
+	args, err := commandArgs(arr)
+	if err != nil {
+		return []byte(fmt.Sprintf("-ERR %s\r\n", err))
+	}
+
+	cmd, errReply := lookupCommand(args)
+	if errReply != nil {
+		return errReply
+	}
+	if cmd.flags&flagWrite == 0 {
+		return cmd.handler(args, cache)
+	}
+
+	var reply []byte
+	persistence.LogWrite(func() [][]string {
+		reply = cmd.handler(args, cache)
+		if len(reply) > 0 && reply[0] == '-' {
+			return nil
 		}
+		return propagated(args, reply, cache)
+	})
+	return reply
+}
+
+// lookupCommand looks the command up in the command table, ignoring the case of its name, and checks its arity.
+// It returns the error reply to send when the command is unknown or has the wrong number of arguments.
+func lookupCommand(args []string) (*command, []byte) {
+	cmd, ok := commands[strings.ToUpper(args[0])]
+	if !ok {
+		return nil, []byte("-ERR unknown command\r\n")
 	}
+	if !cmd.checkArity(len(args)) {
+		return nil, wrongNumberOfArguments(cmd.name)
+	}
+	return cmd, nil
+}
 
-	return []byte("-ERR unknown command\r\n")
+// Replay executes a command read from the append-only file, given as its name and arguments, without logging it
+// again. Only the commands that succeeded are logged, so Replay only fails when the command is unknown or has the
+// wrong number of arguments, which means the file is corrupt.
+func Replay(args []string, cache keyspace.Keyspace) error {
+	cmd, errReply := lookupCommand(args)
+	if errReply != nil {
+		return fmt.Errorf("%s: %s", args[0], strings.TrimSpace(string(errReply[1:])))
+	}
+	cmd.handler(args, cache)
+	return nil
 }
 
-func echoCommand(arr []interface{}) []byte {
-	// validate length is exactly 2
-	// ECHO <message>
-	if len(arr) != 2 {
-		return []byte("-ERR wrong number of arguments for 'ECHO' command\r\n")
+// commandArgs converts the elements of a command array into strings.
+// Clients send every argument as a bulk string, but integers are accepted as well.
+func commandArgs(arr []interface{}) ([]string, error) {
+	args := make([]string, len(arr))
+	for i, elem := range arr {
+		switch v := elem.(type) {
+		case string:
+			args[i] = v
+		case int:
+			args[i] = strconv.Itoa(v)
+		default:
+			return nil, errors.New("Protocol error: expected bulk string arguments")
+		}
 	}
+	return args, nil
+}
 
-	message := arr[1].(string)
+// wrongNumberOfArguments returns the error replied when a command is called with an invalid number of arguments.
+func wrongNumberOfArguments(name string) []byte {
+	return []byte(fmt.Sprintf("-ERR wrong number of arguments for '%s' command\r\n", name))
+}
+
+func pingCommand(args []string, cache keyspace.Keyspace) []byte {
+	// PING [message]
+	if len(args) == 2 {
+		return []byte(fmt.Sprintf("$%d\r\n%s\r\n", len(args[1]), args[1]))
+	}
+	if len(args) > 2 {
+		return wrongNumberOfArguments("PING")
+	}
+	return []byte("+PONG\r\n")
+}
+
+func echoCommand(args []string, cache keyspace.Keyspace) []byte {
+	// ECHO <message>
+	message := args[1]
 	return []byte(fmt.Sprintf("$%d\r\n%s\r\n", len(message), message))
 }
 
-func setCommand(arr []interface{}, cache map[string]types.CustomValue) []byte {
-	// validate length is exactly 3 or 5
-	// SET <key> <value>
-	// SET <key> <value> <px> <expiration>
-	// In RESP we will receive the key as *3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n which is an array of 3 elements
-	if len(arr) == 5 {
-		key := arr[1].(string)
-		value := arr[2].(string)
+// setOptions holds the options of the SET command.
+type setOptions struct {
+	nx      bool  // only set the key if it does not exist
+	xx      bool  // only set the key if it already exists
+	get     bool  // reply with the previous value of the key
+	keepTTL bool  // retain the expiration time of the previous value
+	expire  int64 // absolute expiration time in unix milliseconds, or -1 for none
+}
 
-		expiration, err := strconv.ParseInt(arr[4].(string), 10, 64)
-		if err != nil {
-			return []byte("-ERR invalid expiration value\r\n")
+// parseSetOptions parses the options following SET <key> <value>.
+// It returns the error reply to send when the options are invalid.
+func parseSetOptions(options []string, now int64) (setOptions, []byte) {
+	opts := setOptions{expire: -1}
+	hasExpire := false
 
+	for i := 0; i < len(options); i++ {
+		switch option := strings.ToUpper(options[i]); option {
+		case "NX":
+			if opts.xx {
+				return opts, errorReply("ERR syntax error")
+			}
+			opts.nx = true
+		case "XX":
+			if opts.nx {
+				return opts, errorReply("ERR syntax error")
+			}
+			opts.xx = true
+		case "GET":
+			opts.get = true
+		case "KEEPTTL":
+			if hasExpire {
+				return opts, errorReply("ERR syntax error")
+			}
+			opts.keepTTL = true
+		case "EX", "PX", "EXAT", "PXAT":
+			if hasExpire || opts.keepTTL || i+1 == len(options) {
+				return opts, errorReply("ERR syntax error")
+			}
+			i++
+			n, err := strconv.ParseInt(options[i], 10, 64)
+			if err != nil {
+				return opts, errorReply("ERR invalid expiration value")
+			}
+			expire, errReply := parseExpireTime(option, n, now, "set")
+			if errReply != nil {
+				return opts, errReply
+			}
+			opts.expire = expire
+			hasExpire = true
+		default:
+			return opts, errorReply("ERR syntax error")
 		}
+	}
 
-		cache[key] = types.CustomValue{Value: value, ValueExpiration: time.Now().UnixMilli() + expiration}
-		return []byte(fmt.Sprintf("+OK\r\n"))
-	} else if len(arr) == 3 {
-		key := arr[1].(string)
-		value := arr[2].(string)
+	return opts, nil
+}
 
-		// This is without the expiration time.
-		cache[key] = types.CustomValue{Value: value, ValueExpiration: -1}
-		return []byte(fmt.Sprintf("+OK\r\n"))
+// parseExpireTime converts the value n of an EX, PX, EXAT or PXAT option into an absolute unix time in milliseconds.
+// It returns the error reply to send when the value is not positive or overflows.
+func parseExpireTime(unit string, n, now int64, commandName string) (int64, []byte) {
+	invalid := errorReply(fmt.Sprintf("ERR invalid expire time in '%s' command", commandName))
+	if n <= 0 {
+		return 0, invalid
 	}
 
-	return []byte("-ERR wrong number of arguments for 'SET' command\r\n")
+	// Seconds are converted to milliseconds, checking for overflows
+	if unit == "EX" || unit == "EXAT" {
+		if n > math.MaxInt64/1000 {
+			return 0, invalid
+		}
+		n *= 1000
+	}
+	// Relative times are made absolute
+	if unit == "EX" || unit == "PX" {
+		if n > math.MaxInt64-now {
+			return 0, invalid
+		}
+		n += now
+	}
+	return n, nil
 }
 
-func getCommand(arr []interface{}, cache map[string]types.CustomValue) []byte {
-	// validate length is exactly 2
-	// GET <key>
-	if len(arr) != 2 {
-		return []byte("-ERR wrong number of arguments for 'GET' command\r\n")
+func setCommand(args []string, cache keyspace.Keyspace) []byte {
+	// SET <key> <value> [NX | XX] [GET] [EX seconds | PX milliseconds | EXAT unix-time-seconds | PXAT unix-time-milliseconds | KEEPTTL]
+	// In RESP we will receive the key as *3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n which is an array of 3 elements
+	key := args[1]
+	value := args[2]
+
+	opts, errReply := parseSetOptions(args[3:], time.Now().UnixMilli())
+	if errReply != nil {
+		return errReply
 	}
+	if opts.expire >= 0 {
+		rewriteSetExpiration(args, opts.expire)
+	}
+
+	var reply []byte
+	var changes int64
+	cache.Atomically([]string{key}, func(tx keyspace.Tx) {
+		old, exists := tx.Get(key)
 
-	key := arr[1].(string)
-	val, ok := cache[key]
+		if opts.get {
+			if exists && old.Type != types.ValueTypeString {
+				reply = errorReply(errWrongType)
+				return
+			}
+			if exists {
+				reply = bulkString(old.Value)
+			} else {
+				reply = nullBulkString()
+			}
+		}
 
-	if ok && (val.ValueExpiration == -1 || val.ValueExpiration > time.Now().UnixMilli()) {
+		// The key is left untouched when the NX or XX condition is not met
+		if (opts.nx && exists) || (opts.xx && !exists) {
+			if reply == nil {
+				reply = nullBulkString()
+			}
+			return
+		}
+
+		expire := opts.expire
+		if opts.keepTTL && exists {
+			expire = old.ValueExpiration
+		}
+		tx.Set(key, types.CustomValue{Value: value, ValueExpiration: expire})
+		changes = 1
+
+		if reply == nil {
+			reply = simpleString("OK")
+		}
+	})
+
+	persistence.AddChanges(changes)
+	return reply
+}
+
+func getCommand(args []string, cache keyspace.Keyspace) []byte {
+	// GET <key>
+	key := args[1]
+	// The keyspace reports expired keys as missing
+	val, ok := cache.Get(key)
+
+	if ok && val.Type != types.ValueTypeString {
+		return errorReply(errWrongType)
+	}
+	if ok {
 		return []byte(fmt.Sprintf("$%d\r\n%s\r\n", len(val.Value), val.Value))
 	} else {
 		return []byte("$-1\r\n")
 	}
 }
 
-func configCommand(arr []interface{}) []byte {
-	if len(arr) != 3 {
-		return []byte("-ERR wrong number of arguments for 'CONFIG' command\r\n")
+func configCommand(args []string, cache keyspace.Keyspace) []byte {
+	// CONFIG GET <parameter> | CONFIG SET save <rules>
+	// The parameter names are case-insensitive, like the subcommands
+	if len(args) == 4 && strings.EqualFold(args[1], "SET") && strings.EqualFold(args[2], "save") {
+		rules, err := persistence.ParseSaveRules(args[3])
+		if err != nil {
+			return errorReply("ERR CONFIG SET failed (possibly related to argument 'save') - " + err.Error())
+		}
+		persistence.SetSaveRules(rules)
+		return simpleString("OK")
+	}
+	if len(args) != 3 {
+		return wrongNumberOfArguments("CONFIG")
 	}
 
-	if arr[1].(string) == "GET" && arr[2].(string) == "dir" {
-		return []byte(fmt.Sprintf("*2\r\n$3\r\ndir\r\n$%d\r\n%s\r\n", len(dir), dir))
-	} else if arr[1].(string) == "GET" && arr[2].(string) == "dbfilename" {
-		return []byte(fmt.Sprintf("*2\r\n$10\r\ndbfilename\r\n$%d\r\n%s\r\n", len(dbfilename), dbfilename))
+	if strings.EqualFold(args[1], "GET") && strings.EqualFold(args[2], "dir") {
+		return []byte(fmt.Sprintf("*2\r\n$3\r\ndir\r\n$%d\r\n%s\r\n", len(config.Dir), config.Dir))
+	} else if strings.EqualFold(args[1], "GET") && strings.EqualFold(args[2], "dbfilename") {
+		return []byte(fmt.Sprintf("*2\r\n$10\r\ndbfilename\r\n$%d\r\n%s\r\n", len(config.DBFilename), config.DBFilename))
+	} else if strings.EqualFold(args[1], "GET") && strings.EqualFold(args[2], "save") {
+		return bulkStringArray([]string{"save", persistence.FormatSaveRules(persistence.SaveRules())})
 	}
 	return []byte("-ERR unsupported CONFIG parameter\r\n")
 }
 
-func saveCommand(arr []interface{}, cache map[string]types.CustomValue) []byte {
-	// validate length is exactly 1
+func saveCommand(args []string, cache keyspace.Keyspace) []byte {
 	// SAVE
-	if len(arr) != 1 {
-		return []byte("-ERR wrong number of arguments for 'SAVE' command\r\n")
-	}
-
-	fmt.Println("Saving data to file")
-	// Create the directory if it doesn't exist
-	err := os.MkdirAll(dir, 0755)
-	if err != nil {
-		return []byte("-ERR failed to save data to file\r\n")
-	}
-
-	// Create the file
-	filePath := fmt.Sprintf("%s/%s", dir, dbfilename)
-	file, err := os.Create(filePath)
-	if err != nil {
-		return []byte("-ERR failed to save data to file\r\n")
-	}
-
-	file.WriteString("REDIS0009")
-	defer file.Close()
-	//
-	// Ensure data is flushed to disk
-	if err := file.Sync(); err != nil {
-		fmt.Println("Error syncing file to disk:", err)
+	// The whole keyspace is written before replying, like the blocking SAVE of Redis
+	if err := persistence.Save(config.RDBPath(), cache); err != nil {
+		if errors.Is(err, persistence.ErrSaveInProgress) {
+			return errorReply(errSaveInProgress)
+		}
+		log.Printf("failed to save the keyspace: %v", err)
 		return []byte("-ERR failed to save data to file\r\n")
 	}
 
-	fmt.Println("File saved successfully at", filePath)
 	return []byte("+OK\r\n")
 }
@@ -1,14 +1,40 @@
 package handler_test
 
 import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/config"
 	"github.com/Himanshu-Negi8/build-your-own-redis-server/handler"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/keyspace"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/parser"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/persistence"
 	"github.com/Himanshu-Negi8/build-your-own-redis-server/types"
 )
 
+// useTempDir points config.Dir to a temporary directory for the duration of the test, so that the files the
+// commands write do not clobber the ones of a real server.
+func useTempDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	previous := config.Dir
+	config.Dir = dir
+	t.Cleanup(func() {
+		config.Dir = previous
+	})
+	return dir
+}
+
 func TestHandleCommands(t *testing.T) {
-	cache := make(map[string]types.CustomValue)
+	cache := keyspace.New()
+	dir := useTempDir(t)
+	configGetDir := fmt.Sprintf("*2\r\n$3\r\ndir\r\n$%d\r\n%s\r\n", len(dir), dir)
 
 	tests := []struct {
 		name     string
@@ -44,7 +70,7 @@ func TestHandleCommands(t *testing.T) {
 			name:     "CONFIG GET dir command",
 			command:  []interface{}{"CONFIG", "GET", "dir"},
 			respType: types.RESPTypeArray,
-			expected: "*2\r\n$3\r\ndir\r\n$15\r\n/tmp/redis-data\r\n",
+			expected: configGetDir,
 		},
 		{
 			name:     "CONFIG GET dbfilename command",
@@ -82,6 +108,54 @@ func TestHandleCommands(t *testing.T) {
 			respType: types.RESPTypeArray,
 			expected: "-ERR invalid expiration value\r\n",
 		},
+		{
+			name:     "Lowercase set command",
+			command:  []interface{}{"set", "lowercase", "value"},
+			respType: types.RESPTypeArray,
+			expected: "+OK\r\n",
+		},
+		{
+			name:     "Mixed case get command",
+			command:  []interface{}{"gEt", "lowercase"},
+			respType: types.RESPTypeArray,
+			expected: "$5\r\nvalue\r\n",
+		},
+		{
+			name:     "PING command with message",
+			command:  []interface{}{"ping", "hello"},
+			respType: types.RESPTypeArray,
+			expected: "$5\r\nhello\r\n",
+		},
+		{
+			name:     "GET command with wrong number of arguments",
+			command:  []interface{}{"GET", "a", "b"},
+			respType: types.RESPTypeArray,
+			expected: "-ERR wrong number of arguments for 'GET' command\r\n",
+		},
+		{
+			name:     "Command with a non-string argument",
+			command:  []interface{}{"ECHO", []interface{}{"nested"}},
+			respType: types.RESPTypeArray,
+			expected: "-ERR Protocol error: expected bulk string arguments\r\n",
+		},
+		{
+			name:     "Command with an integer argument",
+			command:  []interface{}{"ECHO", 42},
+			respType: types.RESPTypeArray,
+			expected: "$2\r\n42\r\n",
+		},
+		{
+			name:     "Lowercase config get command",
+			command:  []interface{}{"config", "get", "dir"},
+			respType: types.RESPTypeArray,
+			expected: configGetDir,
+		},
+		{
+			name:     "Uppercase config parameter",
+			command:  []interface{}{"CONFIG", "GET", "DIR"},
+			respType: types.RESPTypeArray,
+			expected: configGetDir,
+		},
 		{
 			name:     "CONFIG command with unsupported parameter",
 			command:  []interface{}{"CONFIG", "GET", "unsupported"},
@@ -99,3 +173,980 @@ func TestHandleCommands(t *testing.T) {
 		})
 	}
 }
+
+func TestCommandCommand(t *testing.T) {
+	cache := keyspace.New()
+
+	tests := []struct {
+		name     string
+		command  []interface{}
+		expected string
+	}{
+		{
+			name:    "COMMAND INFO of a known command",
+			command: []interface{}{"COMMAND", "INFO", "get"},
+			expected: "*1\r\n*10\r\n$3\r\nget\r\n:2\r\n*2\r\n+readonly\r\n+fast\r\n:1\r\n:1\r\n:1\r\n" +
+				"*3\r\n+@read\r\n+@string\r\n+@fast\r\n*0\r\n*0\r\n*0\r\n",
+		},
+		{
+			name:     "COMMAND INFO of an unknown command",
+			command:  []interface{}{"COMMAND", "INFO", "nosuchcommand"},
+			expected: "*1\r\n*-1\r\n",
+		},
+		{
+			name:    "COMMAND DOCS of a known and an unknown command",
+			command: []interface{}{"COMMAND", "DOCS", "echo", "nosuchcommand"},
+			expected: "*2\r\n$4\r\necho\r\n*8\r\n$7\r\nsummary\r\n$25\r\nReturns the given string.\r\n" +
+				"$5\r\nsince\r\n$5\r\n1.0.0\r\n$5\r\ngroup\r\n$10\r\nconnection\r\n$10\r\ncomplexity\r\n$4\r\nO(1)\r\n",
+		},
+		{
+			name:    "COMMAND INFO of a command with movable keys",
+			command: []interface{}{"COMMAND", "INFO", "sintercard"},
+			expected: "*1\r\n*10\r\n$10\r\nsintercard\r\n:-3\r\n*2\r\n+readonly\r\n+movablekeys\r\n:0\r\n:0\r\n:0\r\n" +
+				"*3\r\n+@read\r\n+@set\r\n+@slow\r\n*0\r\n*0\r\n*0\r\n",
+		},
+		{
+			name:     "COMMAND GETKEYS of a command with a range of keys",
+			command:  []interface{}{"COMMAND", "GETKEYS", "mset", "a", "1", "b", "2"},
+			expected: "*2\r\n$1\r\na\r\n$1\r\nb\r\n",
+		},
+		{
+			name:     "COMMAND GETKEYS of ZUNIONSTORE",
+			command:  []interface{}{"COMMAND", "GETKEYS", "ZUNIONSTORE", "dst", "2", "a", "b", "WEIGHTS", "1", "2"},
+			expected: "*3\r\n$3\r\ndst\r\n$1\r\na\r\n$1\r\nb\r\n",
+		},
+		{
+			name:     "COMMAND GETKEYS of SINTERCARD",
+			command:  []interface{}{"COMMAND", "GETKEYS", "SINTERCARD", "2", "a", "b", "LIMIT", "1"},
+			expected: "*2\r\n$1\r\na\r\n$1\r\nb\r\n",
+		},
+		{
+			name:     "COMMAND GETKEYS with an invalid numkeys",
+			command:  []interface{}{"COMMAND", "GETKEYS", "SINTERCARD", "3", "a", "b"},
+			expected: "-ERR Invalid arguments specified for command\r\n",
+		},
+		{
+			name:     "COMMAND GETKEYS of a command without keys",
+			command:  []interface{}{"COMMAND", "GETKEYS", "PING"},
+			expected: "-ERR The command has no key arguments\r\n",
+		},
+		{
+			name:     "COMMAND GETKEYS with the wrong number of arguments",
+			command:  []interface{}{"COMMAND", "GETKEYS", "GET"},
+			expected: "-ERR Invalid number of arguments specified for command\r\n",
+		},
+		{
+			name:     "COMMAND GETKEYS of an unknown command",
+			command:  []interface{}{"COMMAND", "GETKEYS", "nosuchcommand"},
+			expected: "-ERR Invalid command specified\r\n",
+		},
+		{
+			name:     "COMMAND with an unknown subcommand",
+			command:  []interface{}{"COMMAND", "NOSUCH"},
+			expected: "-ERR unknown subcommand 'NOSUCH'. Try COMMAND HELP.\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := handler.HandleCommands(tt.command, types.RESPTypeArray, cache)
+			if string(response) != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, response)
+			}
+		})
+	}
+
+	t.Run("COMMAND COUNT matches the entries of COMMAND", func(t *testing.T) {
+		all, _, _, err := parser.ParseFrame(handler.HandleCommands([]interface{}{"COMMAND"}, types.RESPTypeArray, cache))
+		if err != nil {
+			t.Fatalf("failed to parse COMMAND reply: %v", err)
+		}
+		count, _, _, err := parser.ParseFrame(handler.HandleCommands([]interface{}{"COMMAND", "COUNT"}, types.RESPTypeArray, cache))
+		if err != nil {
+			t.Fatalf("failed to parse COMMAND COUNT reply: %v", err)
+		}
+		if len(all.([]interface{})) != count.(int) {
+			t.Errorf("expected COMMAND COUNT %v to match the %d entries of COMMAND", count, len(all.([]interface{})))
+		}
+	})
+}
+
+// step is a command run against a keyspace shared with the previous steps, and its expected reply.
+type step struct {
+	name     string
+	command  []interface{}
+	expected string
+}
+
+// runSteps runs the steps in order and stops at the first unexpected reply.
+func runSteps(t *testing.T, cache keyspace.Keyspace, steps []step) {
+	t.Helper()
+	for _, step := range steps {
+		response := handler.HandleCommands(step.command, types.RESPTypeArray, cache)
+		if string(response) != step.expected {
+			t.Fatalf("%s: expected %q, got %q", step.name, step.expected, response)
+		}
+	}
+}
+
+func TestSetCommandOptions(t *testing.T) {
+	cache := keyspace.New()
+
+	runSteps(t, cache, []step{
+		{"SET NX on a missing key", []interface{}{"SET", "k", "v1", "NX"}, "+OK\r\n"},
+		{"SET NX on an existing key", []interface{}{"SET", "k", "v2", "nx"}, "$-1\r\n"},
+		{"GET after SET NX", []interface{}{"GET", "k"}, "$2\r\nv1\r\n"},
+		{"SET XX on an existing key", []interface{}{"SET", "k", "v2", "XX"}, "+OK\r\n"},
+		{"SET XX on a missing key", []interface{}{"SET", "missing", "v", "XX"}, "$-1\r\n"},
+		{"GET of a key not set by SET XX", []interface{}{"GET", "missing"}, "$-1\r\n"},
+		{"SET GET returns the old value", []interface{}{"SET", "k", "v3", "GET"}, "$2\r\nv2\r\n"},
+		{"SET GET on a missing key", []interface{}{"SET", "new", "v", "GET"}, "$-1\r\n"},
+		{"SET NX GET on an existing key", []interface{}{"SET", "k", "v4", "NX", "GET"}, "$2\r\nv3\r\n"},
+		{"GET after SET NX GET", []interface{}{"GET", "k"}, "$2\r\nv3\r\n"},
+		{"SET with NX and XX", []interface{}{"SET", "k", "v", "NX", "XX"}, "-ERR syntax error\r\n"},
+		{"SET with EX and PX", []interface{}{"SET", "k", "v", "EX", "10", "PX", "100"}, "-ERR syntax error\r\n"},
+		{"SET with EX and KEEPTTL", []interface{}{"SET", "k", "v", "EX", "10", "KEEPTTL"}, "-ERR syntax error\r\n"},
+		{"SET with a missing EX value", []interface{}{"SET", "k", "v", "EX"}, "-ERR syntax error\r\n"},
+		{"SET with an unknown option", []interface{}{"SET", "k", "v", "FOO"}, "-ERR syntax error\r\n"},
+		{"SET with a zero expire time", []interface{}{"SET", "k", "v", "EX", "0"}, "-ERR invalid expire time in 'set' command\r\n"},
+		{"SET with a negative expire time", []interface{}{"SET", "k", "v", "PX", "-5"}, "-ERR invalid expire time in 'set' command\r\n"},
+		{"SET with an overflowing expire time", []interface{}{"SET", "k", "v", "EX", "9223372036854775807"}, "-ERR invalid expire time in 'set' command\r\n"},
+		{"SET with a non-integer expire time", []interface{}{"SET", "k", "v", "EXAT", "soon"}, "-ERR invalid expiration value\r\n"},
+		{"SET with EXAT in the past", []interface{}{"SET", "past", "v", "EXAT", "1"}, "+OK\r\n"},
+		{"GET of a key set with EXAT in the past", []interface{}{"GET", "past"}, "$-1\r\n"},
+	})
+
+	expireTime := func(key string) int64 {
+		at, ok := cache.ExpireTime(key)
+		if !ok {
+			t.Fatalf("expected %s to exist", key)
+		}
+		return at
+	}
+
+	before := time.Now().UnixMilli()
+	handler.HandleCommands([]interface{}{"SET", "ttl", "v", "EX", "100"}, types.RESPTypeArray, cache)
+	if at := expireTime("ttl"); at < before+100000 || at > time.Now().UnixMilli()+100000 {
+		t.Errorf("expected SET EX to expire in 100 seconds, got expiration time %d", at)
+	}
+
+	handler.HandleCommands([]interface{}{"SET", "ttl", "v2", "KEEPTTL"}, types.RESPTypeArray, cache)
+	if at := expireTime("ttl"); at < before+100000 {
+		t.Errorf("expected SET KEEPTTL to retain the expiration time, got %d", at)
+	}
+
+	handler.HandleCommands([]interface{}{"SET", "ttl", "v3"}, types.RESPTypeArray, cache)
+	if at := expireTime("ttl"); at != -1 {
+		t.Errorf("expected SET to clear the expiration time, got %d", at)
+	}
+
+	handler.HandleCommands([]interface{}{"SET", "ttl", "v", "PXAT", "4102444800000"}, types.RESPTypeArray, cache)
+	if at := expireTime("ttl"); at != 4102444800000 {
+		t.Errorf("expected SET PXAT to set the absolute expiration time, got %d", at)
+	}
+}
+
+func TestExpireCommands(t *testing.T) {
+	cache := keyspace.New()
+	future := strconv.FormatInt(time.Now().Unix()+1000, 10)
+
+	runSteps(t, cache, []step{
+		{"TTL of a missing key", []interface{}{"TTL", "k"}, ":-2\r\n"},
+		{"EXPIRE of a missing key", []interface{}{"EXPIRE", "k", "100"}, ":0\r\n"},
+		{"SET", []interface{}{"SET", "k", "v"}, "+OK\r\n"},
+		{"TTL of a key without expiration", []interface{}{"TTL", "k"}, ":-1\r\n"},
+		{"EXPIRETIME of a key without expiration", []interface{}{"EXPIRETIME", "k"}, ":-1\r\n"},
+		{"PERSIST of a key without expiration", []interface{}{"PERSIST", "k"}, ":0\r\n"},
+		{"EXPIRE XX without expiration", []interface{}{"EXPIRE", "k", "100", "XX"}, ":0\r\n"},
+		{"EXPIRE GT without expiration", []interface{}{"EXPIRE", "k", "100", "GT"}, ":0\r\n"},
+		{"EXPIRE NX without expiration", []interface{}{"EXPIRE", "k", "100", "NX"}, ":1\r\n"},
+		{"TTL after EXPIRE", []interface{}{"TTL", "k"}, ":100\r\n"},
+		{"EXPIRE NX with expiration", []interface{}{"EXPIRE", "k", "200", "nx"}, ":0\r\n"},
+		{"EXPIRE GT with a lower time", []interface{}{"EXPIRE", "k", "50", "GT"}, ":0\r\n"},
+		{"EXPIRE GT with a greater time", []interface{}{"EXPIRE", "k", "200", "GT"}, ":1\r\n"},
+		{"EXPIRE LT with a greater time", []interface{}{"EXPIRE", "k", "300", "LT"}, ":0\r\n"},
+		{"EXPIRE LT XX with a lower time", []interface{}{"EXPIRE", "k", "150", "LT", "XX"}, ":1\r\n"},
+		{"TTL after EXPIRE LT", []interface{}{"TTL", "k"}, ":150\r\n"},
+		{"PEXPIRE", []interface{}{"PEXPIRE", "k", "5000"}, ":1\r\n"},
+		{"TTL after PEXPIRE", []interface{}{"TTL", "k"}, ":5\r\n"},
+		{"EXPIREAT", []interface{}{"EXPIREAT", "k", future}, ":1\r\n"},
+		{"EXPIRETIME after EXPIREAT", []interface{}{"EXPIRETIME", "k"}, ":" + future + "\r\n"},
+		{"PEXPIRETIME after EXPIREAT", []interface{}{"PEXPIRETIME", "k"}, ":" + future + "000\r\n"},
+		{"PERSIST with expiration", []interface{}{"PERSIST", "k"}, ":1\r\n"},
+		{"PTTL after PERSIST", []interface{}{"PTTL", "k"}, ":-1\r\n"},
+		{"PEXPIREAT in the past", []interface{}{"PEXPIREAT", "k", "1"}, ":1\r\n"},
+		{"GET after PEXPIREAT in the past", []interface{}{"GET", "k"}, "$-1\r\n"},
+		{"SET again", []interface{}{"SET", "k", "v"}, "+OK\r\n"},
+		{"EXPIRE with a negative time", []interface{}{"EXPIRE", "k", "-1"}, ":1\r\n"},
+		{"TTL after EXPIRE with a negative time", []interface{}{"TTL", "k"}, ":-2\r\n"},
+		{"EXPIRE with NX and XX", []interface{}{"EXPIRE", "k", "10", "NX", "XX"}, "-ERR NX and XX, GT or LT options at the same time are not compatible\r\n"},
+		{"EXPIRE with GT and LT", []interface{}{"EXPIRE", "k", "10", "GT", "LT"}, "-ERR GT and LT options at the same time are not compatible\r\n"},
+		{"EXPIRE with an unknown option", []interface{}{"EXPIRE", "k", "10", "FOO"}, "-ERR Unsupported option FOO\r\n"},
+		{"EXPIRE with a non-integer time", []interface{}{"EXPIRE", "k", "ten"}, "-ERR value is not an integer or out of range\r\n"},
+		{"EXPIRE with an overflowing time", []interface{}{"EXPIRE", "k", "9223372036854775807"}, "-ERR invalid expire time in 'expire' command\r\n"},
+	})
+}
+
+func TestKeyCommands(t *testing.T) {
+	cache := keyspace.New()
+
+	runSteps(t, cache, []step{
+		{"SET a", []interface{}{"SET", "a", "1"}, "+OK\r\n"},
+		{"SET b", []interface{}{"SET", "b", "2", "EX", "100"}, "+OK\r\n"},
+		{"EXISTS with duplicates and a missing key", []interface{}{"EXISTS", "a", "b", "a", "missing"}, ":3\r\n"},
+		{"TOUCH", []interface{}{"TOUCH", "a", "missing"}, ":1\r\n"},
+		{"TYPE of a string", []interface{}{"TYPE", "a"}, "+string\r\n"},
+		{"TYPE of a missing key", []interface{}{"TYPE", "missing"}, "+none\r\n"},
+		{"RENAME of a missing key", []interface{}{"RENAME", "missing", "c"}, "-ERR no such key\r\n"},
+		{"RENAME", []interface{}{"RENAME", "b", "c"}, "+OK\r\n"},
+		{"GET of the renamed key", []interface{}{"GET", "b"}, "$-1\r\n"},
+		{"GET of the new name", []interface{}{"GET", "c"}, "$1\r\n2\r\n"},
+		{"TTL of the new name", []interface{}{"TTL", "c"}, ":100\r\n"},
+		{"RENAME onto itself", []interface{}{"RENAME", "c", "c"}, "+OK\r\n"},
+		{"RENAMENX onto an existing key", []interface{}{"RENAMENX", "c", "a"}, ":0\r\n"},
+		{"RENAMENX", []interface{}{"RENAMENX", "c", "d"}, ":1\r\n"},
+		{"COPY onto an existing key", []interface{}{"COPY", "d", "a"}, ":0\r\n"},
+		{"COPY REPLACE onto an existing key", []interface{}{"COPY", "d", "a", "REPLACE"}, ":1\r\n"},
+		{"GET of the copy", []interface{}{"GET", "a"}, "$1\r\n2\r\n"},
+		{"TTL of the copy", []interface{}{"TTL", "a"}, ":100\r\n"},
+		{"COPY of a missing key", []interface{}{"COPY", "missing", "e"}, ":0\r\n"},
+		{"COPY to the same key", []interface{}{"COPY", "a", "a"}, "-ERR source and destination objects are the same\r\n"},
+		{"COPY to another database", []interface{}{"COPY", "a", "e", "DB", "1"}, "-ERR DB index is out of range\r\n"},
+		{"COPY with an unknown option", []interface{}{"COPY", "a", "e", "FOO"}, "-ERR syntax error\r\n"},
+		{"DEL with a missing key", []interface{}{"DEL", "a", "d", "missing"}, ":2\r\n"},
+		{"UNLINK of a deleted key", []interface{}{"UNLINK", "a"}, ":0\r\n"},
+		{"EXISTS after DEL", []interface{}{"EXISTS", "a", "d"}, ":0\r\n"},
+	})
+}
+
+// replyStrings parses a RESP array reply of bulk strings and returns its elements sorted.
+func replyStrings(t *testing.T, response []byte) []string {
+	t.Helper()
+	value, _, _, err := parser.ParseFrame(response)
+	if err != nil {
+		t.Fatalf("failed to parse reply %q: %v", response, err)
+	}
+	elems, ok := value.([]interface{})
+	if !ok {
+		t.Fatalf("expected an array reply, got %q", response)
+	}
+	values := make([]string, len(elems))
+	for i, elem := range elems {
+		values[i] = elem.(string)
+	}
+	sort.Strings(values)
+	return values
+}
+
+func TestKeysAndScan(t *testing.T) {
+	cache := keyspace.New()
+	for _, key := range []string{"hello", "hallo", "hxllo", "hllo", "heeeello", "hbllo", "h*llo", "user:1", "user:2", "other"} {
+		handler.HandleCommands([]interface{}{"SET", key, "v"}, types.RESPTypeArray, cache)
+	}
+
+	patterns := []struct {
+		pattern  string
+		expected []string
+	}{
+		{"h?llo", []string{"h*llo", "hallo", "hbllo", "hello", "hxllo"}},
+		{"h*llo", []string{"h*llo", "hallo", "hbllo", "heeeello", "hello", "hllo", "hxllo"}},
+		{"h[ae]llo", []string{"hallo", "hello"}},
+		{"h[^e]llo", []string{"h*llo", "hallo", "hbllo", "hxllo"}},
+		{"h[a-b]llo", []string{"hallo", "hbllo"}},
+		{"h\\*llo", []string{"h*llo"}},
+		{"user:*", []string{"user:1", "user:2"}},
+		{"nomatch*", []string{}},
+		{"h*e*l*o", []string{"heeeello", "hello"}},
+		{"*r:[1]", []string{"user:1"}},
+		// Would take exponential time with a backtracking matcher
+		{"*a*a*a*a*a*a*a*a*a*a*a*a*a*a*a*a*b", []string{}},
+	}
+	handler.HandleCommands([]interface{}{"SET", strings.Repeat("a", 100), "v"}, types.RESPTypeArray, cache)
+	for _, tt := range patterns {
+		t.Run("KEYS "+tt.pattern, func(t *testing.T) {
+			keys := replyStrings(t, handler.HandleCommands([]interface{}{"KEYS", tt.pattern}, types.RESPTypeArray, cache))
+			if !reflect.DeepEqual(keys, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, keys)
+			}
+		})
+	}
+
+	t.Run("SCAN iterates over every key", func(t *testing.T) {
+		var keys []string
+		cursor := "0"
+		for {
+			value, _, _, err := parser.ParseFrame(handler.HandleCommands([]interface{}{"SCAN", cursor, "COUNT", "3", "MATCH", "user:*", "TYPE", "string"}, types.RESPTypeArray, cache))
+			if err != nil {
+				t.Fatalf("failed to parse SCAN reply: %v", err)
+			}
+			reply := value.([]interface{})
+			cursor = reply[0].(string)
+			for _, key := range reply[1].([]interface{}) {
+				keys = append(keys, key.(string))
+			}
+			if cursor == "0" {
+				break
+			}
+		}
+		sort.Strings(keys)
+		if expected := []string{"user:1", "user:2"}; !reflect.DeepEqual(keys, expected) {
+			t.Errorf("expected %v, got %v", expected, keys)
+		}
+	})
+
+	runSteps(t, cache, []step{
+		{"SCAN with a type matching no key", []interface{}{"SCAN", "0", "COUNT", "100", "TYPE", "list"}, "*2\r\n$1\r\n0\r\n*0\r\n"},
+		{"SCAN with an invalid cursor", []interface{}{"SCAN", "abc"}, "-ERR invalid cursor\r\n"},
+		{"SCAN with a zero count", []interface{}{"SCAN", "0", "COUNT", "0"}, "-ERR syntax error\r\n"},
+		{"SCAN with a missing option value", []interface{}{"SCAN", "0", "MATCH"}, "-ERR syntax error\r\n"},
+	})
+}
+
+func TestNumericCommands(t *testing.T) {
+	cache := keyspace.New()
+
+	runSteps(t, cache, []step{
+		{"INCR of a missing key", []interface{}{"INCR", "counter"}, ":1\r\n"},
+		{"INCRBY", []interface{}{"INCRBY", "counter", "41"}, ":42\r\n"},
+		{"DECR", []interface{}{"DECR", "counter"}, ":41\r\n"},
+		{"DECRBY", []interface{}{"DECRBY", "counter", "-9"}, ":50\r\n"},
+		{"GET of the counter", []interface{}{"GET", "counter"}, "$2\r\n50\r\n"},
+		{"EXPIRE the counter", []interface{}{"EXPIRE", "counter", "100"}, ":1\r\n"},
+		{"INCR keeps the expiration time", []interface{}{"INCR", "counter"}, ":51\r\n"},
+		{"TTL after INCR", []interface{}{"TTL", "counter"}, ":100\r\n"},
+		{"INCRBY with a non-integer increment", []interface{}{"INCRBY", "counter", "1.5"}, "-ERR value is not an integer or out of range\r\n"},
+		{"SET a non-integer", []interface{}{"SET", "text", "abc"}, "+OK\r\n"},
+		{"INCR of a non-integer", []interface{}{"INCR", "text"}, "-ERR value is not an integer or out of range\r\n"},
+		{"SET an integer with a leading space", []interface{}{"SET", "spaced", " 1"}, "+OK\r\n"},
+		{"INCR of an integer with a leading space", []interface{}{"INCR", "spaced"}, "-ERR value is not an integer or out of range\r\n"},
+		{"SET the maximum integer", []interface{}{"SET", "max", "9223372036854775807"}, "+OK\r\n"},
+		{"INCR overflowing", []interface{}{"INCR", "max"}, "-ERR increment or decrement would overflow\r\n"},
+		{"SET the minimum integer", []interface{}{"SET", "min", "-9223372036854775808"}, "+OK\r\n"},
+		{"DECR overflowing", []interface{}{"DECR", "min"}, "-ERR increment or decrement would overflow\r\n"},
+		{"DECRBY the minimum integer", []interface{}{"DECRBY", "counter", "-9223372036854775808"}, "-ERR decrement would overflow\r\n"},
+		{"INCRBYFLOAT of a missing key", []interface{}{"INCRBYFLOAT", "float", "10.5"}, "$4\r\n10.5\r\n"},
+		{"INCRBYFLOAT", []interface{}{"INCRBYFLOAT", "float", "0.1"}, "$4\r\n10.6\r\n"},
+		{"INCRBYFLOAT with an exponent", []interface{}{"INCRBYFLOAT", "float", "5.0e3"}, "$6\r\n5010.6\r\n"},
+		{"INCRBYFLOAT of an integer", []interface{}{"INCRBYFLOAT", "counter", "-1"}, "$2\r\n50\r\n"},
+		{"INCRBYFLOAT of a non-float", []interface{}{"INCRBYFLOAT", "text", "1"}, "-ERR value is not a valid float\r\n"},
+		{"INCRBYFLOAT with a non-float increment", []interface{}{"INCRBYFLOAT", "float", "abc"}, "-ERR value is not a valid float\r\n"},
+		{"SET a large float", []interface{}{"SET", "large", "1.7e308"}, "+OK\r\n"},
+		{"INCRBYFLOAT overflowing to infinity", []interface{}{"INCRBYFLOAT", "large", "1.7e308"}, "-ERR increment would produce NaN or Infinity\r\n"},
+	})
+}
+
+func TestStringCommands(t *testing.T) {
+	cache := keyspace.New()
+
+	runSteps(t, cache, []step{
+		{"APPEND to a missing key", []interface{}{"APPEND", "s", "Hello"}, ":5\r\n"},
+		{"APPEND binary data", []interface{}{"APPEND", "s", " \x00World"}, ":12\r\n"},
+		{"STRLEN", []interface{}{"STRLEN", "s"}, ":12\r\n"},
+		{"STRLEN of a missing key", []interface{}{"STRLEN", "missing"}, ":0\r\n"},
+		{"SET", []interface{}{"SET", "r", "This is a string"}, "+OK\r\n"},
+		{"GETRANGE", []interface{}{"GETRANGE", "r", "0", "3"}, "$4\r\nThis\r\n"},
+		{"GETRANGE with negative offsets", []interface{}{"GETRANGE", "r", "-3", "-1"}, "$3\r\ning\r\n"},
+		{"GETRANGE of the whole string", []interface{}{"GETRANGE", "r", "0", "-1"}, "$16\r\nThis is a string\r\n"},
+		{"GETRANGE past the end", []interface{}{"GETRANGE", "r", "10", "100"}, "$6\r\nstring\r\n"},
+		{"GETRANGE reversed", []interface{}{"GETRANGE", "r", "5", "3"}, "$0\r\n\r\n"},
+		{"GETRANGE reversed negative", []interface{}{"GETRANGE", "r", "-1", "-5"}, "$0\r\n\r\n"},
+		{"GETRANGE of a missing key", []interface{}{"GETRANGE", "missing", "0", "-1"}, "$0\r\n\r\n"},
+		{"SETRANGE", []interface{}{"SETRANGE", "r", "10", "STRING"}, ":16\r\n"},
+		{"GET after SETRANGE", []interface{}{"GET", "r"}, "$16\r\nThis is a STRING\r\n"},
+		{"SETRANGE of a missing key pads with zeros", []interface{}{"SETRANGE", "padded", "3", "ab"}, ":5\r\n"},
+		{"GET of the padded key", []interface{}{"GET", "padded"}, "$5\r\n\x00\x00\x00ab\r\n"},
+		{"SETRANGE with an empty value on a missing key", []interface{}{"SETRANGE", "empty", "10", ""}, ":0\r\n"},
+		{"EXISTS after SETRANGE with an empty value", []interface{}{"EXISTS", "empty"}, ":0\r\n"},
+		{"SETRANGE with a negative offset", []interface{}{"SETRANGE", "r", "-1", "x"}, "-ERR offset is out of range\r\n"},
+		{"SETRANGE past the maximum size", []interface{}{"SETRANGE", "r", "536870912", "x"}, "-ERR string exceeds maximum allowed size (proto-max-bulk-len)\r\n"},
+		{"GETDEL", []interface{}{"GETDEL", "r"}, "$16\r\nThis is a STRING\r\n"},
+		{"GETDEL of a deleted key", []interface{}{"GETDEL", "r"}, "$-1\r\n"},
+		{"SET for GETEX", []interface{}{"SET", "g", "v"}, "+OK\r\n"},
+		{"GETEX without options", []interface{}{"GETEX", "g"}, "$1\r\nv\r\n"},
+		{"GETEX EX", []interface{}{"GETEX", "g", "EX", "100"}, "$1\r\nv\r\n"},
+		{"TTL after GETEX EX", []interface{}{"TTL", "g"}, ":100\r\n"},
+		{"GETEX PERSIST", []interface{}{"GETEX", "g", "PERSIST"}, "$1\r\nv\r\n"},
+		{"TTL after GETEX PERSIST", []interface{}{"TTL", "g"}, ":-1\r\n"},
+		{"GETEX with EX and PERSIST", []interface{}{"GETEX", "g", "EX", "1", "PERSIST"}, "-ERR syntax error\r\n"},
+		{"GETEX with an invalid time", []interface{}{"GETEX", "g", "PX", "0"}, "-ERR invalid expire time in 'getex' command\r\n"},
+		{"GETEX PXAT in the past", []interface{}{"GETEX", "g", "PXAT", "1"}, "$1\r\nv\r\n"},
+		{"GET after GETEX PXAT in the past", []interface{}{"GET", "g"}, "$-1\r\n"},
+		{"GETEX of a missing key", []interface{}{"GETEX", "g"}, "$-1\r\n"},
+		{"MSET", []interface{}{"MSET", "a", "1", "b", "2"}, "+OK\r\n"},
+		{"MSET with an odd number of arguments", []interface{}{"MSET", "a", "1", "b"}, "-ERR wrong number of arguments for 'MSET' command\r\n"},
+		{"MGET", []interface{}{"MGET", "a", "missing", "b"}, "*3\r\n$1\r\n1\r\n$-1\r\n$1\r\n2\r\n"},
+		{"MSETNX with an existing key", []interface{}{"MSETNX", "c", "3", "a", "x"}, ":0\r\n"},
+		{"GET of a key not set by MSETNX", []interface{}{"GET", "c"}, "$-1\r\n"},
+		{"MSETNX", []interface{}{"MSETNX", "c", "3", "d", "4"}, ":1\r\n"},
+		{"MGET after MSETNX", []interface{}{"MGET", "c", "d"}, "*2\r\n$1\r\n3\r\n$1\r\n4\r\n"},
+		{"SETNX of an existing key", []interface{}{"SETNX", "a", "x"}, ":0\r\n"},
+		{"SETNX", []interface{}{"SETNX", "e", "5"}, ":1\r\n"},
+		{"SETEX", []interface{}{"SETEX", "ex", "100", "v"}, "+OK\r\n"},
+		{"TTL after SETEX", []interface{}{"TTL", "ex"}, ":100\r\n"},
+		{"PSETEX", []interface{}{"PSETEX", "px", "100000", "v"}, "+OK\r\n"},
+		{"TTL after PSETEX", []interface{}{"TTL", "px"}, ":100\r\n"},
+		{"SETEX with an invalid time", []interface{}{"SETEX", "ex", "-1", "v"}, "-ERR invalid expire time in 'setex' command\r\n"},
+		{"SETEX with a non-integer time", []interface{}{"SETEX", "ex", "soon", "v"}, "-ERR value is not an integer or out of range\r\n"},
+	})
+}
+
+func TestListCommands(t *testing.T) {
+	cache := keyspace.New()
+
+	runSteps(t, cache, []step{
+		{"RPUSH to a missing key", []interface{}{"RPUSH", "l", "a", "b", "c"}, ":3\r\n"},
+		{"LPUSH", []interface{}{"LPUSH", "l", "y", "z"}, ":5\r\n"},
+		{"LRANGE of the whole list", []interface{}{"LRANGE", "l", "0", "-1"}, "*5\r\n$1\r\nz\r\n$1\r\ny\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		{"LRANGE out of range", []interface{}{"LRANGE", "l", "-100", "1"}, "*2\r\n$1\r\nz\r\n$1\r\ny\r\n"},
+		{"LRANGE reversed", []interface{}{"LRANGE", "l", "3", "1"}, "*0\r\n"},
+		{"LRANGE of a missing key", []interface{}{"LRANGE", "missing", "0", "-1"}, "*0\r\n"},
+		{"TYPE of a list", []interface{}{"TYPE", "l"}, "+list\r\n"},
+		{"LLEN", []interface{}{"LLEN", "l"}, ":5\r\n"},
+		{"LLEN of a missing key", []interface{}{"LLEN", "missing"}, ":0\r\n"},
+		{"LINDEX", []interface{}{"LINDEX", "l", "2"}, "$1\r\na\r\n"},
+		{"LINDEX with a negative index", []interface{}{"LINDEX", "l", "-1"}, "$1\r\nc\r\n"},
+		{"LINDEX out of range", []interface{}{"LINDEX", "l", "5"}, "$-1\r\n"},
+		{"LPOP", []interface{}{"LPOP", "l"}, "$1\r\nz\r\n"},
+		{"RPOP with a count", []interface{}{"RPOP", "l", "2"}, "*2\r\n$1\r\nc\r\n$1\r\nb\r\n"},
+		{"LPOP with a negative count", []interface{}{"LPOP", "l", "-1"}, "-ERR value is out of range, must be positive\r\n"},
+		{"LPOP of a missing key", []interface{}{"LPOP", "missing"}, "$-1\r\n"},
+		{"lpop with too many arguments", []interface{}{"lpop", "l", "1", "2"}, "-ERR wrong number of arguments for 'LPOP' command\r\n"},
+		{"LPOP with a count of a missing key", []interface{}{"LPOP", "missing", "2"}, "*-1\r\n"},
+		{"LSET", []interface{}{"LSET", "l", "0", "Y"}, "+OK\r\n"},
+		{"LSET out of range", []interface{}{"LSET", "l", "5", "x"}, "-ERR index out of range\r\n"},
+		{"LSET of a missing key", []interface{}{"LSET", "missing", "0", "x"}, "-ERR no such key\r\n"},
+		{"LPOP with a count larger than the list", []interface{}{"LPOP", "l", "10"}, "*2\r\n$1\r\nY\r\n$1\r\na\r\n"},
+		{"EXISTS after popping the last element", []interface{}{"EXISTS", "l"}, ":0\r\n"},
+		{"RPUSH for LREM", []interface{}{"RPUSH", "r", "a", "b", "a", "c", "a"}, ":5\r\n"},
+		{"LREM from the tail", []interface{}{"LREM", "r", "-2", "a"}, ":2\r\n"},
+		{"LRANGE after LREM", []interface{}{"LRANGE", "r", "0", "-1"}, "*3\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		{"LREM of all", []interface{}{"LREM", "r", "0", "a"}, ":1\r\n"},
+		{"LINSERT BEFORE", []interface{}{"LINSERT", "r", "BEFORE", "c", "x"}, ":3\r\n"},
+		{"LINSERT AFTER", []interface{}{"LINSERT", "r", "after", "c", "y"}, ":4\r\n"},
+		{"LINSERT with a missing pivot", []interface{}{"LINSERT", "r", "BEFORE", "z", "x"}, ":-1\r\n"},
+		{"LINSERT of a missing key", []interface{}{"LINSERT", "missing", "BEFORE", "z", "x"}, ":0\r\n"},
+		{"LINSERT with a syntax error", []interface{}{"LINSERT", "r", "AROUND", "c", "x"}, "-ERR syntax error\r\n"},
+		{"LRANGE after LINSERT", []interface{}{"LRANGE", "r", "0", "-1"}, "*4\r\n$1\r\nb\r\n$1\r\nx\r\n$1\r\nc\r\n$1\r\ny\r\n"},
+		{"LTRIM", []interface{}{"LTRIM", "r", "1", "-2"}, "+OK\r\n"},
+		{"LRANGE after LTRIM", []interface{}{"LRANGE", "r", "0", "-1"}, "*2\r\n$1\r\nx\r\n$1\r\nc\r\n"},
+		{"LTRIM to an empty range", []interface{}{"LTRIM", "r", "5", "10"}, "+OK\r\n"},
+		{"EXISTS after trimming every element", []interface{}{"EXISTS", "r"}, ":0\r\n"},
+		{"RPUSH for LPOS", []interface{}{"RPUSH", "p", "a", "b", "c", "1", "2", "3", "c", "c"}, ":8\r\n"},
+		{"LPOS", []interface{}{"LPOS", "p", "c"}, ":2\r\n"},
+		{"LPOS with a RANK", []interface{}{"LPOS", "p", "c", "RANK", "2"}, ":6\r\n"},
+		{"LPOS with a negative RANK", []interface{}{"LPOS", "p", "c", "RANK", "-1"}, ":7\r\n"},
+		{"LPOS with a COUNT", []interface{}{"LPOS", "p", "c", "COUNT", "2"}, "*2\r\n:2\r\n:6\r\n"},
+		{"LPOS with a COUNT of 0", []interface{}{"LPOS", "p", "c", "COUNT", "0"}, "*3\r\n:2\r\n:6\r\n:7\r\n"},
+		{"LPOS with a MAXLEN", []interface{}{"LPOS", "p", "c", "COUNT", "0", "MAXLEN", "3"}, "*1\r\n:2\r\n"},
+		{"LPOS without a match", []interface{}{"LPOS", "p", "z"}, "$-1\r\n"},
+		{"LPOS with a RANK of 0", []interface{}{"LPOS", "p", "c", "RANK", "0"}, "-ERR RANK can't be zero: use 1 to start from the first match, 2 from the second ... or use negative to start from the last match\r\n"},
+		{"LPOS with a negative COUNT", []interface{}{"LPOS", "p", "c", "COUNT", "-1"}, "-ERR COUNT can't be negative\r\n"},
+		{"RPUSH for LMOVE", []interface{}{"RPUSH", "src", "one", "two"}, ":2\r\n"},
+		{"LMOVE", []interface{}{"LMOVE", "src", "dst", "RIGHT", "LEFT"}, "$3\r\ntwo\r\n"},
+		{"LMOVE rotating a list", []interface{}{"LMOVE", "p", "p", "LEFT", "RIGHT"}, "$1\r\na\r\n"},
+		{"LINDEX after the rotation", []interface{}{"LINDEX", "p", "-1"}, "$1\r\na\r\n"},
+		{"LMOVE of the last element", []interface{}{"LMOVE", "src", "dst", "LEFT", "LEFT"}, "$3\r\none\r\n"},
+		{"LRANGE of the destination", []interface{}{"LRANGE", "dst", "0", "-1"}, "*2\r\n$3\r\none\r\n$3\r\ntwo\r\n"},
+		{"EXISTS of the emptied source", []interface{}{"EXISTS", "src"}, ":0\r\n"},
+		{"LMOVE of a missing key", []interface{}{"LMOVE", "src", "dst", "LEFT", "LEFT"}, "$-1\r\n"},
+		{"LMOVE with a syntax error", []interface{}{"LMOVE", "dst", "src", "UP", "LEFT"}, "-ERR syntax error\r\n"},
+	})
+}
+
+func TestWrongType(t *testing.T) {
+	cache := keyspace.New()
+	wrongType := "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+
+	runSteps(t, cache, []step{
+		{"SET a string", []interface{}{"SET", "s", "v"}, "+OK\r\n"},
+		{"RPUSH a list", []interface{}{"RPUSH", "l", "a"}, ":1\r\n"},
+		{"GET of a list", []interface{}{"GET", "l"}, wrongType},
+		{"SET GET of a list", []interface{}{"SET", "l", "v", "GET"}, wrongType},
+		{"INCR of a list", []interface{}{"INCR", "l"}, wrongType},
+		{"APPEND to a list", []interface{}{"APPEND", "l", "x"}, wrongType},
+		{"STRLEN of a list", []interface{}{"STRLEN", "l"}, wrongType},
+		{"GETRANGE of a list", []interface{}{"GETRANGE", "l", "0", "-1"}, wrongType},
+		{"GETDEL of a list", []interface{}{"GETDEL", "l"}, wrongType},
+		{"MGET replies lists as missing", []interface{}{"MGET", "s", "l"}, "*2\r\n$1\r\nv\r\n$-1\r\n"},
+		{"LPUSH to a string", []interface{}{"LPUSH", "s", "a"}, wrongType},
+		{"LRANGE of a string", []interface{}{"LRANGE", "s", "0", "-1"}, wrongType},
+		{"LMOVE to a string", []interface{}{"LMOVE", "l", "s", "LEFT", "LEFT"}, wrongType},
+		{"LLEN after the failed LMOVE", []interface{}{"LLEN", "l"}, ":1\r\n"},
+		{"COPY a list", []interface{}{"COPY", "l", "c"}, ":1\r\n"},
+		{"RPUSH to the copy", []interface{}{"RPUSH", "c", "b"}, ":2\r\n"},
+		{"LLEN of the copied list", []interface{}{"LLEN", "l"}, ":1\r\n"},
+		{"SET over a list", []interface{}{"SET", "l", "v"}, "+OK\r\n"},
+		{"TYPE after SET over a list", []interface{}{"TYPE", "l"}, "+string\r\n"},
+	})
+}
+
+func TestHashCommands(t *testing.T) {
+	cache := keyspace.New()
+
+	runSteps(t, cache, []step{
+		{"HSET to a missing key", []interface{}{"HSET", "h", "name", "Ada", "age", "36"}, ":2\r\n"},
+		{"HSET of an existing field", []interface{}{"HSET", "h", "name", "Grace", "lang", "COBOL"}, ":1\r\n"},
+		{"HSET with a missing value", []interface{}{"HSET", "h", "name"}, "-ERR wrong number of arguments for 'HSET' command\r\n"},
+		{"hset with a missing value", []interface{}{"hset", "h", "name"}, "-ERR wrong number of arguments for 'HSET' command\r\n"},
+		{"HGET", []interface{}{"HGET", "h", "name"}, "$5\r\nGrace\r\n"},
+		{"HGET of a missing field", []interface{}{"HGET", "h", "missing"}, "$-1\r\n"},
+		{"HGET of a missing key", []interface{}{"HGET", "missing", "name"}, "$-1\r\n"},
+		{"HMGET", []interface{}{"HMGET", "h", "name", "missing", "age"}, "*3\r\n$5\r\nGrace\r\n$-1\r\n$2\r\n36\r\n"},
+		{"HMGET of a missing key", []interface{}{"HMGET", "missing", "name"}, "*1\r\n$-1\r\n"},
+		{"HGETALL", []interface{}{"HGETALL", "h"}, "*6\r\n$4\r\nname\r\n$5\r\nGrace\r\n$3\r\nage\r\n$2\r\n36\r\n$4\r\nlang\r\n$5\r\nCOBOL\r\n"},
+		{"HKEYS", []interface{}{"HKEYS", "h"}, "*3\r\n$4\r\nname\r\n$3\r\nage\r\n$4\r\nlang\r\n"},
+		{"HVALS", []interface{}{"HVALS", "h"}, "*3\r\n$5\r\nGrace\r\n$2\r\n36\r\n$5\r\nCOBOL\r\n"},
+		{"HGETALL of a missing key", []interface{}{"HGETALL", "missing"}, "*0\r\n"},
+		{"HLEN", []interface{}{"HLEN", "h"}, ":3\r\n"},
+		{"HEXISTS", []interface{}{"HEXISTS", "h", "age"}, ":1\r\n"},
+		{"HEXISTS of a missing field", []interface{}{"HEXISTS", "h", "missing"}, ":0\r\n"},
+		{"TYPE of a hash", []interface{}{"TYPE", "h"}, "+hash\r\n"},
+		{"HSETNX of an existing field", []interface{}{"HSETNX", "h", "name", "x"}, ":0\r\n"},
+		{"HSETNX", []interface{}{"HSETNX", "h", "city", "Arlington"}, ":1\r\n"},
+		{"HINCRBY", []interface{}{"HINCRBY", "h", "age", "4"}, ":40\r\n"},
+		{"HINCRBY of a missing field", []interface{}{"HINCRBY", "h", "visits", "-2"}, ":-2\r\n"},
+		{"HINCRBY of a non-integer field", []interface{}{"HINCRBY", "h", "name", "1"}, "-ERR hash value is not an integer\r\n"},
+		{"HINCRBY overflowing", []interface{}{"HINCRBY", "h", "age", "9223372036854775807"}, "-ERR increment or decrement would overflow\r\n"},
+		{"HINCRBYFLOAT", []interface{}{"HINCRBYFLOAT", "h", "score", "10.5"}, "$4\r\n10.5\r\n"},
+		{"HINCRBYFLOAT of an integer field", []interface{}{"HINCRBYFLOAT", "h", "age", "0.25"}, "$5\r\n40.25\r\n"},
+		{"HINCRBYFLOAT of a non-float field", []interface{}{"HINCRBYFLOAT", "h", "name", "1"}, "-ERR hash value is not a float\r\n"},
+		{"HINCRBY creating a hash", []interface{}{"HINCRBY", "counters", "a", "1"}, ":1\r\n"},
+		{"HDEL", []interface{}{"HDEL", "h", "name", "missing", "lang"}, ":2\r\n"},
+		{"HLEN after HDEL", []interface{}{"HLEN", "h"}, ":4\r\n"},
+		{"HDEL of the last field", []interface{}{"HDEL", "counters", "a"}, ":1\r\n"},
+		{"EXISTS after deleting the last field", []interface{}{"EXISTS", "counters"}, ":0\r\n"},
+		{"HDEL of a missing key", []interface{}{"HDEL", "counters", "a"}, ":0\r\n"},
+		{"HRANDFIELD of a missing key", []interface{}{"HRANDFIELD", "missing"}, "$-1\r\n"},
+		{"HRANDFIELD with a count of a missing key", []interface{}{"HRANDFIELD", "missing", "3"}, "*0\r\n"},
+		{"HSET for HRANDFIELD", []interface{}{"HSET", "one", "f", "v"}, ":1\r\n"},
+		{"HRANDFIELD", []interface{}{"HRANDFIELD", "one"}, "$1\r\nf\r\n"},
+		{"HRANDFIELD with a count larger than the hash", []interface{}{"HRANDFIELD", "one", "5", "WITHVALUES"}, "*2\r\n$1\r\nf\r\n$1\r\nv\r\n"},
+		{"HRANDFIELD with a negative count", []interface{}{"HRANDFIELD", "one", "-3"}, "*3\r\n$1\r\nf\r\n$1\r\nf\r\n$1\r\nf\r\n"},
+		{"HRANDFIELD with a syntax error", []interface{}{"HRANDFIELD", "one", "1", "WITHSCORES"}, "-ERR syntax error\r\n"},
+		{"HRANDFIELD with a huge negative count", []interface{}{"HRANDFIELD", "one", "-9223372036854775807"}, "-ERR value is out of range\r\n"},
+		{"HRANDFIELD with a huge negative count and values", []interface{}{"HRANDFIELD", "one", "-9223372036854775807", "WITHVALUES"}, "-ERR value is out of range\r\n"},
+		{"SET a string", []interface{}{"SET", "s", "v"}, "+OK\r\n"},
+		{"HSET of a string", []interface{}{"HSET", "s", "f", "v"}, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{"GET of a hash", []interface{}{"GET", "h"}, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+	})
+
+	// Distinct random fields of a hash large enough to leave its compact encoding
+	for i := 0; i < 200; i++ {
+		handler.HandleCommands([]interface{}{"HSET", "big", "field:" + strconv.Itoa(i), strconv.Itoa(i)}, types.RESPTypeArray, cache)
+	}
+	for _, count := range []int{50, 150} {
+		fields := replyStrings(t, handler.HandleCommands([]interface{}{"HRANDFIELD", "big", strconv.Itoa(count)}, types.RESPTypeArray, cache))
+		for i := 1; i < len(fields); i++ {
+			if fields[i] == fields[i-1] {
+				t.Errorf("HRANDFIELD with a positive count replied %q twice", fields[i])
+			}
+		}
+		if len(fields) != count {
+			t.Errorf("HRANDFIELD replied %d fields, want %d", len(fields), count)
+		}
+	}
+	runSteps(t, cache, []step{
+		{"HLEN of a large hash", []interface{}{"HLEN", "big"}, ":200\r\n"},
+		{"HGET of a large hash", []interface{}{"HGET", "big", "field:150"}, "$3\r\n150\r\n"},
+	})
+}
+
+func TestSortedSetCommands(t *testing.T) {
+	cache := keyspace.New()
+
+	runSteps(t, cache, []step{
+		{"ZADD to a missing key", []interface{}{"ZADD", "z", "1", "one", "2", "two", "3", "three"}, ":3\r\n"},
+		{"ZADD of an existing member", []interface{}{"ZADD", "z", "1", "one", "4", "four"}, ":1\r\n"},
+		{"ZADD with CH", []interface{}{"ZADD", "z", "CH", "1.5", "one", "5", "five"}, ":2\r\n"},
+		{"ZADD NX", []interface{}{"ZADD", "z", "NX", "10", "one", "6", "six"}, ":1\r\n"},
+		{"ZADD XX", []interface{}{"ZADD", "z", "XX", "CH", "1", "one", "7", "seven"}, ":1\r\n"},
+		{"ZADD GT lower", []interface{}{"ZADD", "z", "GT", "CH", "0", "one"}, ":0\r\n"},
+		{"ZADD LT lower", []interface{}{"ZADD", "z", "LT", "CH", "0.5", "one"}, ":1\r\n"},
+		{"ZADD INCR", []interface{}{"ZADD", "z", "INCR", "0.5", "one"}, "$1\r\n1\r\n"},
+		{"ZADD INCR skipped by NX", []interface{}{"ZADD", "z", "NX", "INCR", "1", "one"}, "$-1\r\n"},
+		{"ZADD with NX and XX", []interface{}{"ZADD", "z", "NX", "XX", "1", "one"}, "-ERR XX and NX options at the same time are not compatible\r\n"},
+		{"ZADD with GT and LT", []interface{}{"ZADD", "z", "GT", "LT", "1", "one"}, "-ERR GT, LT, and/or NX options at the same time are not compatible\r\n"},
+		{"ZADD INCR with several pairs", []interface{}{"ZADD", "z", "INCR", "1", "one", "2", "two"}, "-ERR INCR option supports a single increment-element pair\r\n"},
+		{"ZADD with an invalid score", []interface{}{"ZADD", "z", "x", "one"}, "-ERR value is not a valid float\r\n"},
+		{"ZADD with a missing member", []interface{}{"ZADD", "z", "1", "one", "2"}, "-ERR syntax error\r\n"},
+		{"ZCARD", []interface{}{"ZCARD", "z"}, ":6\r\n"},
+		{"TYPE of a sorted set", []interface{}{"TYPE", "z"}, "+zset\r\n"},
+		{"ZSCORE", []interface{}{"ZSCORE", "z", "one"}, "$1\r\n1\r\n"},
+		{"ZSCORE of a missing member", []interface{}{"ZSCORE", "z", "missing"}, "$-1\r\n"},
+		{"ZRANGE", []interface{}{"ZRANGE", "z", "0", "2"}, "*3\r\n$3\r\none\r\n$3\r\ntwo\r\n$5\r\nthree\r\n"},
+		{"ZRANGE WITHSCORES", []interface{}{"ZRANGE", "z", "-2", "-1", "WITHSCORES"}, "*4\r\n$4\r\nfive\r\n$1\r\n5\r\n$3\r\nsix\r\n$1\r\n6\r\n"},
+		{"ZRANGE REV", []interface{}{"ZRANGE", "z", "0", "1", "REV"}, "*2\r\n$3\r\nsix\r\n$4\r\nfive\r\n"},
+		{"ZRANGE BYSCORE", []interface{}{"ZRANGE", "z", "(2", "5", "BYSCORE"}, "*3\r\n$5\r\nthree\r\n$4\r\nfour\r\n$4\r\nfive\r\n"},
+		{"ZRANGE BYSCORE with infinities", []interface{}{"ZRANGE", "z", "-inf", "+inf", "BYSCORE", "LIMIT", "1", "2"}, "*2\r\n$3\r\ntwo\r\n$5\r\nthree\r\n"},
+		{"ZRANGE BYSCORE REV", []interface{}{"ZRANGE", "z", "5", "(2", "BYSCORE", "REV", "LIMIT", "0", "2"}, "*2\r\n$4\r\nfive\r\n$4\r\nfour\r\n"},
+		{"ZRANGE BYSCORE with a negative offset", []interface{}{"ZRANGE", "z", "-inf", "+inf", "BYSCORE", "LIMIT", "-1", "2"}, "*0\r\n"},
+		{"ZRANGE BYSCORE with an invalid bound", []interface{}{"ZRANGE", "z", "x", "5", "BYSCORE"}, "-ERR min or max is not a float\r\n"},
+		{"ZRANGE LIMIT by rank", []interface{}{"ZRANGE", "z", "0", "1", "LIMIT", "0", "1"}, "-ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX\r\n"},
+		{"ZRANGE of a missing key", []interface{}{"ZRANGE", "missing", "0", "-1"}, "*0\r\n"},
+		{"ZADD members of equal scores", []interface{}{"ZADD", "lex", "0", "a", "0", "b", "0", "c", "0", "d"}, ":4\r\n"},
+		{"ZRANGE BYLEX", []interface{}{"ZRANGE", "lex", "[b", "+", "BYLEX"}, "*3\r\n$1\r\nb\r\n$1\r\nc\r\n$1\r\nd\r\n"},
+		{"ZRANGE BYLEX REV", []interface{}{"ZRANGE", "lex", "(c", "-", "BYLEX", "REV"}, "*2\r\n$1\r\nb\r\n$1\r\na\r\n"},
+		{"ZRANGE BYLEX with an invalid bound", []interface{}{"ZRANGE", "lex", "b", "+", "BYLEX"}, "-ERR min or max not valid string range item\r\n"},
+		{"ZRANGE BYLEX WITHSCORES", []interface{}{"ZRANGE", "lex", "-", "+", "BYLEX", "WITHSCORES"}, "-ERR syntax error, WITHSCORES not supported in combination with BYLEX\r\n"},
+		{"ZRANK", []interface{}{"ZRANK", "z", "three"}, ":2\r\n"},
+		{"ZRANK WITHSCORE", []interface{}{"ZRANK", "z", "three", "WITHSCORE"}, "*2\r\n:2\r\n$1\r\n3\r\n"},
+		{"ZREVRANK", []interface{}{"ZREVRANK", "z", "three"}, ":3\r\n"},
+		{"ZRANK of a missing member", []interface{}{"ZRANK", "z", "missing"}, "$-1\r\n"},
+		{"ZCOUNT", []interface{}{"ZCOUNT", "z", "(1", "4"}, ":3\r\n"},
+		{"ZCOUNT of an empty range", []interface{}{"ZCOUNT", "z", "10", "+inf"}, ":0\r\n"},
+		{"ZINCRBY", []interface{}{"ZINCRBY", "z", "2.5", "one"}, "$3\r\n3.5\r\n"},
+		{"ZINCRBY of a missing member", []interface{}{"ZINCRBY", "z", "-1", "zero"}, "$2\r\n-1\r\n"},
+		{"ZINCRBY to infinity", []interface{}{"ZINCRBY", "inf", "+inf", "m"}, "$3\r\ninf\r\n"},
+		{"ZINCRBY producing NaN", []interface{}{"ZINCRBY", "inf", "-inf", "m"}, "-ERR resulting score is not a number (NaN)\r\n"},
+		{"ZPOPMIN", []interface{}{"ZPOPMIN", "z"}, "*2\r\n$4\r\nzero\r\n$2\r\n-1\r\n"},
+		{"ZPOPMAX with a count", []interface{}{"ZPOPMAX", "z", "2"}, "*4\r\n$3\r\nsix\r\n$1\r\n6\r\n$4\r\nfive\r\n$1\r\n5\r\n"},
+		{"ZPOPMIN with a negative count", []interface{}{"ZPOPMIN", "z", "-1"}, "-ERR value is out of range, must be positive\r\n"},
+		{"ZREM", []interface{}{"ZREM", "z", "two", "missing"}, ":1\r\n"},
+		{"ZRANGE after ZREM", []interface{}{"ZRANGE", "z", "0", "-1", "WITHSCORES"}, "*6\r\n$5\r\nthree\r\n$1\r\n3\r\n$3\r\none\r\n$3\r\n3.5\r\n$4\r\nfour\r\n$1\r\n4\r\n"},
+		{"ZREM of the last member", []interface{}{"ZREM", "inf", "m"}, ":1\r\n"},
+		{"EXISTS after removing the last member", []interface{}{"EXISTS", "inf"}, ":0\r\n"},
+		{"SET a string", []interface{}{"SET", "s", "v"}, "+OK\r\n"},
+		{"ZADD to a string", []interface{}{"ZADD", "s", "1", "m"}, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+	})
+}
+
+func TestSortedSetStore(t *testing.T) {
+	cache := keyspace.New()
+
+	runSteps(t, cache, []step{
+		{"ZADD the first set", []interface{}{"ZADD", "a", "1", "x", "2", "y"}, ":2\r\n"},
+		{"ZADD the second set", []interface{}{"ZADD", "b", "10", "y", "20", "z"}, ":2\r\n"},
+		{"ZUNIONSTORE", []interface{}{"ZUNIONSTORE", "u", "2", "a", "b"}, ":3\r\n"},
+		{"ZRANGE of the union", []interface{}{"ZRANGE", "u", "0", "-1", "WITHSCORES"}, "*6\r\n$1\r\nx\r\n$1\r\n1\r\n$1\r\ny\r\n$2\r\n12\r\n$1\r\nz\r\n$2\r\n20\r\n"},
+		{"ZUNIONSTORE with WEIGHTS and AGGREGATE", []interface{}{"ZUNIONSTORE", "u", "2", "a", "b", "WEIGHTS", "2", "0.5", "AGGREGATE", "MAX"}, ":3\r\n"},
+		{"ZRANGE of the weighted union", []interface{}{"ZRANGE", "u", "0", "-1", "WITHSCORES"}, "*6\r\n$1\r\nx\r\n$1\r\n2\r\n$1\r\ny\r\n$1\r\n5\r\n$1\r\nz\r\n$2\r\n10\r\n"},
+		{"ZINTERSTORE", []interface{}{"ZINTERSTORE", "i", "2", "a", "b", "AGGREGATE", "MIN"}, ":1\r\n"},
+		{"ZRANGE of the intersection", []interface{}{"ZRANGE", "i", "0", "-1", "WITHSCORES"}, "*2\r\n$1\r\ny\r\n$1\r\n2\r\n"},
+		{"ZINTERSTORE with a missing key", []interface{}{"ZINTERSTORE", "i", "2", "a", "missing"}, ":0\r\n"},
+		{"EXISTS of an empty intersection", []interface{}{"EXISTS", "i"}, ":0\r\n"},
+		{"ZUNIONSTORE into a source", []interface{}{"ZUNIONSTORE", "a", "2", "a", "b"}, ":3\r\n"},
+		{"ZUNIONSTORE without keys", []interface{}{"ZUNIONSTORE", "u", "0", "a"}, "-ERR at least 1 input key is needed for 'zunionstore' command\r\n"},
+		{"ZUNIONSTORE with too few keys", []interface{}{"ZUNIONSTORE", "u", "3", "a", "b"}, "-ERR syntax error\r\n"},
+		{"ZUNIONSTORE with an invalid weight", []interface{}{"ZUNIONSTORE", "u", "1", "a", "WEIGHTS", "x"}, "-ERR weight value is not a float\r\n"},
+		{"ZUNIONSTORE with an invalid aggregate", []interface{}{"ZUNIONSTORE", "u", "1", "a", "AGGREGATE", "AVG"}, "-ERR syntax error\r\n"},
+	})
+}
+
+func TestSetCommands(t *testing.T) {
+	cache := keyspace.New()
+
+	runSteps(t, cache, []step{
+		{"SADD to a missing key", []interface{}{"SADD", "s", "3", "1", "2", "1"}, ":3\r\n"},
+		{"SMEMBERS of an intset", []interface{}{"SMEMBERS", "s"}, "*3\r\n$1\r\n1\r\n$1\r\n2\r\n$1\r\n3\r\n"},
+		{"SADD of existing members", []interface{}{"SADD", "s", "2", "3", "4"}, ":1\r\n"},
+		{"SCARD", []interface{}{"SCARD", "s"}, ":4\r\n"},
+		{"SCARD of a missing key", []interface{}{"SCARD", "missing"}, ":0\r\n"},
+		{"TYPE of a set", []interface{}{"TYPE", "s"}, "+set\r\n"},
+		{"SISMEMBER", []interface{}{"SISMEMBER", "s", "2"}, ":1\r\n"},
+		{"SISMEMBER of a missing member", []interface{}{"SISMEMBER", "s", "02"}, ":0\r\n"},
+		{"SMISMEMBER", []interface{}{"SMISMEMBER", "s", "1", "5", "4"}, "*3\r\n:1\r\n:0\r\n:1\r\n"},
+		{"SMISMEMBER of a missing key", []interface{}{"SMISMEMBER", "missing", "1"}, "*1\r\n:0\r\n"},
+		{"SREM", []interface{}{"SREM", "s", "4", "5"}, ":1\r\n"},
+		{"SADD of a string member", []interface{}{"SADD", "s", "a"}, ":1\r\n"},
+		{"SRANDMEMBER of a missing key", []interface{}{"SRANDMEMBER", "missing"}, "$-1\r\n"},
+		{"SRANDMEMBER with a count of a missing key", []interface{}{"SRANDMEMBER", "missing", "2"}, "*0\r\n"},
+		{"SPOP of a missing key", []interface{}{"SPOP", "missing"}, "$-1\r\n"},
+		{"SPOP with a negative count", []interface{}{"SPOP", "s", "-1"}, "-ERR value is out of range, must be positive\r\n"},
+		{"SADD a single member", []interface{}{"SADD", "one", "m"}, ":1\r\n"},
+		{"SRANDMEMBER", []interface{}{"SRANDMEMBER", "one"}, "$1\r\nm\r\n"},
+		{"SRANDMEMBER with a negative count", []interface{}{"SRANDMEMBER", "one", "-2"}, "*2\r\n$1\r\nm\r\n$1\r\nm\r\n"},
+		{"SRANDMEMBER with a count larger than the set", []interface{}{"SRANDMEMBER", "one", "5"}, "*1\r\n$1\r\nm\r\n"},
+		{"SRANDMEMBER with a huge negative count", []interface{}{"SRANDMEMBER", "one", "-9223372036854775807"}, "-ERR value is out of range\r\n"},
+		{"SPOP", []interface{}{"SPOP", "one"}, "$1\r\nm\r\n"},
+		{"EXISTS after popping the last member", []interface{}{"EXISTS", "one"}, ":0\r\n"},
+		{"SET a string", []interface{}{"SET", "str", "v"}, "+OK\r\n"},
+		{"SADD to a string", []interface{}{"SADD", "str", "m"}, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{"SINTER with a string", []interface{}{"SINTER", "s", "str"}, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+	})
+
+	members := replyStrings(t, handler.HandleCommands([]interface{}{"SPOP", "s", "10"}, types.RESPTypeArray, cache))
+	if !reflect.DeepEqual(members, []string{"1", "2", "3", "a"}) {
+		t.Errorf("SPOP with a count replied %v", members)
+	}
+	runSteps(t, cache, []step{
+		{"EXISTS after popping every member", []interface{}{"EXISTS", "s"}, ":0\r\n"},
+	})
+
+	// Distinct random members of a set large enough to leave its intset encoding
+	for i := 0; i < 200; i++ {
+		handler.HandleCommands([]interface{}{"SADD", "big", "member:" + strconv.Itoa(i)}, types.RESPTypeArray, cache)
+	}
+	for _, count := range []int{20, 150} {
+		members := replyStrings(t, handler.HandleCommands([]interface{}{"SRANDMEMBER", "big", strconv.Itoa(count)}, types.RESPTypeArray, cache))
+		for i := 1; i < len(members); i++ {
+			if members[i] == members[i-1] {
+				t.Errorf("SRANDMEMBER with a positive count replied %q twice", members[i])
+			}
+		}
+		if len(members) != count {
+			t.Errorf("SRANDMEMBER replied %d members, want %d", len(members), count)
+		}
+	}
+	popped := replyStrings(t, handler.HandleCommands([]interface{}{"SPOP", "big", "20"}, types.RESPTypeArray, cache))
+	for _, member := range popped {
+		if string(handler.HandleCommands([]interface{}{"SISMEMBER", "big", member}, types.RESPTypeArray, cache)) != ":0\r\n" {
+			t.Errorf("SPOP replied %q but left it in the set", member)
+		}
+	}
+	runSteps(t, cache, []step{
+		{"SCARD after popping from a large set", []interface{}{"SCARD", "big"}, ":180\r\n"},
+	})
+}
+
+func TestSetAlgebra(t *testing.T) {
+	cache := keyspace.New()
+	handler.HandleCommands([]interface{}{"SADD", "a", "x", "y", "z", "1"}, types.RESPTypeArray, cache)
+	handler.HandleCommands([]interface{}{"SADD", "b", "y", "z", "w"}, types.RESPTypeArray, cache)
+	handler.HandleCommands([]interface{}{"SADD", "c", "z", "1"}, types.RESPTypeArray, cache)
+
+	commands := []struct {
+		command  []interface{}
+		expected []string
+	}{
+		{[]interface{}{"SINTER", "a", "b"}, []string{"y", "z"}},
+		{[]interface{}{"SINTER", "a", "b", "c"}, []string{"z"}},
+		{[]interface{}{"SINTER", "a", "missing"}, []string{}},
+		{[]interface{}{"SUNION", "a", "b", "missing"}, []string{"1", "w", "x", "y", "z"}},
+		{[]interface{}{"SDIFF", "a", "b"}, []string{"1", "x"}},
+		{[]interface{}{"SDIFF", "a", "b", "c"}, []string{"x"}},
+		{[]interface{}{"SDIFF", "missing", "a"}, []string{}},
+	}
+	for _, c := range commands {
+		got := replyStrings(t, handler.HandleCommands(c.command, types.RESPTypeArray, cache))
+		if !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("%v replied %v, want %v", c.command, got, c.expected)
+		}
+	}
+
+	runSteps(t, cache, []step{
+		{"SINTERSTORE", []interface{}{"SINTERSTORE", "dst", "a", "b"}, ":2\r\n"},
+		{"SUNIONSTORE", []interface{}{"SUNIONSTORE", "dst", "dst", "c"}, ":3\r\n"},
+		{"SDIFFSTORE", []interface{}{"SDIFFSTORE", "dst", "a", "b"}, ":2\r\n"},
+		{"SDIFFSTORE of an empty difference", []interface{}{"SDIFFSTORE", "dst", "c", "a"}, ":0\r\n"},
+		{"EXISTS of an empty result", []interface{}{"EXISTS", "dst"}, ":0\r\n"},
+		{"SINTERCARD", []interface{}{"SINTERCARD", "2", "a", "b"}, ":2\r\n"},
+		{"SINTERCARD with a LIMIT", []interface{}{"SINTERCARD", "2", "a", "b", "LIMIT", "1"}, ":1\r\n"},
+		{"SINTERCARD without keys", []interface{}{"SINTERCARD", "0", "a"}, "-ERR numkeys should be greater than 0\r\n"},
+		{"SINTERCARD with too few keys", []interface{}{"SINTERCARD", "3", "a", "b"}, "-ERR Number of keys can't be greater than number of args\r\n"},
+		{"SINTERCARD with a negative LIMIT", []interface{}{"SINTERCARD", "1", "a", "LIMIT", "-1"}, "-ERR LIMIT can't be negative\r\n"},
+		{"SMOVE", []interface{}{"SMOVE", "a", "b", "x"}, ":1\r\n"},
+		{"SMOVE of a missing member", []interface{}{"SMOVE", "a", "b", "x"}, ":0\r\n"},
+		{"SISMEMBER after SMOVE", []interface{}{"SISMEMBER", "b", "x"}, ":1\r\n"},
+		{"SMOVE of the last member", []interface{}{"SMOVE", "c", "new", "1"}, ":1\r\n"},
+		{"SMOVE of the last member again", []interface{}{"SMOVE", "c", "new", "z"}, ":1\r\n"},
+		{"EXISTS of the emptied source", []interface{}{"EXISTS", "c"}, ":0\r\n"},
+		{"SCARD of the destination", []interface{}{"SCARD", "new"}, ":2\r\n"},
+		{"ZUNIONSTORE of sets", []interface{}{"ZUNIONSTORE", "z", "2", "a", "new", "WEIGHTS", "1", "2"}, ":3\r\n"},
+		{"ZSCORE of a member of both sets", []interface{}{"ZSCORE", "z", "z"}, "$1\r\n3\r\n"},
+	})
+}
+
+// waitBackgroundSave waits for the running background save to complete.
+func waitBackgroundSave(t *testing.T) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for persistence.Status().BgsaveInProgress {
+		if time.Now().After(deadline) {
+			t.Fatalf("the background save did not complete")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBackgroundSave(t *testing.T) {
+	cache := keyspace.New()
+	useTempDir(t)
+	handler.HandleCommands([]interface{}{"SET", "k", "v"}, types.RESPTypeArray, cache)
+	before := persistence.Status().Saves
+
+	runSteps(t, cache, []step{
+		{"BGSAVE starts a background save", []interface{}{"BGSAVE"}, "+Background saving started\r\n"},
+	})
+	waitBackgroundSave(t)
+
+	status := persistence.Status()
+	if status.Saves != before+1 || status.LastBgsaveErr != nil {
+		t.Fatalf("after BGSAVE: %d saves and error %v, want %d saves and no error", status.Saves, status.LastBgsaveErr, before+1)
+	}
+	runSteps(t, cache, []step{
+		{"LASTSAVE after BGSAVE", []interface{}{"LASTSAVE"}, fmt.Sprintf(":%d\r\n", status.LastSave.Unix())},
+	})
+
+	info := string(handler.HandleCommands([]interface{}{"INFO", "persistence"}, types.RESPTypeArray, cache))
+	for _, field := range []string{"# Persistence\r\n", "rdb_bgsave_in_progress:0\r\n", "rdb_last_bgsave_status:ok\r\n",
+		fmt.Sprintf("rdb_saves:%d\r\n", status.Saves)} {
+		if !strings.Contains(info, field) {
+			t.Errorf("INFO persistence = %q, want it to contain %q", info, field)
+		}
+	}
+	runSteps(t, cache, []step{
+		{"INFO of an unknown section", []interface{}{"INFO", "nosuchsection"}, "$0\r\n\r\n"},
+	})
+
+	loaded := keyspace.New()
+	if _, err := persistence.Load(config.RDBPath(), loaded); err != nil {
+		t.Fatalf("failed to load the file written by BGSAVE: %v", err)
+	}
+	runSteps(t, loaded, []step{
+		{"GET of a key saved by BGSAVE", []interface{}{"GET", "k"}, "$1\r\nv\r\n"},
+	})
+}
+
+func TestSaveRules(t *testing.T) {
+	defer persistence.SetSaveRules(persistence.DefaultSaveRules)
+	cache := keyspace.New()
+
+	runSteps(t, cache, []step{
+		{"CONFIG GET save of the default rules", []interface{}{"CONFIG", "GET", "save"}, "*2\r\n$4\r\nsave\r\n$23\r\n3600 1 300 100 60 10000\r\n"},
+		{"CONFIG SET save", []interface{}{"CONFIG", "SET", "save", "900 1 300 10"}, "+OK\r\n"},
+		{"CONFIG GET save after CONFIG SET", []interface{}{"CONFIG", "GET", "save"}, "*2\r\n$4\r\nsave\r\n$12\r\n900 1 300 10\r\n"},
+		{"CONFIG SET save with a missing number of changes", []interface{}{"CONFIG", "SET", "save", "900"},
+			"-ERR CONFIG SET failed (possibly related to argument 'save') - invalid save parameters \"900\"\r\n"},
+	})
+
+	runSteps(t, cache, []step{
+		{"CONFIG SET SAVE", []interface{}{"config", "set", "SAVE", "60 5"}, "+OK\r\n"},
+		{"CONFIG GET SAVE", []interface{}{"config", "get", "SAVE"}, "*2\r\n$4\r\nsave\r\n$4\r\n60 5\r\n"},
+	})
+
+	// Writes count the keys they modify, reads, failed writes and writes that leave the keyspace unchanged do not
+	changes := []struct {
+		name    string
+		command []interface{}
+		want    int64
+	}{
+		{"SET", []interface{}{"SET", "k", "v"}, 1},
+		{"GET", []interface{}{"GET", "k"}, 0},
+		{"a failed LPUSH", []interface{}{"LPUSH", "k", "a"}, 0},
+		{"MSET of 3 keys", []interface{}{"MSET", "a", "1", "b", "2", "c", "3"}, 3},
+		{"DEL of 2 existing keys and a missing one", []interface{}{"DEL", "a", "b", "missing"}, 2},
+		{"DEL of a missing key", []interface{}{"DEL", "missing"}, 0},
+		{"SETNX of an existing key", []interface{}{"SETNX", "k", "v"}, 0},
+		{"SADD of 2 new members", []interface{}{"SADD", "set", "x", "y"}, 2},
+		{"SADD of existing members", []interface{}{"SADD", "set", "x", "y"}, 0},
+		{"SREM of a missing member", []interface{}{"SREM", "set", "z"}, 0},
+		{"EXPIRE of a missing key", []interface{}{"EXPIRE", "missing", "100"}, 0},
+		{"PERSIST of a key without expiration", []interface{}{"PERSIST", "k"}, 0},
+		{"LPOP of a missing list", []interface{}{"LPOP", "missing"}, 0},
+		{"SINTERSTORE of an empty result into a missing key", []interface{}{"SINTERSTORE", "dst", "missing"}, 0},
+	}
+	for _, tt := range changes {
+		before := persistence.Status().Changes
+		handler.HandleCommands(tt.command, types.RESPTypeArray, cache)
+		if got := persistence.Status().Changes - before; got != tt.want {
+			t.Errorf("%s: %d changes counted, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAppendOnlyPropagation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	aof, err := persistence.OpenAOF(path, persistence.FsyncAlways, keyspace.New())
+	if err != nil {
+		t.Fatalf("OpenAOF() failed: %v", err)
+	}
+	persistence.EnableAOF(aof)
+	defer persistence.EnableAOF(nil)
+
+	cache := keyspace.New()
+	before := time.Now().UnixMilli()
+	runSteps(t, cache, []step{
+		{"SET with a relative expiration", []interface{}{"SET", "s", "v", "EX", "100"}, "+OK\r\n"},
+		{"SETEX", []interface{}{"SETEX", "e", "100", "v"}, "+OK\r\n"},
+		{"SET with an expiration in the past", []interface{}{"SET", "p", "v", "EXAT", "1"}, "+OK\r\n"},
+		{"PSETEX that expires right away", []interface{}{"PSETEX", "q", "1", "v"}, "+OK\r\n"},
+		{"GET is not logged", []interface{}{"GET", "s"}, "$1\r\nv\r\n"},
+		{"SADD", []interface{}{"SADD", "set", "a"}, ":1\r\n"},
+		{"SPOP", []interface{}{"SPOP", "set"}, "$1\r\na\r\n"},
+		{"EXPIRE of a missing key is not logged", []interface{}{"EXPIRE", "missing", "100"}, ":0\r\n"},
+		{"EXPIRE in the past deletes the key", []interface{}{"EXPIRE", "e", "-1"}, ":1\r\n"},
+		{"GETEX PERSIST", []interface{}{"GETEX", "s", "PERSIST"}, "$1\r\nv\r\n"},
+		{"a failed write is not logged", []interface{}{"INCR", "s"}, "-ERR value is not an integer or out of range\r\n"},
+	})
+	aof.Close()
+
+	var logged [][]string
+	if _, err := persistence.LoadAOF(path, keyspace.New(), func(args []string) error {
+		logged = append(logged, args)
+		return nil
+	}); err != nil {
+		t.Fatalf("LoadAOF() failed: %v", err)
+	}
+
+	var names []string
+	for _, args := range logged {
+		names = append(names, strings.Join(args[:2], " "))
+	}
+	want := []string{"SET s", "SET e", "SET p", "SET q", "SADD set", "SREM set", "DEL e", "PERSIST s"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("logged %q, want %q", names, want)
+	}
+	for _, args := range logged[:2] {
+		if at, err := strconv.ParseInt(args[len(args)-1], 10, 64); len(args) != 5 || args[3] != "PXAT" || err != nil || at <= time.Now().UnixMilli() {
+			t.Errorf("the relative expiration was logged as %q, want SET with an absolute PXAT", args)
+		}
+	}
+	// The expiration is logged as the command computed it, although the key expired before it was logged
+	if want := []string{"SET", "p", "v", "PXAT", "1000"}; !reflect.DeepEqual(logged[2], want) {
+		t.Errorf("the past expiration was logged as %q, want %q", logged[2], want)
+	}
+	if at, err := strconv.ParseInt(logged[3][len(logged[3])-1], 10, 64); len(logged[3]) != 5 || logged[3][3] != "PXAT" || err != nil || at < before+1 {
+		t.Errorf("PSETEX was logged as %q, want SET with the absolute PXAT it computed", logged[3])
+	}
+
+	// Replaying the file reproduces the keyspace
+	replayed := keyspace.New()
+	for _, args := range logged {
+		if err := handler.Replay(args, replayed); err != nil {
+			t.Fatalf("Replay(%q) failed: %v", args, err)
+		}
+	}
+	runSteps(t, replayed, []step{
+		{"GET of the replayed key", []interface{}{"GET", "s"}, "$1\r\nv\r\n"},
+		{"TTL of the replayed key", []interface{}{"TTL", "s"}, ":-1\r\n"},
+		{"EXISTS of the deleted keys", []interface{}{"EXISTS", "e", "p", "q", "set"}, ":0\r\n"},
+	})
+	if err := handler.Replay([]string{"NOSUCHCOMMAND"}, replayed); err == nil {
+		t.Errorf("Replay() of an unknown command did not fail")
+	}
+}
+
+func TestAppendOnlyLoadingKeepsExpiredKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	aof, err := persistence.OpenAOF(path, persistence.FsyncAlways, keyspace.New())
+	if err != nil {
+		t.Fatalf("OpenAOF() failed: %v", err)
+	}
+	// The keys had not expired yet when the commands ran, but they have by the time the file is loaded
+	past := strconv.FormatInt(time.Now().Add(-time.Hour).UnixMilli(), 10)
+	if err := aof.Append([][]string{
+		{"SET", "k", "v", "PXAT", past},
+		{"APPEND", "k", "x"},
+		{"SET", "e", "v"},
+		{"PEXPIREAT", "e", past},
+		{"RPUSH", "e", "a"},
+	}); err != nil {
+		t.Fatalf("Append() failed: %v", err)
+	}
+	aof.Close()
+
+	cache := keyspace.New()
+	if _, err := persistence.LoadAOF(path, cache, func(args []string) error {
+		return handler.Replay(args, cache)
+	}); err != nil {
+		t.Fatalf("LoadAOF() failed: %v", err)
+	}
+	if cache.Loading() {
+		t.Errorf("the keyspace is still loading after LoadAOF()")
+	}
+
+	// The commands modified the keys before they expired, instead of creating new keys without an expiration
+	runSteps(t, cache, []step{
+		{"EXISTS of the expired keys", []interface{}{"EXISTS", "k", "e"}, ":0\r\n"},
+	})
+}
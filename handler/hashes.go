@@ -0,0 +1,513 @@
+package handler
+
+import (
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/datatypes"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/keyspace"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/persistence"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/types"
+)
+
+func init() {
+	register(
+		&command{
+			name: "HSET", arity: -4, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "hash", summary: "Creates or modifies the value of a field in a hash.", since: "2.0.0", complexity: "O(1) for each field/value pair added, so O(N) to add N field/value pairs when the command is called with multiple field/value pairs.",
+			handler: hsetCommand,
+		},
+		&command{
+			name: "HSETNX", arity: 4, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "hash", summary: "Sets the value of a field in a hash only when the field doesn't exist.", since: "2.0.0", complexity: "O(1)",
+			handler: hsetNXCommand,
+		},
+		&command{
+			name: "HGET", arity: 3, flags: flagReadonly | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "hash", summary: "Returns the value of a field in a hash.", since: "2.0.0", complexity: "O(1)",
+			handler: hgetCommand,
+		},
+		&command{
+			name: "HMGET", arity: -3, flags: flagReadonly | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "hash", summary: "Returns the values of all fields in a hash.", since: "2.0.0", complexity: "O(N) where N is the number of fields being requested.",
+			handler: hmgetCommand,
+		},
+		&command{
+			name: "HDEL", arity: -3, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "hash", summary: "Deletes one or more fields and their values from a hash. Deletes the hash if no fields remain.", since: "2.0.0", complexity: "O(N) where N is the number of fields to be removed.",
+			handler: hdelCommand,
+		},
+		&command{
+			name: "HGETALL", arity: 2, flags: flagReadonly, firstKey: 1, lastKey: 1, step: 1,
+			group: "hash", summary: "Returns all fields and values in a hash.", since: "2.0.0", complexity: "O(N) where N is the size of the hash.",
+			handler: hgetAllCommand,
+		},
+		&command{
+			name: "HKEYS", arity: 2, flags: flagReadonly, firstKey: 1, lastKey: 1, step: 1,
+			group: "hash", summary: "Returns all fields in a hash.", since: "2.0.0", complexity: "O(N) where N is the size of the hash.",
+			handler: hgetAllCommand,
+		},
+		&command{
+			name: "HVALS", arity: 2, flags: flagReadonly, firstKey: 1, lastKey: 1, step: 1,
+			group: "hash", summary: "Returns all values in a hash.", since: "2.0.0", complexity: "O(N) where N is the size of the hash.",
+			handler: hgetAllCommand,
+		},
+		&command{
+			name: "HLEN", arity: 2, flags: flagReadonly | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "hash", summary: "Returns the number of fields in a hash.", since: "2.0.0", complexity: "O(1)",
+			handler: hlenCommand,
+		},
+		&command{
+			name: "HEXISTS", arity: 3, flags: flagReadonly | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "hash", summary: "Determines whether a field exists in a hash.", since: "2.0.0", complexity: "O(1)",
+			handler: hexistsCommand,
+		},
+		&command{
+			name: "HINCRBY", arity: 4, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "hash", summary: "Increments the integer value of a field in a hash by a number. Uses 0 as initial value if the field doesn't exist.", since: "2.0.0", complexity: "O(1)",
+			handler: hincrByCommand,
+		},
+		&command{
+			name: "HINCRBYFLOAT", arity: 4, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "hash", summary: "Increments the floating point value of a field by a number. Uses 0 as initial value if the field doesn't exist.", since: "2.6.0", complexity: "O(1)",
+			handler: hincrByFloatCommand,
+		},
+		&command{
+			name: "HRANDFIELD", arity: -2, flags: flagReadonly, firstKey: 1, lastKey: 1, step: 1,
+			group: "hash", summary: "Returns one or more random fields from a hash.", since: "6.2.0", complexity: "O(N) where N is the number of fields returned",
+			handler: hrandFieldCommand,
+		},
+	)
+}
+
+// hashAt returns the hash stored at key, or nil when the key does not exist. get reads the key, and is
+// GetForWrite when the command modifies the hash. wrongType is set when the key holds a value of another type.
+func hashAt(get func(key string) (types.CustomValue, bool), key string) (hash *datatypes.Hash, wrongType bool) {
+	value, exists := get(key)
+	if !exists {
+		return nil, false
+	}
+	if value.Type != types.ValueTypeHash {
+		return nil, true
+	}
+	return value.Hash, false
+}
+
+// maxRandomCount bounds the number of elements a negative count may ask HRANDFIELD or SRANDMEMBER for. Unlike
+// a positive count it is not limited by the size of the collection, and the whole reply is built in memory.
+const maxRandomCount = 1 << 24
+
+// randomIndices calls fn with random positions between 0 and n-1, the way HRANDFIELD and SRANDMEMBER pick
+// elements: a positive count picks min(count, n) distinct positions, while a negative one picks -count
+// positions that may repeat. Like in Redis, the work is proportional to the number of positions picked.
+func randomIndices(n int, count int64, fn func(i int)) {
+	switch {
+	case count < 0:
+		for ; count < 0; count++ {
+			fn(rand.IntN(n))
+		}
+	case count >= int64(n):
+		// Every position is picked
+		for i := 0; i < n; i++ {
+			fn(i)
+		}
+	case count*3 > int64(n):
+		// Most positions are picked, so a permutation costs about as much as the reply
+		for _, i := range rand.Perm(n)[:count] {
+			fn(i)
+		}
+	default:
+		// Few positions are picked, so they are drawn until enough distinct ones are found
+		seen := make(map[int]struct{}, count)
+		for int64(len(seen)) < count {
+			i := rand.IntN(n)
+			if _, ok := seen[i]; ok {
+				continue
+			}
+			seen[i] = struct{}{}
+			fn(i)
+		}
+	}
+}
+
+// createHashAt returns the hash stored at key, creating it when the key does not exist.
+// wrongType is set when the key holds a value of another type.
+func createHashAt(tx keyspace.Tx, key string) (hash *datatypes.Hash, wrongType bool) {
+	hash, wrongType = hashAt(tx.GetForWrite, key)
+	if hash == nil && !wrongType {
+		hash = datatypes.NewHash()
+		tx.Set(key, types.CustomValue{Type: types.ValueTypeHash, Hash: hash, ValueExpiration: -1})
+	}
+	return hash, wrongType
+}
+
+func hsetCommand(args []string, cache keyspace.Keyspace) []byte {
+	// HSET <key> <field> <value> [field value ...]
+	if len(args)%2 != 0 {
+		return wrongNumberOfArguments("HSET")
+	}
+	key := args[1]
+
+	var reply []byte
+	var changes int64
+	cache.Atomically([]string{key}, func(tx keyspace.Tx) {
+		hash, wrongType := createHashAt(tx, key)
+		if wrongType {
+			reply = errorReply(errWrongType)
+			return
+		}
+
+		added := int64(0)
+		for i := 2; i < len(args); i += 2 {
+			if hash.Set(args[i], args[i+1]) {
+				added++
+			}
+		}
+		changes = int64(len(args)-2) / 2
+		reply = integer(added)
+	})
+
+	persistence.AddChanges(changes)
+	return reply
+}
+
+func hsetNXCommand(args []string, cache keyspace.Keyspace) []byte {
+	// HSETNX <key> <field> <value>
+	key := args[1]
+
+	var reply []byte
+	var changes int64
+	cache.Atomically([]string{key}, func(tx keyspace.Tx) {
+		hash, wrongType := createHashAt(tx, key)
+		if wrongType {
+			reply = errorReply(errWrongType)
+			return
+		}
+
+		if _, exists := hash.Get(args[2]); exists {
+			reply = integer(0)
+			return
+		}
+		hash.Set(args[2], args[3])
+		changes = 1
+		reply = integer(1)
+	})
+
+	persistence.AddChanges(changes)
+	return reply
+}
+
+func hgetCommand(args []string, cache keyspace.Keyspace) []byte {
+	// HGET <key> <field>
+	reply := nullBulkString()
+	cache.View([]string{args[1]}, func(tx keyspace.ReadTx) {
+		hash, wrongType := hashAt(tx.Get, args[1])
+		if wrongType {
+			reply = errorReply(errWrongType)
+			return
+		}
+		if hash == nil {
+			return
+		}
+		if value, ok := hash.Get(args[2]); ok {
+			reply = bulkString(value)
+		}
+	})
+
+	return reply
+}
+
+func hmgetCommand(args []string, cache keyspace.Keyspace) []byte {
+	// HMGET <key> <field> [field ...]
+	var reply []byte
+	cache.View([]string{args[1]}, func(tx keyspace.ReadTx) {
+		hash, wrongType := hashAt(tx.Get, args[1])
+		if wrongType {
+			reply = errorReply(errWrongType)
+			return
+		}
+
+		// Fields of a missing hash are replied as missing
+		reply = arrayHeader(len(args) - 2)
+		for _, field := range args[2:] {
+			if hash == nil {
+				reply = append(reply, nullBulkString()...)
+			} else if value, ok := hash.Get(field); ok {
+				reply = append(reply, bulkString(value)...)
+			} else {
+				reply = append(reply, nullBulkString()...)
+			}
+		}
+	})
+
+	return reply
+}
+
+func hdelCommand(args []string, cache keyspace.Keyspace) []byte {
+	// HDEL <key> <field> [field ...]
+	key := args[1]
+
+	var reply []byte
+	var changes int64
+	cache.Atomically([]string{key}, func(tx keyspace.Tx) {
+		hash, wrongType := hashAt(tx.GetForWrite, key)
+		switch {
+		case wrongType:
+			reply = errorReply(errWrongType)
+			return
+		case hash == nil:
+			reply = integer(0)
+			return
+		}
+
+		deleted := int64(0)
+		for _, field := range args[2:] {
+			if hash.Delete(field) {
+				deleted++
+			}
+		}
+		// A hash is deleted along with its last field
+		if hash.Len() == 0 {
+			tx.Delete(key)
+		}
+		changes = deleted
+		reply = integer(deleted)
+	})
+
+	persistence.AddChanges(changes)
+	return reply
+}
+
+func hgetAllCommand(args []string, cache keyspace.Keyspace) []byte {
+	// HGETALL <key>
+	// HKEYS <key>
+	// HVALS <key>
+	name := strings.ToUpper(args[0])
+
+	var reply []byte
+	cache.View([]string{args[1]}, func(tx keyspace.ReadTx) {
+		hash, wrongType := hashAt(tx.Get, args[1])
+		switch {
+		case wrongType:
+			reply = errorReply(errWrongType)
+			return
+		case hash == nil:
+			reply = arrayHeader(0)
+			return
+		}
+
+		// HGETALL replies the fields and values interleaved
+		n := hash.Len()
+		if name == "HGETALL" {
+			n *= 2
+		}
+		reply = arrayHeader(n)
+		hash.Iterate(func(field, value string) bool {
+			if name != "HVALS" {
+				reply = append(reply, bulkString(field)...)
+			}
+			if name != "HKEYS" {
+				reply = append(reply, bulkString(value)...)
+			}
+			return true
+		})
+	})
+
+	return reply
+}
+
+func hlenCommand(args []string, cache keyspace.Keyspace) []byte {
+	// HLEN <key>
+	var reply []byte
+	cache.View([]string{args[1]}, func(tx keyspace.ReadTx) {
+		hash, wrongType := hashAt(tx.Get, args[1])
+		switch {
+		case wrongType:
+			reply = errorReply(errWrongType)
+		case hash == nil:
+			reply = integer(0)
+		default:
+			reply = integer(int64(hash.Len()))
+		}
+	})
+
+	return reply
+}
+
+func hexistsCommand(args []string, cache keyspace.Keyspace) []byte {
+	// HEXISTS <key> <field>
+	reply := integer(0)
+	cache.View([]string{args[1]}, func(tx keyspace.ReadTx) {
+		hash, wrongType := hashAt(tx.Get, args[1])
+		if wrongType {
+			reply = errorReply(errWrongType)
+			return
+		}
+		if hash == nil {
+			return
+		}
+		if _, ok := hash.Get(args[2]); ok {
+			reply = integer(1)
+		}
+	})
+
+	return reply
+}
+
+func hincrByCommand(args []string, cache keyspace.Keyspace) []byte {
+	// HINCRBY <key> <field> <increment>
+	key, field := args[1], args[2]
+	delta, ok := parseInteger(args[3])
+	if !ok {
+		return errorReply(errNotInteger)
+	}
+
+	var reply []byte
+	var changes int64
+	cache.Atomically([]string{key}, func(tx keyspace.Tx) {
+		hash, wrongType := hashAt(tx.GetForWrite, key)
+		if wrongType {
+			reply = errorReply(errWrongType)
+			return
+		}
+
+		current := int64(0)
+		if hash != nil {
+			if value, exists := hash.Get(field); exists {
+				if current, ok = parseInteger(value); !ok {
+					reply = errorReply("ERR hash value is not an integer")
+					return
+				}
+			}
+		}
+		if (delta > 0 && current > math.MaxInt64-delta) || (delta < 0 && current < math.MinInt64-delta) {
+			reply = errorReply("ERR increment or decrement would overflow")
+			return
+		}
+		current += delta
+
+		// The hash is only created once the increment is known to succeed
+		hash, _ = createHashAt(tx, key)
+		hash.Set(field, strconv.FormatInt(current, 10))
+		changes = 1
+		reply = integer(current)
+	})
+
+	persistence.AddChanges(changes)
+	return reply
+}
+
+func hincrByFloatCommand(args []string, cache keyspace.Keyspace) []byte {
+	// HINCRBYFLOAT <key> <field> <increment>
+	key, field := args[1], args[2]
+	delta, ok := parseFloat(args[3])
+	if !ok {
+		return errorReply("ERR value is not a valid float")
+	}
+
+	var reply []byte
+	var changes int64
+	cache.Atomically([]string{key}, func(tx keyspace.Tx) {
+		hash, wrongType := hashAt(tx.GetForWrite, key)
+		if wrongType {
+			reply = errorReply(errWrongType)
+			return
+		}
+
+		current := 0.0
+		if hash != nil {
+			if value, exists := hash.Get(field); exists {
+				if current, ok = parseFloat(value); !ok {
+					reply = errorReply("ERR hash value is not a float")
+					return
+				}
+			}
+		}
+		current += delta
+		if math.IsNaN(current) || math.IsInf(current, 0) {
+			reply = errorReply("ERR increment would produce NaN or Infinity")
+			return
+		}
+
+		// The hash is only created once the increment is known to succeed
+		hash, _ = createHashAt(tx, key)
+		value := formatFloat(current)
+		hash.Set(field, value)
+		changes = 1
+		reply = bulkString(value)
+	})
+
+	persistence.AddChanges(changes)
+	return reply
+}
+
+func hrandFieldCommand(args []string, cache keyspace.Keyspace) []byte {
+	// HRANDFIELD <key> [count [WITHVALUES]]
+	if len(args) > 4 {
+		return errorReply("ERR syntax error")
+	}
+
+	// Without a count a single field is replied as a bulk string, with one the fields are replied as an array
+	count := int64(0)
+	hasCount := len(args) >= 3
+	withValues := false
+	if hasCount {
+		var ok bool
+		if count, ok = parseInteger(args[2]); !ok {
+			return errorReply(errNotInteger)
+		}
+		if len(args) == 4 {
+			if !strings.EqualFold(args[3], "WITHVALUES") {
+				return errorReply("ERR syntax error")
+			}
+			withValues = true
+		}
+		// A negative count is not bounded by the size of the hash, with or without WITHVALUES
+		if count < -maxRandomCount {
+			return errorReply("ERR value is out of range")
+		}
+	}
+
+	var reply []byte
+	cache.View([]string{args[1]}, func(tx keyspace.ReadTx) {
+		hash, wrongType := hashAt(tx.Get, args[1])
+		switch {
+		case wrongType:
+			reply = errorReply(errWrongType)
+			return
+		case hash == nil && hasCount:
+			reply = arrayHeader(0)
+			return
+		case hash == nil:
+			reply = nullBulkString()
+			return
+		}
+
+		if !hasCount {
+			field, _ := hash.Entry(rand.IntN(hash.Len()))
+			reply = bulkString(field)
+			return
+		}
+
+		// A positive count picks distinct fields, a negative one may pick the same field several times
+		n := int(-count)
+		if count >= 0 {
+			n = int(min(count, int64(hash.Len())))
+		}
+		if withValues {
+			n *= 2
+		}
+		reply = arrayHeader(n)
+		randomIndices(hash.Len(), count, func(i int) {
+			field, value := hash.Entry(i)
+			reply = append(reply, bulkString(field)...)
+			if withValues {
+				reply = append(reply, bulkString(value)...)
+			}
+		})
+	})
+
+	return reply
+}
@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"strings"
+
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/keyspace"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/persistence"
+)
+
+func init() {
+	register(
+		&command{
+			name: "DEL", arity: -2, flags: flagWrite, firstKey: 1, lastKey: -1, step: 1,
+			group: "generic", summary: "Deletes one or more keys.", since: "1.0.0", complexity: "O(N) where N is the number of keys that will be removed.",
+			handler: delCommand,
+		},
+		&command{
+			name: "UNLINK", arity: -2, flags: flagWrite | flagFast, firstKey: 1, lastKey: -1, step: 1,
+			group: "generic", summary: "Asynchronously deletes one or more keys.", since: "4.0.0", complexity: "O(1) for each key removed regardless of its size.",
+			handler: delCommand,
+		},
+		&command{
+			name: "EXISTS", arity: -2, flags: flagReadonly | flagFast, firstKey: 1, lastKey: -1, step: 1,
+			group: "generic", summary: "Determines whether one or more keys exist.", since: "1.0.0", complexity: "O(N) where N is the number of keys to check.",
+			handler: existsCommand,
+		},
+		&command{
+			name: "TOUCH", arity: -2, flags: flagReadonly | flagFast, firstKey: 1, lastKey: -1, step: 1,
+			group: "generic", summary: "Returns the number of existing keys out of those specified after updating the time they were last accessed.", since: "3.2.1", complexity: "O(N) where N is the number of keys that will be touched.",
+			handler: existsCommand,
+		},
+		&command{
+			name: "TYPE", arity: 2, flags: flagReadonly | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "generic", summary: "Determines the type of value stored at a key.", since: "1.0.0", complexity: "O(1)",
+			handler: typeCommand,
+		},
+		&command{
+			name: "RENAME", arity: 3, flags: flagWrite, firstKey: 1, lastKey: 2, step: 1,
+			group: "generic", summary: "Renames a key and overwrites the destination.", since: "1.0.0", complexity: "O(1)",
+			handler: renameCommand,
+		},
+		&command{
+			name: "RENAMENX", arity: 3, flags: flagWrite | flagFast, firstKey: 1, lastKey: 2, step: 1,
+			group: "generic", summary: "Renames a key only when the target key name doesn't exist.", since: "1.0.0", complexity: "O(1)",
+			handler: renameCommand,
+		},
+		&command{
+			name: "COPY", arity: -3, flags: flagWrite, firstKey: 1, lastKey: 2, step: 1,
+			group: "generic", summary: "Copies the value of a key to a new key.", since: "6.2.0", complexity: "O(N) worst case for collections, where N is the number of nested items. O(1) for string values.",
+			handler: copyCommand,
+		},
+	)
+}
+
+func delCommand(args []string, cache keyspace.Keyspace) []byte {
+	// DEL <key> [key ...]
+	// UNLINK <key> [key ...]
+	deleted := int64(0)
+	cache.Atomically(args[1:], func(tx keyspace.Tx) {
+		for _, key := range args[1:] {
+			if tx.Delete(key) {
+				deleted++
+			}
+		}
+	})
+	persistence.AddChanges(deleted)
+	return integer(deleted)
+}
+
+func existsCommand(args []string, cache keyspace.Keyspace) []byte {
+	// EXISTS <key> [key ...]
+	// TOUCH <key> [key ...]
+	// A key given several times is counted several times
+	existing := int64(0)
+	for _, key := range args[1:] {
+		if _, ok := cache.Get(key); ok {
+			existing++
+		}
+	}
+	return integer(existing)
+}
+
+func typeCommand(args []string, cache keyspace.Keyspace) []byte {
+	// TYPE <key>
+	value, ok := cache.Get(args[1])
+	if !ok {
+		return simpleString("none")
+	}
+	return simpleString(value.Type.String())
+}
+
+func renameCommand(args []string, cache keyspace.Keyspace) []byte {
+	// RENAME <key> <newkey>
+	// RENAMENX <key> <newkey>
+	nx := strings.ToUpper(args[0]) == "RENAMENX"
+	src, dst := args[1], args[2]
+
+	var reply []byte
+	var changes int64
+	cache.Atomically([]string{src, dst}, func(tx keyspace.Tx) {
+		// The collection moves to another key, where it is not known to be held by a snapshot
+		value, ok := tx.GetForWrite(src)
+		if !ok {
+			reply = errorReply("ERR no such key")
+			return
+		}
+
+		if nx {
+			if _, exists := tx.Get(dst); exists {
+				reply = integer(0)
+				return
+			}
+		}
+
+		// The value keeps its expiration time under its new name
+		if src != dst {
+			tx.Delete(src)
+			tx.Set(dst, value)
+			changes = 1
+		}
+
+		if nx {
+			reply = integer(1)
+		} else {
+			reply = simpleString("OK")
+		}
+	})
+
+	persistence.AddChanges(changes)
+	return reply
+}
+
+func copyCommand(args []string, cache keyspace.Keyspace) []byte {
+	// COPY <source> <destination> [DB destination-db] [REPLACE]
+	src, dst := args[1], args[2]
+
+	replace := false
+	for i := 3; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "REPLACE":
+			replace = true
+		case "DB":
+			if i+1 == len(args) {
+				return errorReply("ERR syntax error")
+			}
+			i++
+			// Only the default database exists
+			if args[i] != "0" {
+				return errorReply("ERR DB index is out of range")
+			}
+		default:
+			return errorReply("ERR syntax error")
+		}
+	}
+
+	if src == dst {
+		return errorReply("ERR source and destination objects are the same")
+	}
+
+	var reply []byte
+	var changes int64
+	cache.Atomically([]string{src, dst}, func(tx keyspace.Tx) {
+		value, ok := tx.Get(src)
+		if !ok {
+			reply = integer(0)
+			return
+		}
+		if _, exists := tx.Get(dst); exists && !replace {
+			reply = integer(0)
+			return
+		}
+
+		// The copy keeps the expiration time of the source, and must not share collections with it
+		tx.Set(dst, value.Clone())
+		changes = 1
+		reply = integer(1)
+	})
+
+	persistence.AddChanges(changes)
+	return reply
+}
@@ -0,0 +1,561 @@
+package handler
+
+import (
+	"math"
+	"strings"
+
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/datatypes"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/keyspace"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/persistence"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/types"
+)
+
+func init() {
+	register(
+		&command{
+			name: "LPUSH", arity: -3, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "list", summary: "Prepends one or more elements to a list. Creates the key if it doesn't exist.", since: "1.0.0", complexity: "O(1) for each element added, so O(N) to add N elements when the command is called with multiple arguments.",
+			handler: pushCommand,
+		},
+		&command{
+			name: "RPUSH", arity: -3, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "list", summary: "Appends one or more elements to a list. Creates the key if it doesn't exist.", since: "1.0.0", complexity: "O(1) for each element added, so O(N) to add N elements when the command is called with multiple arguments.",
+			handler: pushCommand,
+		},
+		&command{
+			name: "LPOP", arity: -2, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "list", summary: "Returns the first elements in a list after removing it. Deletes the list if the last element was popped.", since: "1.0.0", complexity: "O(N) where N is the number of elements returned",
+			handler: popCommand,
+		},
+		&command{
+			name: "RPOP", arity: -2, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "list", summary: "Returns and removes the last elements of a list. Deletes the list if the last element was popped.", since: "1.0.0", complexity: "O(N) where N is the number of elements returned",
+			handler: popCommand,
+		},
+		&command{
+			name: "LRANGE", arity: 4, flags: flagReadonly, firstKey: 1, lastKey: 1, step: 1,
+			group: "list", summary: "Returns a range of elements from a list.", since: "1.0.0", complexity: "O(S+N) where S is the distance of start offset from HEAD for small lists, from nearest end (HEAD or TAIL) for large lists; and N is the number of elements in the specified range.",
+			handler: lrangeCommand,
+		},
+		&command{
+			name: "LLEN", arity: 2, flags: flagReadonly | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "list", summary: "Returns the length of a list.", since: "1.0.0", complexity: "O(1)",
+			handler: llenCommand,
+		},
+		&command{
+			name: "LINDEX", arity: 3, flags: flagReadonly, firstKey: 1, lastKey: 1, step: 1,
+			group: "list", summary: "Returns an element from a list by its index.", since: "1.0.0", complexity: "O(N) where N is the number of elements to traverse to get to the element at index. This makes asking for the first or the last element of the list O(1).",
+			handler: lindexCommand,
+		},
+		&command{
+			name: "LSET", arity: 4, flags: flagWrite, firstKey: 1, lastKey: 1, step: 1,
+			group: "list", summary: "Sets the value of an element in a list by its index.", since: "1.0.0", complexity: "O(N) where N is the length of the list. Setting either the first or the last element of the list is O(1).",
+			handler: lsetCommand,
+		},
+		&command{
+			name: "LREM", arity: 4, flags: flagWrite, firstKey: 1, lastKey: 1, step: 1,
+			group: "list", summary: "Removes elements from a list. Deletes the list if the last element was removed.", since: "1.0.0", complexity: "O(N+M) where N is the length of the list and M is the number of elements removed.",
+			handler: lremCommand,
+		},
+		&command{
+			name: "LTRIM", arity: 4, flags: flagWrite, firstKey: 1, lastKey: 1, step: 1,
+			group: "list", summary: "Removes elements from both ends a list. Deletes the list if all elements were trimmed.", since: "1.0.0", complexity: "O(N) where N is the number of elements to be removed by the operation.",
+			handler: ltrimCommand,
+		},
+		&command{
+			name: "LINSERT", arity: 5, flags: flagWrite, firstKey: 1, lastKey: 1, step: 1,
+			group: "list", summary: "Inserts an element before or after another element in a list.", since: "2.2.0", complexity: "O(N) where N is the number of elements to traverse before seeing the value pivot. This means that inserting somewhere on the left end on the list (head) can be considered O(1) and inserting somewhere on the right end (tail) is O(N).",
+			handler: linsertCommand,
+		},
+		&command{
+			name: "LPOS", arity: -3, flags: flagReadonly, firstKey: 1, lastKey: 1, step: 1,
+			group: "list", summary: "Returns the index of matching elements in a list.", since: "6.0.6", complexity: "O(N) where N is the number of elements in the list, for the average case. When searching for elements near the head or the tail of the list, or when the MAXLEN option is provided, the command may run in constant time.",
+			handler: lposCommand,
+		},
+		&command{
+			name: "LMOVE", arity: 5, flags: flagWrite, firstKey: 1, lastKey: 2, step: 1,
+			group: "list", summary: "Returns an element after popping it from one list and pushing it to another. Deletes the list if the last element was moved.", since: "6.2.0", complexity: "O(1)",
+			handler: lmoveCommand,
+		},
+	)
+}
+
+// listAt returns the list stored at key, or nil when the key does not exist. get reads the key, and is
+// GetForWrite when the command modifies the list. wrongType is set when the key holds a value of another type.
+func listAt(get func(key string) (types.CustomValue, bool), key string) (list *datatypes.List, wrongType bool) {
+	value, exists := get(key)
+	if !exists {
+		return nil, false
+	}
+	if value.Type != types.ValueTypeList {
+		return nil, true
+	}
+	return value.List, false
+}
+
+// newListValue returns a value holding a new empty list, without expiration time.
+func newListValue() types.CustomValue {
+	return types.CustomValue{Type: types.ValueTypeList, List: datatypes.NewList(), ValueExpiration: -1}
+}
+
+// parseEnd parses the LEFT or RIGHT argument of LMOVE, reporting whether it designates the head of the list.
+func parseEnd(s string) (left bool, ok bool) {
+	switch strings.ToUpper(s) {
+	case "LEFT":
+		return true, true
+	case "RIGHT":
+		return false, true
+	}
+	return false, false
+}
+
+func pushCommand(args []string, cache keyspace.Keyspace) []byte {
+	// LPUSH <key> <element> [element ...]
+	// RPUSH <key> <element> [element ...]
+	left := strings.ToUpper(args[0]) == "LPUSH"
+	key := args[1]
+
+	var reply []byte
+	var changes int64
+	cache.Atomically([]string{key}, func(tx keyspace.Tx) {
+		list, wrongType := listAt(tx.GetForWrite, key)
+		if wrongType {
+			reply = errorReply(errWrongType)
+			return
+		}
+		if list == nil {
+			value := newListValue()
+			tx.Set(key, value)
+			list = value.List
+		}
+
+		// Elements are pushed one after the other, so LPUSH leaves them in reverse order
+		for _, element := range args[2:] {
+			if left {
+				list.PushFront(element)
+			} else {
+				list.PushBack(element)
+			}
+		}
+		changes = int64(len(args) - 2)
+		reply = integer(int64(list.Len()))
+	})
+
+	persistence.AddChanges(changes)
+	return reply
+}
+
+func popCommand(args []string, cache keyspace.Keyspace) []byte {
+	// LPOP <key> [count]
+	// RPOP <key> [count]
+	name := strings.ToUpper(args[0])
+	left := name == "LPOP"
+	key := args[1]
+	if len(args) > 3 {
+		return wrongNumberOfArguments(name)
+	}
+
+	// Without a count a single element is replied as a bulk string, with one the elements are replied as an array
+	count := int64(-1)
+	if len(args) == 3 {
+		var ok bool
+		if count, ok = parseInteger(args[2]); !ok || count < 0 {
+			return errorReply("ERR value is out of range, must be positive")
+		}
+	}
+
+	var reply []byte
+	var changes int64
+	cache.Atomically([]string{key}, func(tx keyspace.Tx) {
+		list, wrongType := listAt(tx.GetForWrite, key)
+		switch {
+		case wrongType:
+			reply = errorReply(errWrongType)
+			return
+		case list == nil && count < 0:
+			reply = nullBulkString()
+			return
+		case list == nil:
+			reply = nullArray()
+			return
+		}
+
+		pop := list.PopBack
+		if left {
+			pop = list.PopFront
+		}
+		if count < 0 {
+			element, _ := pop()
+			changes = 1
+			reply = bulkString(element)
+		} else {
+			elements := make([]string, 0, min(count, int64(list.Len())))
+			for int64(len(elements)) < count {
+				element, ok := pop()
+				if !ok {
+					break
+				}
+				elements = append(elements, element)
+			}
+			changes = int64(len(elements))
+			reply = bulkStringArray(elements)
+		}
+
+		// A list is deleted along with its last element
+		if list.Len() == 0 {
+			tx.Delete(key)
+		}
+	})
+
+	persistence.AddChanges(changes)
+	return reply
+}
+
+func lrangeCommand(args []string, cache keyspace.Keyspace) []byte {
+	// LRANGE <key> <start> <stop>
+	start, ok := parseInteger(args[2])
+	if !ok {
+		return errorReply(errNotInteger)
+	}
+	stop, ok := parseInteger(args[3])
+	if !ok {
+		return errorReply(errNotInteger)
+	}
+
+	// The list is read while its shard is locked, so no command changes it meanwhile
+	var reply []byte
+	cache.View([]string{args[1]}, func(tx keyspace.ReadTx) {
+		list, wrongType := listAt(tx.Get, args[1])
+		switch {
+		case wrongType:
+			reply = errorReply(errWrongType)
+		case list == nil:
+			reply = arrayHeader(0)
+		default:
+			reply = bulkStringArray(list.Range(int(start), int(stop)))
+		}
+	})
+
+	return reply
+}
+
+func llenCommand(args []string, cache keyspace.Keyspace) []byte {
+	// LLEN <key>
+	var reply []byte
+	cache.View([]string{args[1]}, func(tx keyspace.ReadTx) {
+		list, wrongType := listAt(tx.Get, args[1])
+		switch {
+		case wrongType:
+			reply = errorReply(errWrongType)
+		case list == nil:
+			reply = integer(0)
+		default:
+			reply = integer(int64(list.Len()))
+		}
+	})
+
+	return reply
+}
+
+func lindexCommand(args []string, cache keyspace.Keyspace) []byte {
+	// LINDEX <key> <index>
+	index, ok := parseInteger(args[2])
+	if !ok {
+		return errorReply(errNotInteger)
+	}
+
+	reply := nullBulkString()
+	cache.View([]string{args[1]}, func(tx keyspace.ReadTx) {
+		list, wrongType := listAt(tx.Get, args[1])
+		if wrongType {
+			reply = errorReply(errWrongType)
+			return
+		}
+		if list == nil {
+			return
+		}
+		if element, ok := list.Index(int(index)); ok {
+			reply = bulkString(element)
+		}
+	})
+
+	return reply
+}
+
+func lsetCommand(args []string, cache keyspace.Keyspace) []byte {
+	// LSET <key> <index> <element>
+	index, ok := parseInteger(args[2])
+	if !ok {
+		return errorReply(errNotInteger)
+	}
+
+	var reply []byte
+	var changes int64
+	cache.Atomically([]string{args[1]}, func(tx keyspace.Tx) {
+		list, wrongType := listAt(tx.GetForWrite, args[1])
+		switch {
+		case wrongType:
+			reply = errorReply(errWrongType)
+		case list == nil:
+			reply = errorReply("ERR no such key")
+		case !list.Set(int(index), args[3]):
+			reply = errorReply("ERR index out of range")
+		default:
+			changes = 1
+			reply = simpleString("OK")
+		}
+	})
+
+	persistence.AddChanges(changes)
+	return reply
+}
+
+func lremCommand(args []string, cache keyspace.Keyspace) []byte {
+	// LREM <key> <count> <element>
+	key := args[1]
+	count, ok := parseInteger(args[2])
+	if !ok {
+		return errorReply(errNotInteger)
+	}
+
+	var reply []byte
+	var changes int64
+	cache.Atomically([]string{key}, func(tx keyspace.Tx) {
+		list, wrongType := listAt(tx.GetForWrite, key)
+		switch {
+		case wrongType:
+			reply = errorReply(errWrongType)
+			return
+		case list == nil:
+			reply = integer(0)
+			return
+		}
+
+		removed := list.Remove(args[3], int(count))
+		if list.Len() == 0 {
+			tx.Delete(key)
+		}
+		changes = int64(removed)
+		reply = integer(int64(removed))
+	})
+
+	persistence.AddChanges(changes)
+	return reply
+}
+
+func ltrimCommand(args []string, cache keyspace.Keyspace) []byte {
+	// LTRIM <key> <start> <stop>
+	key := args[1]
+	start, ok := parseInteger(args[2])
+	if !ok {
+		return errorReply(errNotInteger)
+	}
+	stop, ok := parseInteger(args[3])
+	if !ok {
+		return errorReply(errNotInteger)
+	}
+
+	reply := simpleString("OK")
+	var changes int64
+	cache.Atomically([]string{key}, func(tx keyspace.Tx) {
+		list, wrongType := listAt(tx.GetForWrite, key)
+		if wrongType {
+			reply = errorReply(errWrongType)
+			return
+		}
+		if list == nil {
+			return
+		}
+
+		n := list.Len()
+		list.Trim(int(start), int(stop))
+		if list.Len() == 0 {
+			tx.Delete(key)
+		}
+		changes = int64(n - list.Len())
+	})
+
+	persistence.AddChanges(changes)
+	return reply
+}
+
+func linsertCommand(args []string, cache keyspace.Keyspace) []byte {
+	// LINSERT <key> <BEFORE | AFTER> <pivot> <element>
+	var before bool
+	switch strings.ToUpper(args[2]) {
+	case "BEFORE":
+		before = true
+	case "AFTER":
+		before = false
+	default:
+		return errorReply("ERR syntax error")
+	}
+
+	var reply []byte
+	var changes int64
+	cache.Atomically([]string{args[1]}, func(tx keyspace.Tx) {
+		list, wrongType := listAt(tx.GetForWrite, args[1])
+		switch {
+		case wrongType:
+			reply = errorReply(errWrongType)
+		case list == nil:
+			reply = integer(0)
+		case !list.Insert(args[3], args[4], before):
+			reply = integer(-1)
+		default:
+			changes = 1
+			reply = integer(int64(list.Len()))
+		}
+	})
+
+	persistence.AddChanges(changes)
+	return reply
+}
+
+func lposCommand(args []string, cache keyspace.Keyspace) []byte {
+	// LPOS <key> <element> [RANK rank] [COUNT num-matches] [MAXLEN len]
+	rank, count, maxLen := int64(1), int64(-1), int64(0)
+	for i := 3; i < len(args); i += 2 {
+		option := strings.ToUpper(args[i])
+		if i+1 == len(args) {
+			return errorReply("ERR syntax error")
+		}
+		n, ok := parseInteger(args[i+1])
+		if !ok {
+			return errorReply(errNotInteger)
+		}
+
+		switch option {
+		case "RANK":
+			// The rank is negated below to scan from the tail, which MinInt64 does not survive
+			if n == math.MinInt64 {
+				return errorReply("ERR value is out of range, value must between -9223372036854775807 and 9223372036854775807")
+			}
+			if n == 0 {
+				return errorReply("ERR RANK can't be zero: use 1 to start from the first match, 2 from the second ... or use negative to start from the last match")
+			}
+			rank = n
+		case "COUNT":
+			if n < 0 {
+				return errorReply("ERR COUNT can't be negative")
+			}
+			count = n
+		case "MAXLEN":
+			if n < 0 {
+				return errorReply("ERR MAXLEN can't be negative")
+			}
+			maxLen = n
+		default:
+			return errorReply("ERR syntax error")
+		}
+	}
+
+	var reply []byte
+	cache.View([]string{args[1]}, func(tx keyspace.ReadTx) {
+		list, wrongType := listAt(tx.Get, args[1])
+		if wrongType {
+			reply = errorReply(errWrongType)
+			return
+		}
+
+		// A negative rank scans from the tail, skipping the first -rank-1 matches
+		var matches []int64
+		if list != nil {
+			reverse, skip := rank < 0, rank-1
+			if reverse {
+				skip = -rank - 1
+			}
+			compared := int64(0)
+			list.Iterate(reverse, func(index int, element string) bool {
+				if maxLen > 0 && compared == maxLen {
+					return false
+				}
+				compared++
+				if element != args[2] {
+					return true
+				}
+				if skip > 0 {
+					skip--
+					return true
+				}
+				matches = append(matches, int64(index))
+				// Without COUNT only the first match is wanted, and a COUNT of 0 wants all of them
+				return count == 0 || (count > 0 && int64(len(matches)) < count)
+			})
+		}
+
+		if count < 0 {
+			if len(matches) == 0 {
+				reply = nullBulkString()
+			} else {
+				reply = integer(matches[0])
+			}
+			return
+		}
+		reply = arrayHeader(len(matches))
+		for _, match := range matches {
+			reply = append(reply, integer(match)...)
+		}
+	})
+
+	return reply
+}
+
+func lmoveCommand(args []string, cache keyspace.Keyspace) []byte {
+	// LMOVE <source> <destination> <LEFT | RIGHT> <LEFT | RIGHT>
+	src, dst := args[1], args[2]
+	fromLeft, ok := parseEnd(args[3])
+	if !ok {
+		return errorReply("ERR syntax error")
+	}
+	toLeft, ok := parseEnd(args[4])
+	if !ok {
+		return errorReply("ERR syntax error")
+	}
+
+	var reply []byte
+	var changes int64
+	cache.Atomically([]string{src, dst}, func(tx keyspace.Tx) {
+		source, wrongType := listAt(tx.GetForWrite, src)
+		if wrongType {
+			reply = errorReply(errWrongType)
+			return
+		}
+		if source == nil {
+			reply = nullBulkString()
+			return
+		}
+		// The destination is checked before popping, so a failing command leaves the source untouched
+		destination, wrongType := listAt(tx.GetForWrite, dst)
+		if wrongType {
+			reply = errorReply(errWrongType)
+			return
+		}
+
+		var element string
+		if fromLeft {
+			element, _ = source.PopFront()
+		} else {
+			element, _ = source.PopBack()
+		}
+		// When the source and the destination are the same list, the element rotates back into it
+		if source.Len() == 0 && src != dst {
+			tx.Delete(src)
+		}
+		if destination == nil {
+			value := newListValue()
+			tx.Set(dst, value)
+			destination = value.List
+		}
+		if toLeft {
+			destination.PushFront(element)
+		} else {
+			destination.PushBack(element)
+		}
+		changes = 1
+		reply = bulkString(element)
+	})
+
+	persistence.AddChanges(changes)
+	return reply
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/keyspace"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/parser"
+)
+
+// propagated returns the commands logged to the append-only file for a write command that succeeded with reply.
+// Replaying them must have the same effect as the command had, so the commands whose effect depends on the time
+// they run or on chance are rewritten, like Redis does: relative expiration times become absolute, and SPOP becomes
+// the SREM of the members it popped. SET rewrites its arguments itself, with rewriteSetExpiration, and SETEX
+// replaces its relative time with the absolute one it computed. The other commands are logged as they are.
+func propagated(args []string, reply []byte, cache keyspace.Keyspace) [][]string {
+	key := args[1]
+
+	switch strings.ToUpper(args[0]) {
+	case "SETEX", "PSETEX":
+		// The key is logged with the time it was given, even if it has expired by now
+		return [][]string{{"SET", key, args[3], "PXAT", args[2]}}
+
+	case "EXPIRE", "PEXPIRE", "EXPIREAT", "PEXPIREAT":
+		if string(reply) == string(integer(0)) {
+			return nil
+		}
+		return expirationOf(key, cache)
+
+	case "GETEX":
+		// GETEX without options only reads the key, and a missing key is left as it is
+		if len(args) == 2 || string(reply) == string(nullBulkString()) {
+			return nil
+		}
+		return expirationOf(key, cache)
+
+	case "SPOP":
+		value, _, _, err := parser.ParseFrame(reply)
+		if err != nil {
+			break
+		}
+		members := []string{}
+		switch v := value.(type) {
+		case string:
+			members = append(members, v)
+		case []interface{}:
+			for _, member := range v {
+				members = append(members, member.(string))
+			}
+		}
+		if len(members) == 0 {
+			return nil
+		}
+		return [][]string{append([]string{"SREM", key}, members...)}
+	}
+
+	return [][]string{args}
+}
+
+// rewriteSetExpiration replaces the expiration option in the arguments of a SET command by PXAT and at, the absolute
+// expiration time the command computed from its reading of the clock. The command is then logged with the time the
+// key was given, even if the key has expired or was modified by then. A SET that was not applied because of its NX
+// or XX condition is not applied when it is replayed either, whatever its expiration.
+func rewriteSetExpiration(args []string, at int64) {
+	for i := 3; i < len(args)-1; i++ {
+		switch strings.ToUpper(args[i]) {
+		case "EX", "PX", "EXAT", "PXAT":
+			args[i], args[i+1] = "PXAT", strconv.FormatInt(at, 10)
+			return
+		}
+	}
+}
+
+// expirationOf returns the command that gives the key its current expiration time, or deletes it when it is gone
+// because the expiration time was in the past.
+func expirationOf(key string, cache keyspace.Keyspace) [][]string {
+	at, ok := cache.ExpireTime(key)
+	switch {
+	case !ok:
+		return [][]string{{"DEL", key}}
+	case at < 0:
+		return [][]string{{"PERSIST", key}}
+	}
+	return [][]string{{"PEXPIREAT", key, strconv.FormatInt(at, 10)}}
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Helpers encoding RESP replies.
+
+// errNotInteger is the error replied when an argument expected to be an integer is not one.
+const errNotInteger = "ERR value is not an integer or out of range"
+
+// errWrongType is the error replied when a command is run against a key holding a value of another type.
+const errWrongType = "WRONGTYPE Operation against a key holding the wrong kind of value"
+
+// simpleString encodes a RESP simple string such as +OK.
+func simpleString(s string) []byte {
+	return []byte("+" + s + "\r\n")
+}
+
+// errorReply encodes a RESP error. msg must start with the error code, e.g. "ERR syntax error".
+func errorReply(msg string) []byte {
+	return []byte("-" + msg + "\r\n")
+}
+
+// integer encodes a RESP integer.
+func integer(n int64) []byte {
+	return []byte(":" + strconv.FormatInt(n, 10) + "\r\n")
+}
+
+// bulkString encodes a RESP bulk string.
+func bulkString(s string) []byte {
+	return []byte(fmt.Sprintf("$%d\r\n%s\r\n", len(s), s))
+}
+
+// nullBulkString encodes the RESP null bulk string, replied for missing values.
+func nullBulkString() []byte {
+	return []byte("$-1\r\n")
+}
+
+// nullArray encodes the RESP null array, replied when a command producing an array has nothing to reply.
+func nullArray() []byte {
+	return []byte("*-1\r\n")
+}
+
+// arrayHeader encodes the header of a RESP array of n elements, which must be followed by the elements.
+func arrayHeader(n int) []byte {
+	return []byte("*" + strconv.Itoa(n) + "\r\n")
+}
+
+// array encodes a RESP array of already encoded elements.
+func array(elems ...[]byte) []byte {
+	reply := arrayHeader(len(elems))
+	for _, elem := range elems {
+		reply = append(reply, elem...)
+	}
+	return reply
+}
+
+// bulkStringArray encodes a RESP array of bulk strings.
+func bulkStringArray(values []string) []byte {
+	reply := arrayHeader(len(values))
+	for _, value := range values {
+		reply = append(reply, bulkString(value)...)
+	}
+	return reply
+}
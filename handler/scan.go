@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/keyspace"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/types"
+)
+
+func init() {
+	register(
+		&command{
+			name: "KEYS", arity: 2, flags: flagReadonly,
+			group: "keyspace", summary: "Returns all key names that match a pattern.", since: "1.0.0", complexity: "O(N) with N being the number of keys in the database, under the assumption that the key names in the database and the given pattern have limited length.",
+			handler: keysCommand,
+		},
+		&command{
+			name: "SCAN", arity: -2, flags: flagReadonly,
+			group: "keyspace", summary: "Iterates over the key names in the database.", since: "2.8.0", complexity: "O(log N + COUNT) for every call. O(N log N) for a complete iteration, including enough command calls for the cursor to return back to 0. N is the number of keys in the database.",
+			handler: scanCommand,
+		},
+	)
+}
+
+func keysCommand(args []string, cache keyspace.Keyspace) []byte {
+	// KEYS <pattern>
+	pattern := args[1]
+
+	// Every shard is visited once, with its keys read-locked while they are matched
+	var matches []string
+	cache.ForEach(func(key string, _ types.CustomValue) bool {
+		if matchPattern(pattern, key) {
+			matches = append(matches, key)
+		}
+		return true
+	})
+
+	return bulkStringArray(matches)
+}
+
+func scanCommand(args []string, cache keyspace.Keyspace) []byte {
+	// SCAN <cursor> [MATCH pattern] [COUNT count] [TYPE type]
+	cursor, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		return errorReply("ERR invalid cursor")
+	}
+
+	pattern, count, typ := "", 10, ""
+	for i := 2; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			return errorReply("ERR syntax error")
+		}
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			pattern = args[i+1]
+		case "COUNT":
+			count, err = strconv.Atoi(args[i+1])
+			if err != nil {
+				return errorReply(errNotInteger)
+			}
+			if count < 1 {
+				return errorReply("ERR syntax error")
+			}
+		case "TYPE":
+			typ = strings.ToLower(args[i+1])
+		default:
+			return errorReply("ERR syntax error")
+		}
+	}
+
+	keys, next := cache.Scan(cursor, count)
+
+	// The filters are applied to the keys of the batch, so a call may return fewer keys than COUNT, or none
+	matches := keys[:0]
+	for _, key := range keys {
+		if pattern != "" && !matchPattern(pattern, key) {
+			continue
+		}
+		if typ != "" {
+			value, ok := cache.Get(key)
+			if !ok || value.Type.String() != typ {
+				continue
+			}
+		}
+		matches = append(matches, key)
+	}
+
+	return array(
+		bulkString(strconv.FormatUint(next, 10)),
+		bulkStringArray(matches),
+	)
+}
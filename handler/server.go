@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/config"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/keyspace"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/persistence"
+)
+
+func init() {
+	register(
+		&command{
+			name: "BGSAVE", arity: 1, flags: flagAdmin,
+			group: "server", summary: "Asynchronously saves the database(s) to disk.", since: "1.0.0", complexity: "O(1)",
+			handler: bgsaveCommand,
+		},
+		&command{
+			name: "LASTSAVE", arity: 1, flags: flagFast,
+			group: "server", summary: "Returns the Unix timestamp of the last successful save to disk.", since: "1.0.0", complexity: "O(1)",
+			handler: lastsaveCommand,
+		},
+		&command{
+			name: "INFO", arity: -1,
+			group: "server", summary: "Returns information and statistics about the server.", since: "1.0.0", complexity: "O(1)",
+			handler: infoCommand,
+		},
+	)
+}
+
+const errSaveInProgress = "ERR Background save already in progress"
+
+func bgsaveCommand(args []string, cache keyspace.Keyspace) []byte {
+	// BGSAVE
+	if err := persistence.BackgroundSave(config.RDBPath(), cache); err != nil {
+		if errors.Is(err, persistence.ErrSaveInProgress) {
+			return errorReply(errSaveInProgress)
+		}
+		return errorReply("ERR " + err.Error())
+	}
+	return simpleString("Background saving started")
+}
+
+func lastsaveCommand(args []string, cache keyspace.Keyspace) []byte {
+	// LASTSAVE
+	return integer(persistence.Status().LastSave.Unix())
+}
+
+// infoSection is a section of the INFO reply, whose fields are name:value lines.
+type infoSection struct {
+	name   string
+	fields func(cache keyspace.Keyspace) []string
+}
+
+// infoSections are the sections reported by INFO, in order.
+var infoSections = []infoSection{
+	{name: "Persistence", fields: persistenceInfo},
+}
+
+func infoCommand(args []string, cache keyspace.Keyspace) []byte {
+	// INFO [section [section ...]]
+	all := len(args) == 1
+	wanted := make(map[string]bool)
+	for _, arg := range args[1:] {
+		switch section := strings.ToLower(arg); section {
+		case "all", "everything", "default":
+			all = true
+		default:
+			wanted[section] = true
+		}
+	}
+
+	var sb strings.Builder
+	for _, section := range infoSections {
+		if !all && !wanted[strings.ToLower(section.name)] {
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteString("\r\n")
+		}
+		sb.WriteString("# " + section.name + "\r\n")
+		for _, field := range section.fields(cache) {
+			sb.WriteString(field + "\r\n")
+		}
+	}
+	return bulkString(sb.String())
+}
+
+// persistenceInfo returns the fields of the persistence section of INFO.
+func persistenceInfo(cache keyspace.Keyspace) []string {
+	status := persistence.Status()
+
+	inProgress, current := 0, int64(-1)
+	if status.BgsaveInProgress {
+		inProgress, current = 1, int64(time.Since(status.BgsaveStart).Seconds())
+	}
+	lastStatus := "ok"
+	if status.LastBgsaveErr != nil {
+		lastStatus = "err"
+	}
+	lastDuration := int64(-1)
+	if status.LastBgsaveDuration >= 0 {
+		lastDuration = int64(status.LastBgsaveDuration.Seconds())
+	}
+
+	aofEnabled, aofStatus := 0, "ok"
+	if aof := persistence.AppendOnly(); aof != nil {
+		aofEnabled = 1
+		if aof.Err() != nil {
+			aofStatus = "err"
+		}
+	}
+
+	return []string{
+		"loading:0",
+		fmt.Sprintf("rdb_changes_since_last_save:%d", status.Changes),
+		fmt.Sprintf("rdb_bgsave_in_progress:%d", inProgress),
+		fmt.Sprintf("rdb_last_save_time:%d", status.LastSave.Unix()),
+		"rdb_last_bgsave_status:" + lastStatus,
+		fmt.Sprintf("rdb_last_bgsave_time_sec:%d", lastDuration),
+		fmt.Sprintf("rdb_current_bgsave_time_sec:%d", current),
+		fmt.Sprintf("rdb_saves:%d", status.Saves),
+		fmt.Sprintf("aof_enabled:%d", aofEnabled),
+		"aof_last_write_status:" + aofStatus,
+	}
+}
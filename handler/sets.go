@@ -0,0 +1,537 @@
+package handler
+
+import (
+	"math/rand/v2"
+	"strings"
+
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/datatypes"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/keyspace"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/persistence"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/types"
+)
+
+func init() {
+	register(
+		&command{
+			name: "SADD", arity: -3, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "set", summary: "Adds one or more members to a set. Creates the key if it doesn't exist.", since: "1.0.0", complexity: "O(1) for each element added, so O(N) to add N elements when the command is called with multiple arguments.",
+			handler: saddCommand,
+		},
+		&command{
+			name: "SREM", arity: -3, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "set", summary: "Removes one or more members from a set. Deletes the set if the last member was removed.", since: "1.0.0", complexity: "O(N) where N is the number of members to be removed.",
+			handler: sremCommand,
+		},
+		&command{
+			name: "SMEMBERS", arity: 2, flags: flagReadonly, firstKey: 1, lastKey: 1, step: 1,
+			group: "set", summary: "Returns all members of a set.", since: "1.0.0", complexity: "O(N) where N is the set cardinality.",
+			handler: smembersCommand,
+		},
+		&command{
+			name: "SISMEMBER", arity: 3, flags: flagReadonly | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "set", summary: "Determines whether a member belongs to a set.", since: "1.0.0", complexity: "O(1)",
+			handler: sismemberCommand,
+		},
+		&command{
+			name: "SMISMEMBER", arity: -3, flags: flagReadonly | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "set", summary: "Determines whether multiple members belong to a set.", since: "6.2.0", complexity: "O(N) where N is the number of elements being checked for membership",
+			handler: sismemberCommand,
+		},
+		&command{
+			name: "SCARD", arity: 2, flags: flagReadonly | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "set", summary: "Returns the number of members in a set.", since: "1.0.0", complexity: "O(1)",
+			handler: scardCommand,
+		},
+		&command{
+			name: "SPOP", arity: -2, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "set", summary: "Returns one or more random members from a set after removing them. Deletes the set if the last member was popped.", since: "1.0.0", complexity: "Without the count argument O(1), otherwise O(N) where N is the value of the passed count.",
+			handler: srandMemberCommand,
+		},
+		&command{
+			name: "SRANDMEMBER", arity: -2, flags: flagReadonly, firstKey: 1, lastKey: 1, step: 1,
+			group: "set", summary: "Get one or multiple random members from a set", since: "1.0.0", complexity: "Without the count argument O(1), otherwise O(N) where N is the absolute value of the passed count.",
+			handler: srandMemberCommand,
+		},
+		&command{
+			name: "SINTER", arity: -2, flags: flagReadonly, firstKey: 1, lastKey: -1, step: 1,
+			group: "set", summary: "Returns the intersect of multiple sets.", since: "1.0.0", complexity: "O(N*M) worst case where N is the cardinality of the smallest set and M is the number of sets.",
+			handler: setAlgebraCommand,
+		},
+		&command{
+			name: "SUNION", arity: -2, flags: flagReadonly, firstKey: 1, lastKey: -1, step: 1,
+			group: "set", summary: "Returns the union of multiple sets.", since: "1.0.0", complexity: "O(N) where N is the total number of elements in all given sets.",
+			handler: setAlgebraCommand,
+		},
+		&command{
+			name: "SDIFF", arity: -2, flags: flagReadonly, firstKey: 1, lastKey: -1, step: 1,
+			group: "set", summary: "Returns the difference of multiple sets.", since: "1.0.0", complexity: "O(N) where N is the total number of elements in all given sets.",
+			handler: setAlgebraCommand,
+		},
+		&command{
+			name: "SINTERSTORE", arity: -3, flags: flagWrite, firstKey: 1, lastKey: -1, step: 1,
+			group: "set", summary: "Stores the intersect of multiple sets in a key.", since: "1.0.0", complexity: "O(N*M) worst case where N is the cardinality of the smallest set and M is the number of sets.",
+			handler: setAlgebraCommand,
+		},
+		&command{
+			name: "SUNIONSTORE", arity: -3, flags: flagWrite, firstKey: 1, lastKey: -1, step: 1,
+			group: "set", summary: "Stores the union of multiple sets in a key.", since: "1.0.0", complexity: "O(N) where N is the total number of elements in all given sets.",
+			handler: setAlgebraCommand,
+		},
+		&command{
+			name: "SDIFFSTORE", arity: -3, flags: flagWrite, firstKey: 1, lastKey: -1, step: 1,
+			group: "set", summary: "Stores the difference of multiple sets in a key.", since: "1.0.0", complexity: "O(N) where N is the total number of elements in all given sets.",
+			handler: setAlgebraCommand,
+		},
+		&command{
+			name: "SINTERCARD", arity: -3, flags: flagReadonly | flagMovableKeys,
+			group: "set", summary: "Returns the number of members of the intersect of multiple sets.", since: "7.0.0", complexity: "O(N*M) worst case where N is the cardinality of the smallest set and M is the number of sets.",
+			handler: sinterCardCommand,
+			getKeys: func(args []string) []string { return numKeysArgs(args, 1) },
+		},
+		&command{
+			name: "SMOVE", arity: 4, flags: flagWrite | flagFast, firstKey: 1, lastKey: 2, step: 1,
+			group: "set", summary: "Moves a member from one set to another.", since: "1.0.0", complexity: "O(1)",
+			handler: smoveCommand,
+		},
+	)
+}
+
+// setAt returns the set stored at key, or nil when the key does not exist. get reads the key, and is
+// GetForWrite when the command modifies the set. wrongType is set when the key holds a value of another type.
+func setAt(get func(key string) (types.CustomValue, bool), key string) (set *datatypes.Set, wrongType bool) {
+	value, exists := get(key)
+	if !exists {
+		return nil, false
+	}
+	if value.Type != types.ValueTypeSet {
+		return nil, true
+	}
+	return value.Set, false
+}
+
+// newSetValue returns a value holding the set, without expiration time.
+func newSetValue(set *datatypes.Set) types.CustomValue {
+	return types.CustomValue{Type: types.ValueTypeSet, Set: set, ValueExpiration: -1}
+}
+
+// setsAt returns the sets stored at keys, nil standing for a missing key, read with get like setAt.
+// wrongType is set when one of the keys holds a value of another type.
+func setsAt(get func(key string) (types.CustomValue, bool), keys []string) (sets []*datatypes.Set, wrongType bool) {
+	sets = make([]*datatypes.Set, len(keys))
+	for i, key := range keys {
+		if sets[i], wrongType = setAt(get, key); wrongType {
+			return nil, true
+		}
+	}
+	return sets, false
+}
+
+func saddCommand(args []string, cache keyspace.Keyspace) []byte {
+	// SADD <key> <member> [member ...]
+	key := args[1]
+
+	var reply []byte
+	var changes int64
+	cache.Atomically([]string{key}, func(tx keyspace.Tx) {
+		set, wrongType := setAt(tx.GetForWrite, key)
+		if wrongType {
+			reply = errorReply(errWrongType)
+			return
+		}
+		if set == nil {
+			set = datatypes.NewSet()
+			tx.Set(key, newSetValue(set))
+		}
+
+		added := int64(0)
+		for _, member := range args[2:] {
+			if set.Add(member) {
+				added++
+			}
+		}
+		changes = added
+		reply = integer(added)
+	})
+
+	persistence.AddChanges(changes)
+	return reply
+}
+
+func sremCommand(args []string, cache keyspace.Keyspace) []byte {
+	// SREM <key> <member> [member ...]
+	key := args[1]
+
+	var reply []byte
+	var changes int64
+	cache.Atomically([]string{key}, func(tx keyspace.Tx) {
+		set, wrongType := setAt(tx.GetForWrite, key)
+		switch {
+		case wrongType:
+			reply = errorReply(errWrongType)
+			return
+		case set == nil:
+			reply = integer(0)
+			return
+		}
+
+		removed := int64(0)
+		for _, member := range args[2:] {
+			if set.Remove(member) {
+				removed++
+			}
+		}
+		// A set is deleted along with its last member
+		if set.Len() == 0 {
+			tx.Delete(key)
+		}
+		changes = removed
+		reply = integer(removed)
+	})
+
+	persistence.AddChanges(changes)
+	return reply
+}
+
+func smembersCommand(args []string, cache keyspace.Keyspace) []byte {
+	// SMEMBERS <key>
+	var reply []byte
+	cache.View([]string{args[1]}, func(tx keyspace.ReadTx) {
+		set, wrongType := setAt(tx.Get, args[1])
+		switch {
+		case wrongType:
+			reply = errorReply(errWrongType)
+		case set == nil:
+			reply = arrayHeader(0)
+		default:
+			reply = bulkStringArray(set.Members())
+		}
+	})
+
+	return reply
+}
+
+func sismemberCommand(args []string, cache keyspace.Keyspace) []byte {
+	// SISMEMBER <key> <member>
+	// SMISMEMBER <key> <member> [member ...]
+	multi := strings.ToUpper(args[0]) == "SMISMEMBER"
+
+	var reply []byte
+	cache.View([]string{args[1]}, func(tx keyspace.ReadTx) {
+		set, wrongType := setAt(tx.Get, args[1])
+		if wrongType {
+			reply = errorReply(errWrongType)
+			return
+		}
+
+		if multi {
+			reply = arrayHeader(len(args) - 2)
+		}
+		for _, member := range args[2:] {
+			if set != nil && set.Contains(member) {
+				reply = append(reply, integer(1)...)
+			} else {
+				reply = append(reply, integer(0)...)
+			}
+		}
+	})
+
+	return reply
+}
+
+func scardCommand(args []string, cache keyspace.Keyspace) []byte {
+	// SCARD <key>
+	var reply []byte
+	cache.View([]string{args[1]}, func(tx keyspace.ReadTx) {
+		set, wrongType := setAt(tx.Get, args[1])
+		switch {
+		case wrongType:
+			reply = errorReply(errWrongType)
+		case set == nil:
+			reply = integer(0)
+		default:
+			reply = integer(int64(set.Len()))
+		}
+	})
+
+	return reply
+}
+
+func srandMemberCommand(args []string, cache keyspace.Keyspace) []byte {
+	// SPOP <key> [count]
+	// SRANDMEMBER <key> [count]
+	pop := strings.ToUpper(args[0]) == "SPOP"
+	key := args[1]
+	if len(args) > 3 {
+		return errorReply("ERR syntax error")
+	}
+
+	// Without a count a single member is replied as a bulk string, with one the members are replied as an
+	// array. SPOP only accepts positive counts, while a negative count lets SRANDMEMBER repeat members.
+	hasCount := len(args) == 3
+	count := int64(1)
+	if hasCount {
+		var ok bool
+		if count, ok = parseInteger(args[2]); !ok {
+			if pop {
+				return errorReply("ERR value is out of range, must be positive")
+			}
+			return errorReply(errNotInteger)
+		}
+		if pop && count < 0 {
+			return errorReply("ERR value is out of range, must be positive")
+		}
+		// A negative count is not bounded by the size of the set
+		if count < -maxRandomCount {
+			return errorReply("ERR value is out of range")
+		}
+	}
+
+	// sample replies random members of the set at key read with get, and returns the set and, for SPOP, the
+	// members to remove. They are removed once they are all picked, since removing one moves the others.
+	var reply []byte
+	sample := func(get func(key string) (types.CustomValue, bool)) (*datatypes.Set, []string) {
+		set, wrongType := setAt(get, key)
+		switch {
+		case wrongType:
+			reply = errorReply(errWrongType)
+			return nil, nil
+		case set == nil && hasCount:
+			reply = arrayHeader(0)
+			return nil, nil
+		case set == nil:
+			reply = nullBulkString()
+			return nil, nil
+		}
+
+		if !hasCount {
+			member := set.Member(rand.IntN(set.Len()))
+			reply = bulkString(member)
+			return set, []string{member}
+		}
+
+		// A positive count picks distinct members, a negative one may pick the same member several times
+		n := int(-count)
+		if count >= 0 {
+			n = int(min(count, int64(set.Len())))
+		}
+		reply = arrayHeader(n)
+		var picked []string
+		randomIndices(set.Len(), count, func(i int) {
+			member := set.Member(i)
+			reply = append(reply, bulkString(member)...)
+			if pop {
+				picked = append(picked, member)
+			}
+		})
+		return set, picked
+	}
+
+	if !pop {
+		cache.View([]string{key}, func(tx keyspace.ReadTx) {
+			sample(tx.Get)
+		})
+		return reply
+	}
+
+	var changes int64
+	cache.Atomically([]string{key}, func(tx keyspace.Tx) {
+		set, popped := sample(tx.GetForWrite)
+		if set == nil {
+			return
+		}
+		for _, member := range popped {
+			set.Remove(member)
+		}
+		if set.Len() == 0 {
+			tx.Delete(key)
+		}
+		changes = int64(len(popped))
+	})
+
+	persistence.AddChanges(changes)
+	return reply
+}
+
+func setAlgebraCommand(args []string, cache keyspace.Keyspace) []byte {
+	// SINTER <key> [key ...], SUNION <key> [key ...] or SDIFF <key> [key ...]
+	// SINTERSTORE <destination> <key> [key ...], SUNIONSTORE <destination> <key> [key ...] or
+	// SDIFFSTORE <destination> <key> [key ...]
+	name := strings.ToUpper(args[0])
+	op, store := strings.CutSuffix(name, "STORE")
+
+	var reply []byte
+	if !store {
+		cache.View(args[1:], func(tx keyspace.ReadTx) {
+			sets, wrongType := setsAt(tx.Get, args[1:])
+			if wrongType {
+				reply = errorReply(errWrongType)
+				return
+			}
+			reply = bulkStringArray(setAlgebra(op, sets, -1).Members())
+		})
+		return reply
+	}
+
+	dst, sources := args[1], args[2:]
+	var changes int64
+	cache.Atomically(args[1:], func(tx keyspace.Tx) {
+		// The sources are only read, the result is a new set
+		sets, wrongType := setsAt(tx.Get, sources)
+		if wrongType {
+			reply = errorReply(errWrongType)
+			return
+		}
+
+		// An empty result only changes the destination when it deletes it
+		result := setAlgebra(op, sets, -1)
+		if result.Len() > 0 {
+			tx.Set(dst, newSetValue(result))
+			changes = 1
+		} else if tx.Delete(dst) {
+			changes = 1
+		}
+		reply = integer(int64(result.Len()))
+	})
+
+	persistence.AddChanges(changes)
+	return reply
+}
+
+// setAlgebra computes the SINTER, SUNION or SDIFF of the sets, nil standing for a missing key. An
+// intersection stops once it holds limit members, unless limit is negative.
+func setAlgebra(op string, sets []*datatypes.Set, limit int) *datatypes.Set {
+	result := datatypes.NewSet()
+
+	switch op {
+	case "SINTER":
+		// The smallest set is scanned, and a missing key makes the intersection empty
+		smallest := sets[0]
+		for _, set := range sets {
+			if set == nil {
+				return result
+			}
+			if set.Len() < smallest.Len() {
+				smallest = set
+			}
+		}
+		smallest.Iterate(func(member string) bool {
+			for _, set := range sets {
+				if !set.Contains(member) {
+					return true
+				}
+			}
+			result.Add(member)
+			return limit < 0 || result.Len() < limit
+		})
+	case "SUNION":
+		for _, set := range sets {
+			if set == nil {
+				continue
+			}
+			set.Iterate(func(member string) bool {
+				result.Add(member)
+				return true
+			})
+		}
+	case "SDIFF":
+		if sets[0] == nil {
+			return result
+		}
+		sets[0].Iterate(func(member string) bool {
+			for _, set := range sets[1:] {
+				if set != nil && set.Contains(member) {
+					return true
+				}
+			}
+			result.Add(member)
+			return true
+		})
+	}
+
+	return result
+}
+
+func sinterCardCommand(args []string, cache keyspace.Keyspace) []byte {
+	// SINTERCARD <numkeys> <key> [key ...] [LIMIT limit]
+	numKeys, ok := parseInteger(args[1])
+	if !ok {
+		return errorReply(errNotInteger)
+	}
+	if numKeys <= 0 {
+		return errorReply("ERR numkeys should be greater than 0")
+	}
+	if numKeys > int64(len(args)-2) {
+		return errorReply("ERR Number of keys can't be greater than number of args")
+	}
+	keys := args[2 : 2+numKeys]
+
+	// A limit of 0 means no limit
+	limit := int64(0)
+	for i := 2 + int(numKeys); i < len(args); i += 2 {
+		if !strings.EqualFold(args[i], "LIMIT") || i+1 == len(args) {
+			return errorReply("ERR syntax error")
+		}
+		if limit, ok = parseInteger(args[i+1]); !ok {
+			return errorReply(errNotInteger)
+		}
+		if limit < 0 {
+			return errorReply("ERR LIMIT can't be negative")
+		}
+	}
+	if limit == 0 {
+		limit = -1
+	}
+
+	var reply []byte
+	cache.View(keys, func(tx keyspace.ReadTx) {
+		sets, wrongType := setsAt(tx.Get, keys)
+		if wrongType {
+			reply = errorReply(errWrongType)
+			return
+		}
+		reply = integer(int64(setAlgebra("SINTER", sets, int(limit)).Len()))
+	})
+
+	return reply
+}
+
+func smoveCommand(args []string, cache keyspace.Keyspace) []byte {
+	// SMOVE <source> <destination> <member>
+	src, dst, member := args[1], args[2], args[3]
+
+	var reply []byte
+	var changes int64
+	cache.Atomically([]string{src, dst}, func(tx keyspace.Tx) {
+		sets, wrongType := setsAt(tx.GetForWrite, []string{src, dst})
+		if wrongType {
+			reply = errorReply(errWrongType)
+			return
+		}
+		source, destination := sets[0], sets[1]
+
+		if source == nil || !source.Contains(member) {
+			reply = integer(0)
+			return
+		}
+		// Moving a member to its own set leaves the set untouched
+		if src == dst {
+			reply = integer(1)
+			return
+		}
+
+		source.Remove(member)
+		if source.Len() == 0 {
+			tx.Delete(src)
+		}
+		if destination == nil {
+			destination = datatypes.NewSet()
+			tx.Set(dst, newSetValue(destination))
+		}
+		destination.Add(member)
+		changes = 1
+		reply = integer(1)
+	})
+
+	persistence.AddChanges(changes)
+	return reply
+}
@@ -0,0 +1,547 @@
+package handler
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/keyspace"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/persistence"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/types"
+)
+
+func init() {
+	register(
+		&command{
+			name: "INCR", arity: 2, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "string", summary: "Increments the integer value of a key by one. Uses 0 as initial value if the key doesn't exist.", since: "1.0.0", complexity: "O(1)",
+			handler: incrCommand,
+		},
+		&command{
+			name: "DECR", arity: 2, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "string", summary: "Decrements the integer value of a key by one. Uses 0 as initial value if the key doesn't exist.", since: "1.0.0", complexity: "O(1)",
+			handler: incrCommand,
+		},
+		&command{
+			name: "INCRBY", arity: 3, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "string", summary: "Increments the integer value of a key by a number. Uses 0 as initial value if the key doesn't exist.", since: "1.0.0", complexity: "O(1)",
+			handler: incrCommand,
+		},
+		&command{
+			name: "DECRBY", arity: 3, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "string", summary: "Decrements a number from the integer value of a key. Uses 0 as initial value if the key doesn't exist.", since: "1.0.0", complexity: "O(1)",
+			handler: incrCommand,
+		},
+		&command{
+			name: "INCRBYFLOAT", arity: 3, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "string", summary: "Increment the floating point value of a key by a number. Uses 0 as initial value if the key doesn't exist.", since: "2.6.0", complexity: "O(1)",
+			handler: incrByFloatCommand,
+		},
+		&command{
+			name: "APPEND", arity: 3, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "string", summary: "Appends a string to the value of a key. Creates the key if it doesn't exist.", since: "2.0.0", complexity: "O(1). The amortized time complexity is O(1) assuming the appended value is small and the already present value is of any size, since the dynamic string library used by Redis will double the free space available on every reallocation.",
+			handler: appendCommand,
+		},
+		&command{
+			name: "STRLEN", arity: 2, flags: flagReadonly | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "string", summary: "Returns the length of a string value.", since: "2.2.0", complexity: "O(1)",
+			handler: strlenCommand,
+		},
+		&command{
+			name: "GETRANGE", arity: 4, flags: flagReadonly, firstKey: 1, lastKey: 1, step: 1,
+			group: "string", summary: "Returns a substring of the string stored at a key.", since: "2.4.0", complexity: "O(N) where N is the length of the returned string. The complexity is ultimately determined by the returned length, but because creating a substring from an existing string is very cheap, it can be considered O(1) for small strings.",
+			handler: getRangeCommand,
+		},
+		&command{
+			name: "SETRANGE", arity: 4, flags: flagWrite, firstKey: 1, lastKey: 1, step: 1,
+			group: "string", summary: "Overwrites a part of a string value with another by an offset. Creates the key if it doesn't exist.", since: "2.2.0", complexity: "O(1), not counting the time taken to copy the new string in place. Usually, this string is very small so the amortized complexity is O(1). Otherwise, complexity is O(M) with M being the length of the value argument.",
+			handler: setRangeCommand,
+		},
+		&command{
+			name: "GETDEL", arity: 2, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "string", summary: "Returns the string value of a key after deleting the key.", since: "6.2.0", complexity: "O(1)",
+			handler: getDelCommand,
+		},
+		&command{
+			name: "GETEX", arity: -2, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "string", summary: "Returns the string value of a key after setting its expiration time.", since: "6.2.0", complexity: "O(1)",
+			handler: getExCommand,
+		},
+		&command{
+			name: "MSET", arity: -3, flags: flagWrite, firstKey: 1, lastKey: -1, step: 2,
+			group: "string", summary: "Atomically creates or modifies the string values of one or more keys.", since: "1.0.1", complexity: "O(N) where N is the number of keys to set.",
+			handler: msetCommand,
+		},
+		&command{
+			name: "MSETNX", arity: -3, flags: flagWrite, firstKey: 1, lastKey: -1, step: 2,
+			group: "string", summary: "Atomically modifies the string values of one or more keys only when all keys don't exist.", since: "1.0.1", complexity: "O(N) where N is the number of keys to set.",
+			handler: msetCommand,
+		},
+		&command{
+			name: "MGET", arity: -2, flags: flagReadonly | flagFast, firstKey: 1, lastKey: -1, step: 1,
+			group: "string", summary: "Atomically returns the string values of one or more keys.", since: "1.0.0", complexity: "O(N) where N is the number of keys to retrieve.",
+			handler: mgetCommand,
+		},
+		&command{
+			name: "SETNX", arity: 3, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "string", summary: "Set the string value of a key only when the key doesn't exist.", since: "1.0.0", complexity: "O(1)",
+			handler: setNXCommand,
+		},
+		&command{
+			name: "SETEX", arity: 4, flags: flagWrite, firstKey: 1, lastKey: 1, step: 1,
+			group: "string", summary: "Sets the string value and expiration time of a key. Creates the key if it doesn't exist.", since: "2.0.0", complexity: "O(1)",
+			handler: setExCommand,
+		},
+		&command{
+			name: "PSETEX", arity: 4, flags: flagWrite, firstKey: 1, lastKey: 1, step: 1,
+			group: "string", summary: "Sets both string value and expiration time in milliseconds of a key. The key is created if it doesn't exist.", since: "2.6.0", complexity: "O(1)",
+			handler: setExCommand,
+		},
+	)
+}
+
+// maxStringLength is the largest string SETRANGE and APPEND can build, the default proto-max-bulk-len of Redis.
+const maxStringLength = 512 * 1024 * 1024
+
+// parseInteger parses s as a signed 64-bit integer, rejecting the representations Redis does not accept
+// as integers, such as a leading '+', leading zeros or surrounding spaces.
+func parseInteger(s string) (int64, bool) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || strconv.FormatInt(n, 10) != s {
+		return 0, false
+	}
+	return n, true
+}
+
+// parseFloat parses s as a float, rejecting NaN.
+func parseFloat(s string) (float64, bool) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) {
+		return 0, false
+	}
+	return f, true
+}
+
+// formatFloat formats f the way Redis replies floats: the shortest representation, without an exponent.
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func incrCommand(args []string, cache keyspace.Keyspace) []byte {
+	// INCR <key>, DECR <key>, INCRBY <key> <increment> or DECRBY <key> <decrement>
+	name := strings.ToUpper(args[0])
+	key := args[1]
+
+	delta := int64(1)
+	if len(args) == 3 {
+		var ok bool
+		if delta, ok = parseInteger(args[2]); !ok {
+			return errorReply(errNotInteger)
+		}
+	}
+	if name == "DECR" || name == "DECRBY" {
+		if delta == math.MinInt64 {
+			return errorReply("ERR decrement would overflow")
+		}
+		delta = -delta
+	}
+
+	var reply []byte
+	var changes int64
+	cache.Atomically([]string{key}, func(tx keyspace.Tx) {
+		value, exists := tx.Get(key)
+		if exists && value.Type != types.ValueTypeString {
+			reply = errorReply(errWrongType)
+			return
+		}
+		current := int64(0)
+		if exists {
+			var ok bool
+			if current, ok = parseInteger(value.Value); !ok {
+				reply = errorReply(errNotInteger)
+				return
+			}
+		} else {
+			value = types.CustomValue{ValueExpiration: -1}
+		}
+
+		if (delta > 0 && current > math.MaxInt64-delta) || (delta < 0 && current < math.MinInt64-delta) {
+			reply = errorReply("ERR increment or decrement would overflow")
+			return
+		}
+		current += delta
+
+		// The key keeps its expiration time
+		value.Value = strconv.FormatInt(current, 10)
+		tx.Set(key, value)
+		changes = 1
+		reply = integer(current)
+	})
+
+	persistence.AddChanges(changes)
+	return reply
+}
+
+func incrByFloatCommand(args []string, cache keyspace.Keyspace) []byte {
+	// INCRBYFLOAT <key> <increment>
+	key := args[1]
+
+	delta, ok := parseFloat(args[2])
+	if !ok {
+		return errorReply("ERR value is not a valid float")
+	}
+
+	var reply []byte
+	var changes int64
+	cache.Atomically([]string{key}, func(tx keyspace.Tx) {
+		value, exists := tx.Get(key)
+		if exists && value.Type != types.ValueTypeString {
+			reply = errorReply(errWrongType)
+			return
+		}
+		current := 0.0
+		if exists {
+			if current, ok = parseFloat(value.Value); !ok {
+				reply = errorReply("ERR value is not a valid float")
+				return
+			}
+		} else {
+			value = types.CustomValue{ValueExpiration: -1}
+		}
+
+		current += delta
+		if math.IsNaN(current) || math.IsInf(current, 0) {
+			reply = errorReply("ERR increment would produce NaN or Infinity")
+			return
+		}
+
+		// The key keeps its expiration time
+		value.Value = formatFloat(current)
+		tx.Set(key, value)
+		changes = 1
+		reply = bulkString(value.Value)
+	})
+
+	persistence.AddChanges(changes)
+	return reply
+}
+
+func appendCommand(args []string, cache keyspace.Keyspace) []byte {
+	// APPEND <key> <value>
+	key := args[1]
+
+	var reply []byte
+	var changes int64
+	cache.Atomically([]string{key}, func(tx keyspace.Tx) {
+		value, exists := tx.Get(key)
+		if exists && value.Type != types.ValueTypeString {
+			reply = errorReply(errWrongType)
+			return
+		}
+		if !exists {
+			value = types.CustomValue{ValueExpiration: -1}
+		}
+		if len(value.Value)+len(args[2]) > maxStringLength {
+			reply = errorReply("ERR string exceeds maximum allowed size (proto-max-bulk-len)")
+			return
+		}
+		// The key keeps its expiration time
+		value.Value += args[2]
+		tx.Set(key, value)
+		changes = 1
+		reply = integer(int64(len(value.Value)))
+	})
+
+	persistence.AddChanges(changes)
+	return reply
+}
+
+func strlenCommand(args []string, cache keyspace.Keyspace) []byte {
+	// STRLEN <key>
+	value, _ := cache.Get(args[1])
+	if value.Type != types.ValueTypeString {
+		return errorReply(errWrongType)
+	}
+	return integer(int64(len(value.Value)))
+}
+
+func getRangeCommand(args []string, cache keyspace.Keyspace) []byte {
+	// GETRANGE <key> <start> <end>
+	start, ok := parseInteger(args[2])
+	if !ok {
+		return errorReply(errNotInteger)
+	}
+	end, ok := parseInteger(args[3])
+	if !ok {
+		return errorReply(errNotInteger)
+	}
+
+	value, _ := cache.Get(args[1])
+	if value.Type != types.ValueTypeString {
+		return errorReply(errWrongType)
+	}
+	s := value.Value
+	length := int64(len(s))
+
+	// A range with both ends negative and reversed is empty, whatever the length of the string
+	if start < 0 && end < 0 && start > end {
+		return bulkString("")
+	}
+	// Negative offsets count from the end of the string
+	if start < 0 {
+		start = max(length+start, 0)
+	}
+	if end < 0 {
+		end = max(length+end, 0)
+	}
+	end = min(end, length-1)
+	if length == 0 || start > end {
+		return bulkString("")
+	}
+
+	return bulkString(s[start : end+1])
+}
+
+func setRangeCommand(args []string, cache keyspace.Keyspace) []byte {
+	// SETRANGE <key> <offset> <value>
+	key := args[1]
+	patch := args[3]
+
+	offset, ok := parseInteger(args[2])
+	if !ok {
+		return errorReply(errNotInteger)
+	}
+	if offset < 0 {
+		return errorReply("ERR offset is out of range")
+	}
+	if len(patch) > 0 && offset+int64(len(patch)) > maxStringLength {
+		return errorReply("ERR string exceeds maximum allowed size (proto-max-bulk-len)")
+	}
+
+	var reply []byte
+	var changes int64
+	cache.Atomically([]string{key}, func(tx keyspace.Tx) {
+		value, exists := tx.Get(key)
+		if exists && value.Type != types.ValueTypeString {
+			reply = errorReply(errWrongType)
+			return
+		}
+		// An empty value does not create the key nor change the string
+		if len(patch) == 0 {
+			reply = integer(int64(len(value.Value)))
+			return
+		}
+		if !exists {
+			value = types.CustomValue{ValueExpiration: -1}
+		}
+
+		// The string is padded with zero bytes up to the offset
+		buf := []byte(value.Value)
+		if end := int(offset) + len(patch); end > len(buf) {
+			buf = append(buf, make([]byte, end-len(buf))...)
+		}
+		copy(buf[offset:], patch)
+
+		value.Value = string(buf)
+		tx.Set(key, value)
+		changes = 1
+		reply = integer(int64(len(buf)))
+	})
+
+	persistence.AddChanges(changes)
+	return reply
+}
+
+func getDelCommand(args []string, cache keyspace.Keyspace) []byte {
+	// GETDEL <key>
+	key := args[1]
+
+	var reply []byte
+	var changes int64
+	cache.Atomically([]string{key}, func(tx keyspace.Tx) {
+		value, exists := tx.Get(key)
+		if !exists {
+			reply = nullBulkString()
+			return
+		}
+		if value.Type != types.ValueTypeString {
+			reply = errorReply(errWrongType)
+			return
+		}
+		tx.Delete(key)
+		changes = 1
+		reply = bulkString(value.Value)
+	})
+
+	persistence.AddChanges(changes)
+	return reply
+}
+
+func getExCommand(args []string, cache keyspace.Keyspace) []byte {
+	// GETEX <key> [EX seconds | PX milliseconds | EXAT unix-time-seconds | PXAT unix-time-milliseconds | PERSIST]
+	key := args[1]
+	now := time.Now().UnixMilli()
+
+	// expire is the new expiration time, 0 to keep the current one, or -1 to remove it
+	expire := int64(0)
+	for i := 2; i < len(args); i++ {
+		switch option := strings.ToUpper(args[i]); option {
+		case "PERSIST":
+			if expire != 0 {
+				return errorReply("ERR syntax error")
+			}
+			expire = -1
+		case "EX", "PX", "EXAT", "PXAT":
+			if expire != 0 || i+1 == len(args) {
+				return errorReply("ERR syntax error")
+			}
+			i++
+			n, ok := parseInteger(args[i])
+			if !ok {
+				return errorReply(errNotInteger)
+			}
+			var errReply []byte
+			if expire, errReply = parseExpireTime(option, n, now, "getex"); errReply != nil {
+				return errReply
+			}
+		default:
+			return errorReply("ERR syntax error")
+		}
+	}
+
+	var reply []byte
+	var changes int64
+	cache.Atomically([]string{key}, func(tx keyspace.Tx) {
+		value, exists := tx.Get(key)
+		if !exists {
+			reply = nullBulkString()
+			return
+		}
+		if value.Type != types.ValueTypeString {
+			reply = errorReply(errWrongType)
+			return
+		}
+		reply = bulkString(value.Value)
+
+		switch {
+		case expire == 0:
+			return
+		case expire > 0 && expire <= now && !cache.Loading():
+			// An expiration time in the past deletes the key right away, except while the append-only file
+			// is loaded, like in EXPIRE
+			tx.Delete(key)
+		default:
+			value.ValueExpiration = expire
+			tx.Set(key, value)
+		}
+		changes = 1
+	})
+
+	persistence.AddChanges(changes)
+	return reply
+}
+
+func msetCommand(args []string, cache keyspace.Keyspace) []byte {
+	// MSET <key> <value> [key value ...]
+	// MSETNX <key> <value> [key value ...]
+	name := strings.ToUpper(args[0])
+	if len(args)%2 == 0 {
+		return wrongNumberOfArguments(name)
+	}
+
+	keys := make([]string, 0, len(args)/2)
+	for i := 1; i < len(args); i += 2 {
+		keys = append(keys, args[i])
+	}
+
+	// All the keys are set at once, so no client observes some of them set and not the others
+	var reply []byte
+	var changes int64
+	cache.Atomically(keys, func(tx keyspace.Tx) {
+		if name == "MSETNX" {
+			for _, key := range keys {
+				if _, exists := tx.Get(key); exists {
+					reply = integer(0)
+					return
+				}
+			}
+		}
+
+		for i := 1; i < len(args); i += 2 {
+			tx.Set(args[i], types.CustomValue{Value: args[i+1], ValueExpiration: -1})
+		}
+		changes = int64(len(keys))
+
+		if name == "MSETNX" {
+			reply = integer(1)
+		} else {
+			reply = simpleString("OK")
+		}
+	})
+
+	persistence.AddChanges(changes)
+	return reply
+}
+
+func mgetCommand(args []string, cache keyspace.Keyspace) []byte {
+	// MGET <key> [key ...]
+	reply := arrayHeader(len(args) - 1)
+	cache.View(args[1:], func(tx keyspace.ReadTx) {
+		for _, key := range args[1:] {
+			// Keys holding another type than string are replied as missing
+			if value, exists := tx.Get(key); exists && value.Type == types.ValueTypeString {
+				reply = append(reply, bulkString(value.Value)...)
+			} else {
+				reply = append(reply, nullBulkString()...)
+			}
+		}
+	})
+
+	return reply
+}
+
+func setNXCommand(args []string, cache keyspace.Keyspace) []byte {
+	// SETNX <key> <value>
+	key := args[1]
+
+	set := false
+	cache.Atomically([]string{key}, func(tx keyspace.Tx) {
+		if _, exists := tx.Get(key); exists {
+			return
+		}
+		tx.Set(key, types.CustomValue{Value: args[2], ValueExpiration: -1})
+		set = true
+	})
+
+	if set {
+		persistence.AddChanges(1)
+		return integer(1)
+	}
+	return integer(0)
+}
+
+func setExCommand(args []string, cache keyspace.Keyspace) []byte {
+	// SETEX <key> <seconds> <value>
+	// PSETEX <key> <milliseconds> <value>
+	name := strings.ToUpper(args[0])
+
+	n, ok := parseInteger(args[2])
+	if !ok {
+		return errorReply(errNotInteger)
+	}
+	unit := "EX"
+	if name == "PSETEX" {
+		unit = "PX"
+	}
+	expire, errReply := parseExpireTime(unit, n, time.Now().UnixMilli(), strings.ToLower(name))
+	if errReply != nil {
+		return errReply
+	}
+	// The command is logged with the absolute expiration time computed here, see propagated
+	args[2] = strconv.FormatInt(expire, 10)
+
+	cache.Set(args[1], types.CustomValue{Value: args[3], ValueExpiration: expire})
+	persistence.AddChanges(1)
+	return simpleString("OK")
+}
@@ -0,0 +1,763 @@
+package handler
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/datatypes"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/keyspace"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/persistence"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/types"
+)
+
+func init() {
+	register(
+		&command{
+			name: "ZADD", arity: -4, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "sorted-set", summary: "Adds one or more members to a sorted set, or updates their scores. Creates the key if it doesn't exist.", since: "1.2.0", complexity: "O(log(N)) for each item added, where N is the number of elements in the sorted set.",
+			handler: zaddCommand,
+		},
+		&command{
+			name: "ZINCRBY", arity: 4, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "sorted-set", summary: "Increments the score of a member in a sorted set.", since: "1.2.0", complexity: "O(log(N)) where N is the number of elements in the sorted set.",
+			handler: zincrByCommand,
+		},
+		&command{
+			name: "ZREM", arity: -3, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "sorted-set", summary: "Removes one or more members from a sorted set. Deletes the sorted set if all members were removed.", since: "1.2.0", complexity: "O(M*log(N)) with N being the number of elements in the sorted set and M the number of elements to be removed.",
+			handler: zremCommand,
+		},
+		&command{
+			name: "ZSCORE", arity: 3, flags: flagReadonly | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "sorted-set", summary: "Returns the score of a member in a sorted set.", since: "1.2.0", complexity: "O(1)",
+			handler: zscoreCommand,
+		},
+		&command{
+			name: "ZCARD", arity: 2, flags: flagReadonly | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "sorted-set", summary: "Returns the number of members in a sorted set.", since: "1.2.0", complexity: "O(1)",
+			handler: zcardCommand,
+		},
+		&command{
+			name: "ZRANGE", arity: -4, flags: flagReadonly, firstKey: 1, lastKey: 1, step: 1,
+			group: "sorted-set", summary: "Returns members in a sorted set within a range of indexes.", since: "1.2.0", complexity: "O(log(N)+M) with N being the number of elements in the sorted set and M the number of elements returned.",
+			handler: zrangeCommand,
+		},
+		&command{
+			name: "ZRANK", arity: -3, flags: flagReadonly | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "sorted-set", summary: "Returns the index of a member in a sorted set ordered by ascending scores.", since: "2.0.0", complexity: "O(log(N))",
+			handler: zrankCommand,
+		},
+		&command{
+			name: "ZREVRANK", arity: -3, flags: flagReadonly | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "sorted-set", summary: "Returns the index of a member in a sorted set ordered by descending scores.", since: "2.0.0", complexity: "O(log(N))",
+			handler: zrankCommand,
+		},
+		&command{
+			name: "ZCOUNT", arity: 4, flags: flagReadonly | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "sorted-set", summary: "Returns the count of members in a sorted set that have scores within a range.", since: "2.0.0", complexity: "O(log(N)) with N being the number of elements in the sorted set.",
+			handler: zcountCommand,
+		},
+		&command{
+			name: "ZPOPMIN", arity: -2, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "sorted-set", summary: "Returns the lowest-scoring members from a sorted set after removing them. Deletes the sorted set if the last member was popped.", since: "5.0.0", complexity: "O(log(N)*M) with N being the number of elements in the sorted set, and M being the number of elements popped.",
+			handler: zpopCommand,
+		},
+		&command{
+			name: "ZPOPMAX", arity: -2, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1,
+			group: "sorted-set", summary: "Returns the highest-scoring members from a sorted set after removing them. Deletes the sorted set if the last member was popped.", since: "5.0.0", complexity: "O(log(N)*M) with N being the number of elements in the sorted set, and M being the number of elements popped.",
+			handler: zpopCommand,
+		},
+		&command{
+			name: "ZUNIONSTORE", arity: -4, flags: flagWrite | flagMovableKeys, firstKey: 1, lastKey: 1, step: 1,
+			group: "sorted-set", summary: "Stores the union of multiple sorted sets in a key.", since: "2.0.0", complexity: "O(N)+O(M log(M)) with N being the sum of the sizes of the input sorted sets, and M being the number of elements in the resulting sorted set.",
+			handler: zstoreCommand,
+			getKeys: zstoreKeys,
+		},
+		&command{
+			name: "ZINTERSTORE", arity: -4, flags: flagWrite | flagMovableKeys, firstKey: 1, lastKey: 1, step: 1,
+			group: "sorted-set", summary: "Stores the intersect of multiple sorted sets in a key.", since: "2.0.0", complexity: "O(N*K)+O(M*log(M)) worst case with N being the smallest input sorted set, K being the number of input sorted sets and M being the number of elements in the resulting sorted set.",
+			handler: zstoreCommand,
+			getKeys: zstoreKeys,
+		},
+	)
+}
+
+// zsetAt returns the sorted set stored at key, or nil when the key does not exist. get reads the key, and
+// is GetForWrite when the command modifies the sorted set. wrongType is set when the key holds a value of
+// another type.
+func zsetAt(get func(key string) (types.CustomValue, bool), key string) (zset *datatypes.SortedSet, wrongType bool) {
+	value, exists := get(key)
+	if !exists {
+		return nil, false
+	}
+	if value.Type != types.ValueTypeZSet {
+		return nil, true
+	}
+	return value.ZSet, false
+}
+
+// newZSetValue returns a value holding the sorted set, without expiration time.
+func newZSetValue(zset *datatypes.SortedSet) types.CustomValue {
+	return types.CustomValue{Type: types.ValueTypeZSet, ZSet: zset, ValueExpiration: -1}
+}
+
+// formatScore formats a score the way Redis replies it: the shortest representation that parses back to
+// the same float, switching to an exponent for very large or very small scores.
+func formatScore(score float64) string {
+	switch {
+	case math.IsInf(score, 1):
+		return "inf"
+	case math.IsInf(score, -1):
+		return "-inf"
+	case score != 0 && (math.Abs(score) >= 1e21 || math.Abs(score) < 1e-6):
+		return strconv.FormatFloat(score, 'e', -1, 64)
+	}
+	return strconv.FormatFloat(score, 'f', -1, 64)
+}
+
+// parseScore parses a score, accepting the infinities spelled the Redis way like "-inf" or "+inf".
+func parseScore(s string) (float64, bool) {
+	score, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(score) {
+		return 0, false
+	}
+	return score, true
+}
+
+// parseScoreBound parses the minimum or maximum of a range of scores, exclusive when prefixed by '('.
+func parseScoreBound(s string) (datatypes.ScoreBound, bool) {
+	bound := datatypes.ScoreBound{}
+	if strings.HasPrefix(s, "(") {
+		bound.Exclusive = true
+		s = s[1:]
+	}
+	var ok bool
+	bound.Value, ok = parseScore(s)
+	return bound, ok
+}
+
+// parseLexBound parses the minimum or maximum of a range of members: "-", "+", or a member prefixed by
+// '[' when inclusive or '(' when exclusive.
+func parseLexBound(s string) (datatypes.LexBound, bool) {
+	switch {
+	case s == "-":
+		return datatypes.LexBound{Inf: -1}, true
+	case s == "+":
+		return datatypes.LexBound{Inf: 1}, true
+	case strings.HasPrefix(s, "["):
+		return datatypes.LexBound{Value: s[1:]}, true
+	case strings.HasPrefix(s, "("):
+		return datatypes.LexBound{Value: s[1:], Exclusive: true}, true
+	}
+	return datatypes.LexBound{}, false
+}
+
+// zmemberArray encodes members as a RESP array, interleaving their scores when withScores is set.
+func zmemberArray(members []datatypes.ZMember, withScores bool) []byte {
+	n := len(members)
+	if withScores {
+		n *= 2
+	}
+	reply := arrayHeader(n)
+	for _, m := range members {
+		reply = append(reply, bulkString(m.Member)...)
+		if withScores {
+			reply = append(reply, bulkString(formatScore(m.Score))...)
+		}
+	}
+	return reply
+}
+
+func zaddCommand(args []string, cache keyspace.Keyspace) []byte {
+	// ZADD <key> [NX | XX] [GT | LT] [CH] [INCR] <score> <member> [score member ...]
+	key := args[1]
+
+	var nx, xx, gt, lt, ch, incr bool
+	i := 2
+options:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GT":
+			gt = true
+		case "LT":
+			lt = true
+		case "CH":
+			ch = true
+		case "INCR":
+			incr = true
+		default:
+			break options
+		}
+	}
+
+	pairs := args[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return errorReply("ERR syntax error")
+	}
+	if nx && xx {
+		return errorReply("ERR XX and NX options at the same time are not compatible")
+	}
+	if (gt && lt) || (gt && nx) || (lt && nx) {
+		return errorReply("ERR GT, LT, and/or NX options at the same time are not compatible")
+	}
+	if incr && len(pairs) > 2 {
+		return errorReply("ERR INCR option supports a single increment-element pair")
+	}
+
+	// Every score is checked before the sorted set is modified
+	scores := make([]float64, len(pairs)/2)
+	for j := range scores {
+		var ok bool
+		if scores[j], ok = parseScore(pairs[2*j]); !ok {
+			return errorReply("ERR value is not a valid float")
+		}
+	}
+
+	var reply []byte
+	var changes int64
+	cache.Atomically([]string{key}, func(tx keyspace.Tx) {
+		zset, wrongType := zsetAt(tx.GetForWrite, key)
+		if wrongType {
+			reply = errorReply(errWrongType)
+			return
+		}
+
+		added, changed := 0, 0
+		// incremented is the score of the member for INCR, or NaN when the conditions skipped it
+		incremented := math.NaN()
+		for j, score := range scores {
+			member := pairs[2*j+1]
+
+			var current float64
+			exists := false
+			if zset != nil {
+				current, exists = zset.Score(member)
+			}
+			if (nx && exists) || (xx && !exists) {
+				continue
+			}
+
+			if exists {
+				if incr {
+					score += current
+					if math.IsNaN(score) {
+						reply = errorReply("ERR resulting score is not a number (NaN)")
+						return
+					}
+				}
+				if (gt && score <= current) || (lt && score >= current) {
+					continue
+				}
+				if score != current {
+					zset.Add(member, score)
+					changed++
+				}
+			} else {
+				// The sorted set is only created once a member is added to it
+				if zset == nil {
+					zset = datatypes.NewSortedSet()
+					tx.Set(key, newZSetValue(zset))
+				}
+				zset.Add(member, score)
+				added++
+			}
+			incremented = score
+		}
+		changes = int64(added + changed)
+
+		switch {
+		case incr && math.IsNaN(incremented):
+			reply = nullBulkString()
+		case incr:
+			reply = bulkString(formatScore(incremented))
+		case ch:
+			reply = integer(int64(added + changed))
+		default:
+			reply = integer(int64(added))
+		}
+	})
+
+	persistence.AddChanges(changes)
+	return reply
+}
+
+func zincrByCommand(args []string, cache keyspace.Keyspace) []byte {
+	// ZINCRBY <key> <increment> <member>
+	key, member := args[1], args[3]
+	delta, ok := parseScore(args[2])
+	if !ok {
+		return errorReply("ERR value is not a valid float")
+	}
+
+	var reply []byte
+	var changes int64
+	cache.Atomically([]string{key}, func(tx keyspace.Tx) {
+		zset, wrongType := zsetAt(tx.GetForWrite, key)
+		if wrongType {
+			reply = errorReply(errWrongType)
+			return
+		}
+
+		score := delta
+		if zset != nil {
+			if current, exists := zset.Score(member); exists {
+				score += current
+			}
+		}
+		if math.IsNaN(score) {
+			reply = errorReply("ERR resulting score is not a number (NaN)")
+			return
+		}
+
+		if zset == nil {
+			zset = datatypes.NewSortedSet()
+			tx.Set(key, newZSetValue(zset))
+		}
+		zset.Add(member, score)
+		changes = 1
+		reply = bulkString(formatScore(score))
+	})
+
+	persistence.AddChanges(changes)
+	return reply
+}
+
+func zremCommand(args []string, cache keyspace.Keyspace) []byte {
+	// ZREM <key> <member> [member ...]
+	key := args[1]
+
+	var reply []byte
+	var changes int64
+	cache.Atomically([]string{key}, func(tx keyspace.Tx) {
+		zset, wrongType := zsetAt(tx.GetForWrite, key)
+		switch {
+		case wrongType:
+			reply = errorReply(errWrongType)
+			return
+		case zset == nil:
+			reply = integer(0)
+			return
+		}
+
+		removed := int64(0)
+		for _, member := range args[2:] {
+			if zset.Remove(member) {
+				removed++
+			}
+		}
+		// A sorted set is deleted along with its last member
+		if zset.Len() == 0 {
+			tx.Delete(key)
+		}
+		changes = removed
+		reply = integer(removed)
+	})
+
+	persistence.AddChanges(changes)
+	return reply
+}
+
+func zscoreCommand(args []string, cache keyspace.Keyspace) []byte {
+	// ZSCORE <key> <member>
+	reply := nullBulkString()
+	cache.View([]string{args[1]}, func(tx keyspace.ReadTx) {
+		zset, wrongType := zsetAt(tx.Get, args[1])
+		if wrongType {
+			reply = errorReply(errWrongType)
+			return
+		}
+		if zset == nil {
+			return
+		}
+		if score, ok := zset.Score(args[2]); ok {
+			reply = bulkString(formatScore(score))
+		}
+	})
+
+	return reply
+}
+
+func zcardCommand(args []string, cache keyspace.Keyspace) []byte {
+	// ZCARD <key>
+	var reply []byte
+	cache.View([]string{args[1]}, func(tx keyspace.ReadTx) {
+		zset, wrongType := zsetAt(tx.Get, args[1])
+		switch {
+		case wrongType:
+			reply = errorReply(errWrongType)
+		case zset == nil:
+			reply = integer(0)
+		default:
+			reply = integer(int64(zset.Len()))
+		}
+	})
+
+	return reply
+}
+
+func zrangeCommand(args []string, cache keyspace.Keyspace) []byte {
+	// ZRANGE <key> <start> <stop> [BYSCORE | BYLEX] [REV] [LIMIT offset count] [WITHSCORES]
+	var byScore, byLex, rev, limit, withScores bool
+	offset, count := int64(0), int64(-1)
+	for i := 4; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "BYSCORE":
+			byScore = true
+		case "BYLEX":
+			byLex = true
+		case "REV":
+			rev = true
+		case "WITHSCORES":
+			withScores = true
+		case "LIMIT":
+			if i+2 >= len(args) {
+				return errorReply("ERR syntax error")
+			}
+			var ok bool
+			if offset, ok = parseInteger(args[i+1]); !ok {
+				return errorReply(errNotInteger)
+			}
+			if count, ok = parseInteger(args[i+2]); !ok {
+				return errorReply(errNotInteger)
+			}
+			limit = true
+			i += 2
+		default:
+			return errorReply("ERR syntax error")
+		}
+	}
+	if byScore && byLex {
+		return errorReply("ERR syntax error")
+	}
+	if limit && !byScore && !byLex {
+		return errorReply("ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+	}
+	if withScores && byLex {
+		return errorReply("ERR syntax error, WITHSCORES not supported in combination with BYLEX")
+	}
+
+	// With REV, the range is given from the maximum to the minimum
+	lo, hi := args[2], args[3]
+	if rev && (byScore || byLex) {
+		lo, hi = hi, lo
+	}
+
+	var query func(zset *datatypes.SortedSet) []datatypes.ZMember
+	switch {
+	case byScore:
+		min, okMin := parseScoreBound(lo)
+		max, okMax := parseScoreBound(hi)
+		if !okMin || !okMax {
+			return errorReply("ERR min or max is not a float")
+		}
+		query = func(zset *datatypes.SortedSet) []datatypes.ZMember {
+			return zset.RangeByScore(min, max, rev, int(offset), int(count))
+		}
+	case byLex:
+		min, okMin := parseLexBound(lo)
+		max, okMax := parseLexBound(hi)
+		if !okMin || !okMax {
+			return errorReply("ERR min or max not valid string range item")
+		}
+		query = func(zset *datatypes.SortedSet) []datatypes.ZMember {
+			return zset.RangeByLex(min, max, rev, int(offset), int(count))
+		}
+	default:
+		start, ok := parseInteger(lo)
+		if !ok {
+			return errorReply(errNotInteger)
+		}
+		stop, ok := parseInteger(hi)
+		if !ok {
+			return errorReply(errNotInteger)
+		}
+		query = func(zset *datatypes.SortedSet) []datatypes.ZMember {
+			return zset.RangeByRank(int(start), int(stop), rev)
+		}
+	}
+
+	var reply []byte
+	cache.View([]string{args[1]}, func(tx keyspace.ReadTx) {
+		zset, wrongType := zsetAt(tx.Get, args[1])
+		switch {
+		case wrongType:
+			reply = errorReply(errWrongType)
+		// A negative offset selects nothing
+		case zset == nil || offset < 0:
+			reply = arrayHeader(0)
+		default:
+			reply = zmemberArray(query(zset), withScores)
+		}
+	})
+
+	return reply
+}
+
+func zrankCommand(args []string, cache keyspace.Keyspace) []byte {
+	// ZRANK <key> <member> [WITHSCORE]
+	// ZREVRANK <key> <member> [WITHSCORE]
+	reverse := strings.ToUpper(args[0]) == "ZREVRANK"
+	if len(args) > 4 || (len(args) == 4 && !strings.EqualFold(args[3], "WITHSCORE")) {
+		return errorReply("ERR syntax error")
+	}
+	withScore := len(args) == 4
+
+	var reply []byte
+	cache.View([]string{args[1]}, func(tx keyspace.ReadTx) {
+		zset, wrongType := zsetAt(tx.Get, args[1])
+		if wrongType {
+			reply = errorReply(errWrongType)
+			return
+		}
+
+		var rank int
+		ok := false
+		if zset != nil {
+			rank, ok = zset.Rank(args[2], reverse)
+		}
+		switch {
+		case !ok && withScore:
+			reply = nullArray()
+		case !ok:
+			reply = nullBulkString()
+		case withScore:
+			score, _ := zset.Score(args[2])
+			reply = array(integer(int64(rank)), bulkString(formatScore(score)))
+		default:
+			reply = integer(int64(rank))
+		}
+	})
+
+	return reply
+}
+
+func zcountCommand(args []string, cache keyspace.Keyspace) []byte {
+	// ZCOUNT <key> <min> <max>
+	min, okMin := parseScoreBound(args[2])
+	max, okMax := parseScoreBound(args[3])
+	if !okMin || !okMax {
+		return errorReply("ERR min or max is not a float")
+	}
+
+	var reply []byte
+	cache.View([]string{args[1]}, func(tx keyspace.ReadTx) {
+		zset, wrongType := zsetAt(tx.Get, args[1])
+		switch {
+		case wrongType:
+			reply = errorReply(errWrongType)
+		case zset == nil:
+			reply = integer(0)
+		default:
+			reply = integer(int64(zset.CountByScore(min, max)))
+		}
+	})
+
+	return reply
+}
+
+func zpopCommand(args []string, cache keyspace.Keyspace) []byte {
+	// ZPOPMIN <key> [count]
+	// ZPOPMAX <key> [count]
+	highest := strings.ToUpper(args[0]) == "ZPOPMAX"
+	key := args[1]
+	if len(args) > 3 {
+		return errorReply("ERR syntax error")
+	}
+
+	count := int64(1)
+	if len(args) == 3 {
+		var ok bool
+		if count, ok = parseInteger(args[2]); !ok || count < 0 {
+			return errorReply("ERR value is out of range, must be positive")
+		}
+	}
+
+	var reply []byte
+	var changes int64
+	cache.Atomically([]string{key}, func(tx keyspace.Tx) {
+		zset, wrongType := zsetAt(tx.GetForWrite, key)
+		switch {
+		case wrongType:
+			reply = errorReply(errWrongType)
+			return
+		case zset == nil || count == 0:
+			reply = arrayHeader(0)
+			return
+		}
+
+		popped := zset.RangeByRank(0, int(min(count, int64(zset.Len())))-1, highest)
+		for _, m := range popped {
+			zset.Remove(m.Member)
+		}
+		if zset.Len() == 0 {
+			tx.Delete(key)
+		}
+		changes = int64(len(popped))
+		reply = zmemberArray(popped, true)
+	})
+
+	persistence.AddChanges(changes)
+	return reply
+}
+
+// zstoreKeys returns the destination and the source keys of ZUNIONSTORE and ZINTERSTORE.
+func zstoreKeys(args []string) []string {
+	sources := numKeysArgs(args, 2)
+	if sources == nil {
+		return nil
+	}
+	return append([]string{args[1]}, sources...)
+}
+
+func zstoreCommand(args []string, cache keyspace.Keyspace) []byte {
+	// ZUNIONSTORE <destination> <numkeys> <key> [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE <SUM | MIN | MAX>]
+	// ZINTERSTORE <destination> <numkeys> <key> [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE <SUM | MIN | MAX>]
+	inter := strings.ToUpper(args[0]) == "ZINTERSTORE"
+	dst := args[1]
+
+	numKeys, ok := parseInteger(args[2])
+	if !ok {
+		return errorReply(errNotInteger)
+	}
+	if numKeys < 1 {
+		return errorReply("ERR at least 1 input key is needed for '" + strings.ToLower(args[0]) + "' command")
+	}
+	if numKeys > int64(len(args)-3) {
+		return errorReply("ERR syntax error")
+	}
+	sources := args[3 : 3+numKeys]
+
+	weights := make([]float64, numKeys)
+	for i := range weights {
+		weights[i] = 1
+	}
+	aggregate := "SUM"
+	for i := 3 + int(numKeys); i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "WEIGHTS":
+			if i+len(weights) >= len(args) {
+				return errorReply("ERR syntax error")
+			}
+			for j := range weights {
+				if weights[j], ok = parseScore(args[i+1+j]); !ok {
+					return errorReply("ERR weight value is not a float")
+				}
+			}
+			i += len(weights)
+		case "AGGREGATE":
+			if i+1 == len(args) {
+				return errorReply("ERR syntax error")
+			}
+			aggregate = strings.ToUpper(args[i+1])
+			if aggregate != "SUM" && aggregate != "MIN" && aggregate != "MAX" {
+				return errorReply("ERR syntax error")
+			}
+			i++
+		default:
+			return errorReply("ERR syntax error")
+		}
+	}
+
+	var reply []byte
+	var changes int64
+	cache.Atomically(append([]string{dst}, sources...), func(tx keyspace.Tx) {
+		zsets := make([]*datatypes.SortedSet, len(sources))
+		for i, source := range sources {
+			// Plain sets take part as sorted sets whose members all score 1, like in Redis
+			if set, _ := setAt(tx.Get, source); set != nil {
+				zsets[i] = datatypes.NewSortedSet()
+				set.Iterate(func(member string) bool {
+					zsets[i].Add(member, 1)
+					return true
+				})
+				continue
+			}
+			var wrongType bool
+			if zsets[i], wrongType = zsetAt(tx.Get, source); wrongType {
+				reply = errorReply(errWrongType)
+				return
+			}
+		}
+
+		// An empty result only changes the destination when it deletes it
+		result := zstore(zsets, weights, aggregate, inter)
+		if result.Len() > 0 {
+			tx.Set(dst, newZSetValue(result))
+			changes = 1
+		} else if tx.Delete(dst) {
+			changes = 1
+		}
+		reply = integer(int64(result.Len()))
+	})
+
+	persistence.AddChanges(changes)
+	return reply
+}
+
+// zstore computes the union, or the intersection when inter is set, of the sorted sets, nil standing for
+// a missing key. Each score is multiplied by the weight of its sorted set, and the scores of a member
+// found in several sorted sets are combined by the aggregate function.
+func zstore(zsets []*datatypes.SortedSet, weights []float64, aggregate string, inter bool) *datatypes.SortedSet {
+	result := datatypes.NewSortedSet()
+
+	// A weighted infinite score can produce NaN, which Redis turns into 0
+	weighted := func(score, weight float64) float64 {
+		if score = score * weight; math.IsNaN(score) {
+			return 0
+		}
+		return score
+	}
+	combine := func(a, b float64) float64 {
+		switch aggregate {
+		case "MIN":
+			return math.Min(a, b)
+		case "MAX":
+			return math.Max(a, b)
+		}
+		if sum := a + b; !math.IsNaN(sum) {
+			return sum
+		}
+		return 0
+	}
+
+	if inter {
+		for _, zset := range zsets {
+			if zset == nil {
+				return result
+			}
+		}
+		zsets[0].Iterate(func(member string, score float64) bool {
+			score = weighted(score, weights[0])
+			for i, other := range zsets[1:] {
+				otherScore, ok := other.Score(member)
+				if !ok {
+					return true
+				}
+				score = combine(score, weighted(otherScore, weights[i+1]))
+			}
+			result.Add(member, score)
+			return true
+		})
+		return result
+	}
+
+	for i, zset := range zsets {
+		if zset == nil {
+			continue
+		}
+		zset.Iterate(func(member string, score float64) bool {
+			score = weighted(score, weights[i])
+			if current, ok := result.Score(member); ok {
+				score = combine(current, score)
+			}
+			result.Add(member, score)
+			return true
+		})
+	}
+	return result
+}
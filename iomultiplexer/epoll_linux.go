@@ -0,0 +1,122 @@
+package iomultiplexer
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"time"
+)
+
+// Epoll implements the IOMultiplexer interface for Linux-based systems
+type Epoll struct {
+	// fd stores the file descriptor of the epoll instance
+	fd int
+	// ePollEvents acts as a buffer for the events returned by the EpollWait syscall
+	ePollEvents []syscall.EpollEvent
+	// events stores the events after they are converted to the generic Event type
+	// and is returned to the caller
+	events []Event
+	// interests stores the operations each file descriptor is subscribed to.
+	// epoll registers a single interest set per descriptor, so it is tracked here
+	// to give Subscribe the same additive behaviour as kqueue filters
+	interests map[int]Operations
+}
+
+// New creates a new Epoll instance
+func New(maxClients int) (*Epoll, error) {
+	fd, err := syscall.EpollCreate1(0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Epoll{
+		fd:          fd,
+		ePollEvents: make([]syscall.EpollEvent, maxClients),
+		events:      make([]Event, maxClients),
+		interests:   make(map[int]Operations),
+	}, nil
+}
+
+// Subscribe subscribes to the given event
+func (ep *Epoll) Subscribe(event Event) error {
+	current, registered := ep.interests[event.Fd]
+	op := current | event.Op
+
+	ctl := syscall.EPOLL_CTL_ADD
+	if registered {
+		ctl = syscall.EPOLL_CTL_MOD
+	}
+
+	nativeEvent := event.toNative(op)
+	if err := syscall.EpollCtl(ep.fd, ctl, event.Fd, &nativeEvent); err != nil {
+		return fmt.Errorf("epoll subscribe: %w", err)
+	}
+
+	ep.interests[event.Fd] = op
+	return nil
+}
+
+// Unsubscribe stops watching the operations of the given event.
+// The file descriptor is removed from the epoll instance once no operation is left
+func (ep *Epoll) Unsubscribe(event Event) error {
+	current, registered := ep.interests[event.Fd]
+	if !registered {
+		return fmt.Errorf("epoll unsubscribe: fd %d is not subscribed", event.Fd)
+	}
+	op := current &^ event.Op
+
+	if op == 0 {
+		if err := syscall.EpollCtl(ep.fd, syscall.EPOLL_CTL_DEL, event.Fd, nil); err != nil {
+			return fmt.Errorf("epoll unsubscribe: %w", err)
+		}
+		delete(ep.interests, event.Fd)
+		return nil
+	}
+
+	nativeEvent := event.toNative(op)
+	if err := syscall.EpollCtl(ep.fd, syscall.EPOLL_CTL_MOD, event.Fd, &nativeEvent); err != nil {
+		return fmt.Errorf("epoll unsubscribe: %w", err)
+	}
+
+	ep.interests[event.Fd] = op
+	return nil
+}
+
+// Modify replaces the operations watched for the file descriptor of the given event with the event's operations
+func (ep *Epoll) Modify(event Event) error {
+	if _, registered := ep.interests[event.Fd]; !registered {
+		return fmt.Errorf("epoll modify: fd %d is not subscribed", event.Fd)
+	}
+
+	nativeEvent := event.toNative(event.Op)
+	if err := syscall.EpollCtl(ep.fd, syscall.EPOLL_CTL_MOD, event.Fd, &nativeEvent); err != nil {
+		return fmt.Errorf("epoll modify: %w", err)
+	}
+
+	ep.interests[event.Fd] = event.Op
+	return nil
+}
+
+// Poll polls for all the subscribed events simultaneously
+// and returns all the events that were triggered
+// It blocks until at least one event is triggered or the timeout is reached
+func (ep *Epoll) Poll(timeout time.Duration) ([]Event, error) {
+	nEvents, err := syscall.EpollWait(ep.fd, ep.ePollEvents, newTime(timeout))
+	if err != nil {
+		if errors.Is(err, syscall.EINTR) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("epoll poll: %w", err)
+	}
+
+	for i := 0; i < nEvents; i++ {
+		ep.events[i] = newEvent(ep.ePollEvents[i])
+	}
+
+	return ep.events[:nEvents], nil
+}
+
+// Close closes the Epoll instance
+func (ep *Epoll) Close() error {
+	return syscall.Close(ep.fd)
+}
@@ -4,6 +4,8 @@ import "time"
 
 type IOMultiplexer interface {
 	Subscribe(event Event) error
+	Unsubscribe(event Event) error
+	Modify(event Event) error
 	Poll(timeout time.Duration) ([]Event, error)
 	Close() error
 }
@@ -33,12 +33,33 @@ func New(maxClients int) (*KQueue, error) {
 
 // Subscribe subscribes to the given event
 func (kq *KQueue) Subscribe(event Event) error {
-	if subscribed, err := syscall.Kevent(kq.fd, []syscall.Kevent_t{event.toNative(syscall.EV_ADD)}, nil, nil); err != nil || subscribed == -1 {
+	if subscribed, err := syscall.Kevent(kq.fd, event.toNative(syscall.EV_ADD), nil, nil); err != nil || subscribed == -1 {
 		return fmt.Errorf("kqueue subscribe: %w", err)
 	}
 	return nil
 }
 
+// Unsubscribe stops watching the operations of the given event
+func (kq *KQueue) Unsubscribe(event Event) error {
+	if unsubscribed, err := syscall.Kevent(kq.fd, event.toNative(syscall.EV_DELETE), nil, nil); err != nil || unsubscribed == -1 {
+		return fmt.Errorf("kqueue unsubscribe: %w", err)
+	}
+	return nil
+}
+
+// Modify replaces the operations watched for the file descriptor of the given event with the event's operations.
+// Filters that are no longer wanted are disabled rather than deleted, so that it does not matter whether they were added
+func (kq *KQueue) Modify(event Event) error {
+	changes := event.toNative(syscall.EV_ADD | syscall.EV_ENABLE)
+	disabled := Event{Fd: event.Fd, Op: (OpRead | OpWrite) &^ event.Op}
+	changes = append(changes, disabled.toNative(syscall.EV_ADD|syscall.EV_DISABLE)...)
+
+	if modified, err := syscall.Kevent(kq.fd, changes, nil, nil); err != nil || modified == -1 {
+		return fmt.Errorf("kqueue modify: %w", err)
+	}
+	return nil
+}
+
 // Poll polls for all the subscribed events simultaneously
 // and returns all the events that were triggered
 // It blocks until at least one event is triggered or the timeout is reached
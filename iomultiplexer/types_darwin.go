@@ -16,13 +16,20 @@ func newTime(t time.Duration) *syscall.Timespec {
 	}
 }
 
-// toNative converts the given generic Event to Darwin's Kevent_t struct
-func (e Event) toNative(flags uint16) syscall.Kevent_t {
-	return syscall.Kevent_t{
-		Ident:  uint64(e.Fd),
-		Filter: e.Op.toNative(),
-		Flags:  flags,
+// toNative converts the given generic Event to Darwin's Kevent_t structs.
+// kqueue watches each filter separately, so one Kevent_t is returned per operation
+func (e Event) toNative(flags uint16) []syscall.Kevent_t {
+	var kEvents []syscall.Kevent_t
+
+	for _, filter := range e.Op.toNative() {
+		kEvents = append(kEvents, syscall.Kevent_t{
+			Ident:  uint64(e.Fd),
+			Filter: filter,
+			Flags:  flags,
+		})
 	}
+
+	return kEvents
 }
 
 // newEvent converts the given Darwin's Kevent_t struct to the generic Event type
@@ -33,30 +40,29 @@ func newEvent(kEvent syscall.Kevent_t) Event {
 	}
 }
 
-// toNative converts the given generic Operations to Darwin's filter type
-func (op Operations) toNative() int16 {
-	native := int16(0)
+// toNative converts the given generic Operations to Darwin's filter types
+func (op Operations) toNative() []int16 {
+	var native []int16
 
 	if op&OpRead != 0 {
-		native |= syscall.EVFILT_READ
+		native = append(native, syscall.EVFILT_READ)
 	}
 	if op&OpWrite != 0 {
-		native |= syscall.EVFILT_WRITE
+		native = append(native, syscall.EVFILT_WRITE)
 	}
 
 	return native
 }
 
-// newOperations converts the given Darwin's filter type to the generic Operations type
+// newOperations converts the given Darwin's filter type to the generic Operations type.
+// Filters are distinct negative values rather than bit flags, so they are compared as a whole
 func newOperations(filter int16) Operations {
-	op := Operations(0)
-
-	if filter&syscall.EVFILT_READ != 0 {
-		op |= OpRead
-	}
-	if filter&syscall.EVFILT_WRITE != 0 {
-		op |= OpWrite
+	switch filter {
+	case syscall.EVFILT_READ:
+		return OpRead
+	case syscall.EVFILT_WRITE:
+		return OpWrite
 	}
 
-	return op
+	return 0
 }
@@ -0,0 +1,61 @@
+package iomultiplexer
+
+import (
+	"syscall"
+	"time"
+)
+
+// newTime converts the given time.Duration to the millisecond timeout expected by EpollWait
+func newTime(t time.Duration) int {
+	if t < 0 {
+		return -1
+	}
+
+	return int(t / time.Millisecond)
+}
+
+// toNative converts the given generic Event to Linux's EpollEvent struct
+// using op as the full interest set of the file descriptor
+func (e Event) toNative(op Operations) syscall.EpollEvent {
+	return syscall.EpollEvent{
+		Events: op.toNative(),
+		Fd:     int32(e.Fd),
+	}
+}
+
+// newEvent converts the given Linux's EpollEvent struct to the generic Event type
+func newEvent(ePEvent syscall.EpollEvent) Event {
+	return Event{
+		Fd: int(ePEvent.Fd),
+		Op: newOperations(ePEvent.Events),
+	}
+}
+
+// toNative converts the given generic Operations to Linux's epoll event mask
+func (op Operations) toNative() uint32 {
+	native := uint32(0)
+
+	if op&OpRead != 0 {
+		native |= syscall.EPOLLIN
+	}
+	if op&OpWrite != 0 {
+		native |= syscall.EPOLLOUT
+	}
+
+	return native
+}
+
+// newOperations converts the given Linux's epoll event mask to the generic Operations type.
+// Hang-ups and errors are reported as readable so the caller observes them on its next read
+func newOperations(events uint32) Operations {
+	op := Operations(0)
+
+	if events&(syscall.EPOLLIN|syscall.EPOLLHUP|syscall.EPOLLERR|syscall.EPOLLRDHUP) != 0 {
+		op |= OpRead
+	}
+	if events&syscall.EPOLLOUT != 0 {
+		op |= OpWrite
+	}
+
+	return op
+}
@@ -0,0 +1,35 @@
+package keyspace
+
+import "time"
+
+const (
+	// expireCycleKeysPerLoop is the number of keys with an expiration time sampled by each loop of the cycle
+	expireCycleKeysPerLoop = 20
+	// expireCycleAcceptableStale is the percentage of expired keys in a sample under which the cycle stops,
+	// since the memory still held by expired keys is then considered acceptable
+	expireCycleAcceptableStale = 10
+	// ExpireCycleTimeLimit is the time the servers give to each active expire cycle, the same 25ms Redis
+	// spends at its default frequency of 10 cycles per second
+	ExpireCycleTimeLimit = 25 * time.Millisecond
+)
+
+// ActiveExpireCycle deletes expired keys that are not accessed anymore, which passive deletion never reclaims.
+// Like Redis, it adapts its effort to the number of expired keys: it keeps sampling keys with an expiration time
+// while more than expireCycleAcceptableStale percent of a sample is expired, until timeLimit is reached.
+// It returns the number of keys deleted.
+func ActiveExpireCycle(ks Keyspace, timeLimit time.Duration) int {
+	start := time.Now()
+	total := 0
+
+	for {
+		sampled, expired := ks.DeleteExpired(expireCycleKeysPerLoop)
+		total += expired
+
+		if sampled == 0 || expired*100 <= sampled*expireCycleAcceptableStale {
+			return total
+		}
+		if time.Since(start) > timeLimit {
+			return total
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package keyspace
+
+import "math/rand/v2"
+
+const (
+	// indexMaxLevel is the maximum number of levels of an index node, enough for 2^64 keys.
+	indexMaxLevel = 32
+	// indexP is the probability for a node to have one more level.
+	indexP = 0.25
+)
+
+// hashIndex is a skiplist of the keys of a shard ordered by hash then key, which lets Scan seek to its cursor
+// in O(log N) and then visit the keys in cursor order, instead of hashing and sorting the whole shard every call.
+type hashIndex struct {
+	header *indexNode
+	level  int
+}
+
+type indexNode struct {
+	hash    uint64
+	key     string
+	forward []*indexNode
+}
+
+func newHashIndex() *hashIndex {
+	return &hashIndex{header: &indexNode{forward: make([]*indexNode, indexMaxLevel)}, level: 1}
+}
+
+// before reports whether the node is ordered before the given hash and key.
+func (n *indexNode) before(hash uint64, key string) bool {
+	return n.hash < hash || (n.hash == hash && n.key < key)
+}
+
+// next returns the following node, or nil at the end of the index.
+func (n *indexNode) next() *indexNode {
+	return n.forward[0]
+}
+
+// insert adds a key that is not in the index.
+func (idx *hashIndex) insert(hash uint64, key string) {
+	var update [indexMaxLevel]*indexNode
+	x := idx.header
+	for i := idx.level - 1; i >= 0; i-- {
+		for x.forward[i] != nil && x.forward[i].before(hash, key) {
+			x = x.forward[i]
+		}
+		update[i] = x
+	}
+
+	level := 1
+	for level < indexMaxLevel && rand.Float64() < indexP {
+		level++
+	}
+	if level > idx.level {
+		for i := idx.level; i < level; i++ {
+			update[i] = idx.header
+		}
+		idx.level = level
+	}
+
+	x = &indexNode{hash: hash, key: key, forward: make([]*indexNode, level)}
+	for i := 0; i < level; i++ {
+		x.forward[i] = update[i].forward[i]
+		update[i].forward[i] = x
+	}
+}
+
+// delete removes the key from the index.
+func (idx *hashIndex) delete(hash uint64, key string) {
+	var update [indexMaxLevel]*indexNode
+	x := idx.header
+	for i := idx.level - 1; i >= 0; i-- {
+		for x.forward[i] != nil && x.forward[i].before(hash, key) {
+			x = x.forward[i]
+		}
+		update[i] = x
+	}
+
+	x = x.forward[0]
+	if x == nil || x.hash != hash || x.key != key {
+		return
+	}
+	for i := 0; i < idx.level && update[i].forward[i] == x; i++ {
+		update[i].forward[i] = x.forward[i]
+	}
+	for idx.level > 1 && idx.header.forward[idx.level-1] == nil {
+		idx.level--
+	}
+}
+
+// seek returns the first node whose hash is at least the given one, or nil if there is none.
+func (idx *hashIndex) seek(hash uint64) *indexNode {
+	x := idx.header
+	for i := idx.level - 1; i >= 0; i-- {
+		for x.forward[i] != nil && x.forward[i].hash < hash {
+			x = x.forward[i]
+		}
+	}
+	return x.forward[0]
+}
@@ -0,0 +1,84 @@
+package keyspace
+
+import "github.com/Himanshu-Negi8/build-your-own-redis-server/types"
+
+// Keyspace is the storage the command handlers operate on.
+// Implementations must be safe for concurrent use, since the goroutine server calls them from every connection.
+// Expiration times are absolute unix times in milliseconds, and keys whose expiration time has passed
+// are reported as missing by every operation and deleted when they are accessed.
+type Keyspace interface {
+	// Get returns the value stored at key.
+	Get(key string) (types.CustomValue, bool)
+	// Set stores the value at key, replacing any previous value and its expiration time.
+	Set(key string, value types.CustomValue)
+	// Delete removes the key and reports whether it existed.
+	Delete(key string) bool
+	// Len returns the number of keys in the keyspace, including keys that expired but were not removed yet.
+	Len() int
+	// Scan returns about count keys starting at cursor, in cursor order, and the cursor to continue from.
+	// Iteration starts with a cursor of 0 and is complete when the returned cursor is 0.
+	// Every key present during the whole iteration is returned at least once.
+	Scan(cursor uint64, count int) ([]string, uint64)
+	// Expire sets the expiration time of key and reports whether the key exists.
+	Expire(key string, at int64) bool
+	// Persist removes the expiration time of key and reports whether there was one to remove.
+	Persist(key string) bool
+	// ExpireTime returns the expiration time of key, or -1 if the key has none,
+	// and reports whether the key exists.
+	ExpireTime(key string) (int64, bool)
+	// DeleteExpired samples up to sample keys that have an expiration time, deletes the expired ones,
+	// and returns the number of keys sampled and deleted. It drives the active expire cycle.
+	DeleteExpired(sample int) (sampled int, expired int)
+	// ForEach calls fn with every key that has not expired and its value, until fn returns false.
+	// Keys are locked while fn visits them, so fn must not call back into the keyspace.
+	ForEach(fn func(key string, value types.CustomValue) bool)
+	// Snapshot returns a point-in-time view of the keyspace, which stays unchanged while the keyspace is modified.
+	// The snapshot must be released once it is not needed anymore.
+	Snapshot() Snapshot
+	// Atomically calls fn with the given keys locked, so that no other operation observes or modifies them
+	// until fn returns. It is used by commands that read and then write keys. fn must only access the given keys.
+	Atomically(keys []string, fn func(tx Tx))
+	// View calls fn with the given keys locked for reading, so that no other operation modifies them until fn
+	// returns, while other readers may run alongside. It is used by read-only commands that read collections or
+	// several keys. fn must only access the given keys.
+	View(keys []string, fn func(tx ReadTx))
+	// SetLoading sets whether the keyspace is loading the append-only file. While it is, no key expires, so that
+	// the replayed commands find the keys as they were when the commands ran, even if they have expired since.
+	// The expired keys are deleted after loading, when they are accessed or by the active expire cycle.
+	SetLoading(loading bool)
+	// Loading reports whether the keyspace is loading the append-only file.
+	Loading() bool
+}
+
+// New creates the default in-memory keyspace.
+func New() Keyspace {
+	return NewShardedMap(DefaultShards)
+}
+
+// Tx gives access to the keys locked by Keyspace.Atomically.
+type Tx interface {
+	// Get returns the value stored at key. Its collection may be held by a snapshot, so it must not be modified.
+	Get(key string) (types.CustomValue, bool)
+	// GetForWrite returns the value stored at key with a collection that may be modified in place.
+	GetForWrite(key string) (types.CustomValue, bool)
+	// Set stores the value at key, replacing any previous value and its expiration time.
+	// A collection moved from another key must have been read with GetForWrite.
+	Set(key string, value types.CustomValue)
+	// Delete removes the key and reports whether it existed.
+	Delete(key string) bool
+}
+
+// ReadTx gives access to the keys locked by Keyspace.View.
+type ReadTx interface {
+	// Get returns the value stored at key. Its collection must not be modified.
+	Get(key string) (types.CustomValue, bool)
+}
+
+// Snapshot is a point-in-time view of a keyspace, used to persist it while commands keep modifying the keyspace.
+type Snapshot interface {
+	// ForEach calls fn with every key of the snapshot that had not expired when it was taken and its value,
+	// until fn returns false. Values must not be modified.
+	ForEach(fn func(key string, value types.CustomValue) bool)
+	// Release discards the snapshot, letting the keyspace stop preserving it.
+	Release()
+}
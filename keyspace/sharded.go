@@ -0,0 +1,531 @@
+package keyspace
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/types"
+)
+
+// DefaultShards is the number of shards used by the servers for their keyspace.
+const DefaultShards = 256
+
+// ShardedMap is the default in-memory Keyspace. It splits the keys over several shards, each protected by its own lock,
+// so that clients served by different goroutines only contend when their keys fall in the same shard.
+type ShardedMap struct {
+	shards []*shard
+	// shift is the number of low bits dropped from a key's hash to obtain its shard index.
+	// Using the high bits keeps every shard responsible for a contiguous range of hashes.
+	shift uint
+	// expireCursor is the index of the next shard sampled by DeleteExpired
+	expireCursor atomic.Uint64
+	// loading is set while the keyspace is loaded from the append-only file
+	loading atomic.Bool
+}
+
+// shard is a single partition of the keyspace.
+type shard struct {
+	mu    sync.RWMutex
+	items map[string]types.CustomValue
+	// volatile holds the keys of the shard that have an expiration time, which are the ones sampled by DeleteExpired
+	volatile map[string]struct{}
+	// snapshots is the number of snapshots preserving the shard. While there is one, items is copied before it
+	// is modified if it is shared with a snapshot, and collections are cloned before they are handed out to be
+	// modified in place, since the snapshots hold the same pointers.
+	snapshots int
+	// shared is set when items is the map held by a snapshot
+	shared bool
+	// owned holds the keys whose collection no snapshot holds, because it was cloned or stored after the last
+	// snapshot, so that it is modified in place without being cloned
+	owned map[string]struct{}
+	// index orders the keys of the shard by hash for Scan
+	index *hashIndex
+}
+
+// unshare gives the shard its own copy of items if a snapshot holds it, before it is modified.
+// The shard must be write-locked.
+func (s *shard) unshare() {
+	if !s.shared {
+		return
+	}
+	items := make(map[string]types.CustomValue, len(s.items))
+	for key, value := range s.items {
+		items[key] = value
+	}
+	s.items = items
+	s.shared = false
+}
+
+// own clones the collection held by value if a snapshot may hold it too, so that it can be modified in place.
+// The shard must be write-locked.
+func (s *shard) own(key string, value types.CustomValue) types.CustomValue {
+	if s.snapshots == 0 || value.Type == types.ValueTypeString {
+		return value
+	}
+	if _, ok := s.owned[key]; ok {
+		return value
+	}
+	value = value.Clone()
+	s.unshare()
+	s.items[key] = value
+	s.owned[key] = struct{}{}
+	return value
+}
+
+// set stores the value at key and keeps track of whether it expires. The shard must be write-locked.
+func (s *shard) set(key string, value types.CustomValue) {
+	s.unshare()
+	if !sameCollection(s.items[key], value) {
+		// The new collection may come from another key, so it is only owned if the caller says so
+		delete(s.owned, key)
+	}
+	if _, ok := s.items[key]; !ok {
+		s.index.insert(hashKey(key), key)
+	}
+	s.items[key] = value
+	if value.HasExpiration() {
+		s.volatile[key] = struct{}{}
+	} else {
+		delete(s.volatile, key)
+	}
+}
+
+// delete removes the key and reports whether it existed and had not expired. The shard must be write-locked.
+func (s *shard) delete(key string, now int64) bool {
+	value, ok := s.items[key]
+	if !ok {
+		return false
+	}
+	s.unshare()
+	delete(s.items, key)
+	delete(s.volatile, key)
+	delete(s.owned, key)
+	s.index.delete(hashKey(key), key)
+	return !value.IsExpired(now)
+}
+
+// sameCollection reports whether a and b hold the same collection.
+func sameCollection(a, b types.CustomValue) bool {
+	return a.Type == b.Type && a.List == b.List && a.Hash == b.Hash && a.ZSet == b.ZSet && a.Set == b.Set
+}
+
+// get returns the value stored at key, deleting it if it has expired. The shard must be write-locked.
+func (s *shard) get(key string, now int64) (types.CustomValue, bool) {
+	value, ok := s.items[key]
+	if !ok {
+		return types.CustomValue{}, false
+	}
+	if value.IsExpired(now) {
+		s.delete(key, now)
+		return types.CustomValue{}, false
+	}
+	return value, true
+}
+
+// NewShardedMap creates a ShardedMap with the given number of shards, rounded up to a power of two.
+func NewShardedMap(shardCount int) *ShardedMap {
+	bits := uint(0)
+	for 1<<bits < shardCount {
+		bits++
+	}
+
+	m := &ShardedMap{
+		shards: make([]*shard, 1<<bits),
+		shift:  64 - bits,
+	}
+	for i := range m.shards {
+		m.shards[i] = &shard{
+			items:    make(map[string]types.CustomValue),
+			volatile: make(map[string]struct{}),
+			index:    newHashIndex(),
+		}
+	}
+	return m
+}
+
+const (
+	fnvOffset64 = 14695981039346656037
+	fnvPrime64  = 1099511628211
+)
+
+// hashKey returns the 64-bit FNV-1a hash of the key. It is computed inline rather than with hash/fnv,
+// which would copy the key to a byte slice on every operation.
+func hashKey(key string) uint64 {
+	h := uint64(fnvOffset64)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= fnvPrime64
+	}
+	return h
+}
+
+// shardFor returns the shard responsible for the key.
+func (m *ShardedMap) shardFor(key string) *shard {
+	return m.shards[m.shardIndex(hashKey(key))]
+}
+
+// shardIndex returns the index of the shard responsible for the given hash.
+func (m *ShardedMap) shardIndex(hash uint64) int {
+	if m.shift == 64 {
+		return 0
+	}
+	return int(hash >> m.shift)
+}
+
+// now returns the time the expiration times are compared to: the current time in unix milliseconds, or 0 while
+// the keyspace is loading, which keeps every key from expiring.
+func (m *ShardedMap) now() int64 {
+	if m.loading.Load() {
+		return 0
+	}
+	return time.Now().UnixMilli()
+}
+
+// SetLoading stops the expiration of the keys while the keyspace is loading.
+func (m *ShardedMap) SetLoading(loading bool) {
+	m.loading.Store(loading)
+}
+
+// Loading reports whether the keyspace is loading.
+func (m *ShardedMap) Loading() bool {
+	return m.loading.Load()
+}
+
+// Get returns the value stored at key.
+// An expired key is deleted when it is accessed, so it does not wait for the active expire cycle.
+func (m *ShardedMap) Get(key string) (types.CustomValue, bool) {
+	s := m.shardFor(key)
+	current := m.now()
+
+	s.mu.RLock()
+	value, ok := s.items[key]
+	s.mu.RUnlock()
+	if !ok {
+		return types.CustomValue{}, false
+	}
+	if !value.IsExpired(current) {
+		return value, true
+	}
+
+	// The key has expired: take the write lock to delete it, unless it was replaced in the meantime
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.get(key, current)
+}
+
+// Set stores the value at key, replacing any previous value.
+func (m *ShardedMap) Set(key string, value types.CustomValue) {
+	s := m.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.set(key, value)
+}
+
+// Delete removes the key and reports whether it existed.
+func (m *ShardedMap) Delete(key string) bool {
+	s := m.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.delete(key, m.now())
+}
+
+// Len returns the number of keys over all the shards.
+func (m *ShardedMap) Len() int {
+	n := 0
+	for _, s := range m.shards {
+		s.mu.RLock()
+		n += len(s.items)
+		s.mu.RUnlock()
+	}
+	return n
+}
+
+// Scan returns about count keys starting at cursor and the cursor to continue from.
+// The cursor is a position in the 64-bit hash space of the keys: keys are returned in the order of their hash,
+// and the next cursor is the hash of the first key that was not returned. Since the hash of a key never changes,
+// a key present during the whole iteration cannot be skipped, however many keys are added or removed.
+// Keys sharing the hash of the last returned key are returned in the same batch, so that none is skipped.
+// Each shard keeps its keys ordered by hash, so a call seeks to the cursor and only visits the keys it returns,
+// along with the expired keys it skips.
+func (m *ShardedMap) Scan(cursor uint64, count int) ([]string, uint64) {
+	if count <= 0 {
+		count = 10
+	}
+
+	keys := make([]string, 0, count)
+	current := m.now()
+	for idx := m.shardIndex(cursor); idx < len(m.shards); idx++ {
+		s := m.shards[idx]
+
+		s.mu.RLock()
+		var last uint64
+		for node := s.index.seek(cursor); node != nil; node = node.next() {
+			if len(keys) >= count && node.hash != last {
+				s.mu.RUnlock()
+				return keys, node.hash
+			}
+			if s.items[node.key].IsExpired(current) {
+				continue
+			}
+			keys = append(keys, node.key)
+			last = node.hash
+		}
+		s.mu.RUnlock()
+
+		if len(keys) >= count {
+			if idx+1 == len(m.shards) {
+				break
+			}
+			// Continue from the first hash handled by the next shard
+			return keys, uint64(idx+1) << m.shift
+		}
+	}
+	return keys, 0
+}
+
+// Expire sets the expiration time of key and reports whether the key exists.
+func (m *ShardedMap) Expire(key string, at int64) bool {
+	s := m.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, ok := s.get(key, m.now())
+	if !ok {
+		return false
+	}
+	value.ValueExpiration = at
+	s.set(key, value)
+	return true
+}
+
+// Persist removes the expiration time of key and reports whether there was one to remove.
+func (m *ShardedMap) Persist(key string) bool {
+	s := m.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, ok := s.get(key, m.now())
+	if !ok || !value.HasExpiration() {
+		return false
+	}
+	value.ValueExpiration = -1
+	s.set(key, value)
+	return true
+}
+
+// ExpireTime returns the expiration time of key, or -1 if the key has none, and reports whether the key exists.
+func (m *ShardedMap) ExpireTime(key string) (int64, bool) {
+	value, ok := m.Get(key)
+	if !ok {
+		return 0, false
+	}
+	if !value.HasExpiration() {
+		return -1, true
+	}
+	return value.ValueExpiration, true
+}
+
+// ForEach calls fn with every key that has not expired and its value, one shard at a time, until fn returns false.
+// Each shard is read-locked while its keys are visited, so keys set or deleted meanwhile in other shards
+// may or may not be visited.
+func (m *ShardedMap) ForEach(fn func(key string, value types.CustomValue) bool) {
+	current := m.now()
+	for _, s := range m.shards {
+		s.mu.RLock()
+		for key, value := range s.items {
+			if value.IsExpired(current) {
+				continue
+			}
+			if !fn(key, value) {
+				s.mu.RUnlock()
+				return
+			}
+		}
+		s.mu.RUnlock()
+	}
+}
+
+// Snapshot returns a view of the keyspace as it is now. Every shard is locked at once while the snapshot is taken,
+// which only marks the shards as shared: like the pages of a forked process, a shard's map is copied on its first
+// modification, and a collection before a command first modifies it, so the snapshot costs nothing for the keys
+// that are not modified while it is held.
+func (m *ShardedMap) Snapshot() Snapshot {
+	for _, s := range m.shards {
+		s.mu.Lock()
+	}
+
+	snapshot := &shardedSnapshot{m: m, items: make([]map[string]types.CustomValue, len(m.shards)), now: m.now()}
+	for i, s := range m.shards {
+		snapshot.items[i] = s.items
+		s.shared = true
+		s.snapshots++
+		// Every collection is held by the new snapshot, including the ones cloned for a previous snapshot
+		s.owned = make(map[string]struct{})
+		s.mu.Unlock()
+	}
+	return snapshot
+}
+
+// shardedSnapshot implements Snapshot over the maps the shards had when it was taken.
+type shardedSnapshot struct {
+	m     *ShardedMap
+	items []map[string]types.CustomValue
+	// now is the time the snapshot was taken, which decides the keys that had expired
+	now      int64
+	released bool
+}
+
+func (ss *shardedSnapshot) ForEach(fn func(key string, value types.CustomValue) bool) {
+	// The maps are not modified anymore, so they are read without locking the shards
+	for _, items := range ss.items {
+		for key, value := range items {
+			if value.IsExpired(ss.now) {
+				continue
+			}
+			if !fn(key, value) {
+				return
+			}
+		}
+	}
+}
+
+func (ss *shardedSnapshot) Release() {
+	if ss.released {
+		return
+	}
+	ss.released = true
+
+	for _, s := range ss.m.shards {
+		s.mu.Lock()
+		s.snapshots--
+		if s.snapshots == 0 {
+			s.shared = false
+			s.owned = nil
+		}
+		s.mu.Unlock()
+	}
+}
+
+// lockOrder returns the indexes of the shards of the given keys in the order they are locked: in index order,
+// so that concurrent calls over overlapping keys cannot deadlock, and only once per shard.
+func (m *ShardedMap) lockOrder(keys []string) []int {
+	indexes := make([]int, 0, len(keys))
+	for _, key := range keys {
+		indexes = append(indexes, m.shardIndex(hashKey(key)))
+	}
+	sort.Ints(indexes)
+
+	unique := indexes[:0]
+	for i, idx := range indexes {
+		if i == 0 || indexes[i-1] != idx {
+			unique = append(unique, idx)
+		}
+	}
+	return unique
+}
+
+// Atomically calls fn with the shards of the given keys write-locked.
+func (m *ShardedMap) Atomically(keys []string, fn func(tx Tx)) {
+	for _, idx := range m.lockOrder(keys) {
+		m.shards[idx].mu.Lock()
+		defer m.shards[idx].mu.Unlock()
+	}
+
+	fn(&shardedTx{m: m, now: m.now()})
+}
+
+// View calls fn with the shards of the given keys read-locked.
+func (m *ShardedMap) View(keys []string, fn func(tx ReadTx)) {
+	for _, idx := range m.lockOrder(keys) {
+		m.shards[idx].mu.RLock()
+		defer m.shards[idx].mu.RUnlock()
+	}
+
+	fn(&shardedReadTx{m: m, now: m.now()})
+}
+
+// shardedReadTx implements ReadTx over shards that are already read-locked.
+type shardedReadTx struct {
+	m   *ShardedMap
+	now int64
+}
+
+// Get returns the value stored at key. An expired key is reported missing, but it is left for a write or the
+// active expire cycle to delete since the shard is only read-locked.
+func (tx *shardedReadTx) Get(key string) (types.CustomValue, bool) {
+	value, ok := tx.m.shardFor(key).items[key]
+	if !ok || value.IsExpired(tx.now) {
+		return types.CustomValue{}, false
+	}
+	return value, true
+}
+
+// shardedTx implements Tx over shards that are already locked.
+type shardedTx struct {
+	m   *ShardedMap
+	now int64
+}
+
+func (tx *shardedTx) Get(key string) (types.CustomValue, bool) {
+	return tx.m.shardFor(key).get(key, tx.now)
+}
+
+// GetForWrite returns the value stored at key. Since commands modify collections in place, a collection that may
+// be held by a snapshot is cloned first.
+func (tx *shardedTx) GetForWrite(key string) (types.CustomValue, bool) {
+	s := tx.m.shardFor(key)
+	value, ok := s.get(key, tx.now)
+	if !ok {
+		return value, false
+	}
+	return s.own(key, value), true
+}
+
+// Set stores the value at key. A new collection is either created by the command or read with GetForWrite,
+// so no snapshot holds it and it will not need to be cloned.
+func (tx *shardedTx) Set(key string, value types.CustomValue) {
+	s := tx.m.shardFor(key)
+	fresh := s.snapshots > 0 && value.Type != types.ValueTypeString && !sameCollection(s.items[key], value)
+	s.set(key, value)
+	if fresh {
+		s.owned[key] = struct{}{}
+	}
+}
+
+func (tx *shardedTx) Delete(key string) bool {
+	return tx.m.shardFor(key).delete(key, tx.now)
+}
+
+// DeleteExpired samples up to sample keys that have an expiration time and deletes the expired ones.
+// Shards are visited in turn across calls, starting where the previous call stopped, and a single call moves
+// on to the next shards until enough keys are sampled or every shard was visited.
+// It returns the number of keys sampled and the number of keys deleted.
+func (m *ShardedMap) DeleteExpired(sample int) (int, int) {
+	sampled, expired := 0, 0
+	current := m.now()
+
+	for visited := 0; visited < len(m.shards) && sampled < sample; visited++ {
+		idx := int(m.expireCursor.Add(1)-1) % len(m.shards)
+		s := m.shards[idx]
+
+		s.mu.Lock()
+		// Map iteration starts at a random position, which makes it a cheap random sample
+		for key := range s.volatile {
+			if sampled == sample {
+				break
+			}
+			sampled++
+			if s.items[key].IsExpired(current) {
+				s.delete(key, current)
+				expired++
+			}
+		}
+		s.mu.Unlock()
+	}
+
+	return sampled, expired
+}
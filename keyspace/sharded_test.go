@@ -0,0 +1,329 @@
+package keyspace_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/datatypes"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/keyspace"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/types"
+)
+
+func TestShardedMapConcurrentAccess(t *testing.T) {
+	m := keyspace.NewShardedMap(keyspace.DefaultShards)
+
+	const goroutines = 16
+	const keysPerGoroutine = 500
+
+	wg := &sync.WaitGroup{}
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < keysPerGoroutine; i++ {
+				key := fmt.Sprintf("key:%d:%d", g, i)
+				m.Set(key, types.CustomValue{Value: key, ValueExpiration: -1})
+				if value, ok := m.Get(key); !ok || value.Value != key {
+					t.Errorf("expected %s to be stored, got %v", key, value)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if n := m.Len(); n != goroutines*keysPerGoroutine {
+		t.Fatalf("expected %d keys, got %d", goroutines*keysPerGoroutine, n)
+	}
+
+	if !m.Delete("key:0:0") {
+		t.Errorf("expected key:0:0 to be deleted")
+	}
+	if m.Delete("key:0:0") {
+		t.Errorf("expected key:0:0 to be already deleted")
+	}
+	if _, ok := m.Get("key:0:0"); ok {
+		t.Errorf("expected key:0:0 to be missing")
+	}
+}
+
+func TestShardedMapScan(t *testing.T) {
+	ks := keyspace.New()
+	for i := 0; i < 1000; i++ {
+		ks.Set(fmt.Sprintf("key:%d", i), types.CustomValue{Value: "v", ValueExpiration: -1})
+	}
+
+	seen := make(map[string]bool)
+	cursor := uint64(0)
+	for iteration := 0; ; iteration++ {
+		var keys []string
+		keys, cursor = ks.Scan(cursor, 7)
+		for _, key := range keys {
+			seen[key] = true
+		}
+		// Keys added and removed during the iteration must not make it skip the others
+		ks.Set(fmt.Sprintf("added:%d", iteration), types.CustomValue{Value: "v", ValueExpiration: -1})
+		ks.Delete(fmt.Sprintf("key:%d", 500+iteration%500))
+		if cursor == 0 {
+			break
+		}
+	}
+
+	for i := 0; i < 500; i++ {
+		if key := fmt.Sprintf("key:%d", i); !seen[key] {
+			t.Errorf("expected %s to be returned by the scan", key)
+		}
+	}
+}
+
+func TestShardedMapScanReturnsEveryKeyOnce(t *testing.T) {
+	ks := keyspace.New()
+	for i := 0; i < 1000; i++ {
+		ks.Set(fmt.Sprintf("key:%d", i), types.CustomValue{Value: "v", ValueExpiration: -1})
+	}
+	// Overwritten, deleted and expired keys must leave the order of the keys consistent
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key:%d", i)
+		switch i % 4 {
+		case 0:
+			ks.Set(key, types.CustomValue{Value: "w", ValueExpiration: -1})
+		case 1:
+			ks.Delete(key)
+		case 2:
+			ks.Expire(key, time.Now().UnixMilli()-1000)
+		}
+	}
+
+	seen := make(map[string]int)
+	cursor := uint64(0)
+	for {
+		var keys []string
+		keys, cursor = ks.Scan(cursor, 10)
+		if len(keys) > 11 {
+			t.Errorf("expected about 10 keys per call, got %d", len(keys))
+		}
+		for _, key := range keys {
+			seen[key]++
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+
+	if len(seen) != 500 {
+		t.Errorf("expected 500 keys, got %d", len(seen))
+	}
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key:%d", i)
+		if want := i%4 == 0 || i%4 == 3; want && seen[key] != 1 {
+			t.Errorf("expected %s to be returned once, got %d times", key, seen[key])
+		} else if !want && seen[key] != 0 {
+			t.Errorf("expected %s not to be returned", key)
+		}
+	}
+}
+
+func TestShardedMapExpiration(t *testing.T) {
+	ks := keyspace.New()
+	ks.Set("persistent", types.CustomValue{Value: "v", ValueExpiration: -1})
+	ks.Set("expired", types.CustomValue{Value: "v", ValueExpiration: time.Now().UnixMilli() - 1})
+
+	if _, ok := ks.Get("expired"); ok {
+		t.Errorf("expected expired key to be reported as missing")
+	}
+	if ks.Expire("expired", time.Now().UnixMilli()+1000) {
+		t.Errorf("expected expire on an expired key to fail")
+	}
+
+	if at, ok := ks.ExpireTime("persistent"); !ok || at != -1 {
+		t.Errorf("expected no expiration time, got %d %v", at, ok)
+	}
+	if ks.Persist("persistent") {
+		t.Errorf("expected persist without an expiration time to fail")
+	}
+
+	at := time.Now().UnixMilli() + 10000
+	if !ks.Expire("persistent", at) {
+		t.Fatalf("expected expire to succeed")
+	}
+	if got, ok := ks.ExpireTime("persistent"); !ok || got != at {
+		t.Errorf("expected expiration time %d, got %d %v", at, got, ok)
+	}
+	if !ks.Persist("persistent") {
+		t.Errorf("expected persist to remove the expiration time")
+	}
+	if got, _ := ks.ExpireTime("persistent"); got != -1 {
+		t.Errorf("expected no expiration time after persist, got %d", got)
+	}
+}
+
+func TestExpiredKeysAreDeleted(t *testing.T) {
+	ks := keyspace.New()
+	past := time.Now().UnixMilli() - 1
+	for i := 0; i < 1000; i++ {
+		ks.Set(fmt.Sprintf("expired:%d", i), types.CustomValue{Value: "v", ValueExpiration: past})
+	}
+	for i := 0; i < 100; i++ {
+		ks.Set(fmt.Sprintf("persistent:%d", i), types.CustomValue{Value: "v", ValueExpiration: -1})
+	}
+
+	// Passive deletion on access
+	if _, ok := ks.Get("expired:0"); ok {
+		t.Fatalf("expected expired:0 to be missing")
+	}
+	if n := ks.Len(); n != 1099 {
+		t.Fatalf("expected the accessed key to be deleted, got %d keys", n)
+	}
+
+	// Active deletion of the keys that are never accessed
+	if expired := keyspace.ActiveExpireCycle(ks, time.Second); expired != 999 {
+		t.Errorf("expected the active expire cycle to delete 999 keys, got %d", expired)
+	}
+	if n := ks.Len(); n != 100 {
+		t.Errorf("expected 100 keys left, got %d", n)
+	}
+}
+
+func TestLoadingKeepsExpiredKeys(t *testing.T) {
+	ks := keyspace.New()
+	past := time.Now().UnixMilli() - 1
+	ks.SetLoading(true)
+	ks.Set("expired", types.CustomValue{Value: "v", ValueExpiration: past})
+
+	if _, ok := ks.Get("expired"); !ok {
+		t.Errorf("an expired key is missing while loading")
+	}
+	ks.Atomically([]string{"expired"}, func(tx keyspace.Tx) {
+		if _, ok := tx.Get("expired"); !ok {
+			t.Errorf("an expired key is missing from a transaction while loading")
+		}
+	})
+	if _, expired := ks.DeleteExpired(100); expired != 0 {
+		t.Errorf("%d keys deleted by the active expire cycle while loading", expired)
+	}
+
+	ks.SetLoading(false)
+	if _, ok := ks.Get("expired"); ok {
+		t.Errorf("an expired key is found after loading")
+	}
+	if n := ks.Len(); n != 0 {
+		t.Errorf("expected the expired key to be deleted after loading, got %d keys", n)
+	}
+}
+
+func TestShardedMapSnapshot(t *testing.T) {
+	ks := keyspace.New()
+	ks.Set("kept", types.CustomValue{Value: "v", ValueExpiration: -1})
+	ks.Set("replaced", types.CustomValue{Value: "old", ValueExpiration: -1})
+	ks.Set("deleted", types.CustomValue{Value: "v", ValueExpiration: -1})
+	list := datatypes.NewList()
+	list.PushBack("a")
+	ks.Set("list", types.CustomValue{Type: types.ValueTypeList, List: list, ValueExpiration: -1})
+
+	snapshot := ks.Snapshot()
+	defer snapshot.Release()
+
+	ks.Set("replaced", types.CustomValue{Value: "new", ValueExpiration: -1})
+	ks.Set("added", types.CustomValue{Value: "v", ValueExpiration: -1})
+	ks.Delete("deleted")
+	ks.Atomically([]string{"list"}, func(tx keyspace.Tx) {
+		value, _ := tx.GetForWrite("list")
+		value.List.PushBack("b")
+	})
+
+	got := make(map[string]string)
+	snapshot.ForEach(func(key string, value types.CustomValue) bool {
+		if value.Type == types.ValueTypeList {
+			got[key] = strings.Join(value.List.Range(0, -1), ",")
+		} else {
+			got[key] = value.Value
+		}
+		return true
+	})
+	want := map[string]string{"kept": "v", "replaced": "old", "deleted": "v", "list": "a"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("snapshot = %v, want %v", got, want)
+	}
+
+	if value, _ := ks.Get("replaced"); value.Value != "new" {
+		t.Errorf("replaced = %q, want new", value.Value)
+	}
+	if value, _ := ks.Get("list"); value.List.Len() != 2 {
+		t.Errorf("list has %d elements, want 2", value.List.Len())
+	}
+}
+
+func TestShardedMapSnapshotClonesOnlyForWrites(t *testing.T) {
+	ks := keyspace.New()
+	list := datatypes.NewList()
+	list.PushBack("a")
+	ks.Set("list", types.CustomValue{Type: types.ValueTypeList, List: list, ValueExpiration: -1})
+
+	snapshot := ks.Snapshot()
+	defer snapshot.Release()
+
+	ks.View([]string{"list"}, func(tx keyspace.ReadTx) {
+		if value, _ := tx.Get("list"); value.List != list {
+			t.Errorf("a read cloned the list")
+		}
+	})
+
+	var written *datatypes.List
+	ks.Atomically([]string{"list"}, func(tx keyspace.Tx) {
+		value, _ := tx.GetForWrite("list")
+		written = value.List
+	})
+	if written == list {
+		t.Fatalf("the first write did not clone the list held by the snapshot")
+	}
+	ks.Atomically([]string{"list"}, func(tx keyspace.Tx) {
+		if value, _ := tx.GetForWrite("list"); value.List != written {
+			t.Errorf("the second write cloned the list again")
+		}
+	})
+
+	// A collection created after the snapshot is not held by it
+	added := datatypes.NewList()
+	ks.Atomically([]string{"added"}, func(tx keyspace.Tx) {
+		tx.Set("added", types.CustomValue{Type: types.ValueTypeList, List: added, ValueExpiration: -1})
+	})
+	ks.Atomically([]string{"added"}, func(tx keyspace.Tx) {
+		if value, _ := tx.GetForWrite("added"); value.List != added {
+			t.Errorf("a collection created after the snapshot was cloned")
+		}
+	})
+}
+
+func TestShardedMapSnapshotConcurrentWrites(t *testing.T) {
+	ks := keyspace.New()
+	for i := 0; i < 1000; i++ {
+		ks.Set(fmt.Sprintf("key:%d", i), types.CustomValue{Value: "before", ValueExpiration: -1})
+	}
+
+	snapshot := ks.Snapshot()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			ks.Set(fmt.Sprintf("key:%d", i), types.CustomValue{Value: "after", ValueExpiration: -1})
+		}
+	}()
+
+	n := 0
+	snapshot.ForEach(func(key string, value types.CustomValue) bool {
+		if value.Value != "before" {
+			t.Errorf("%s = %q in the snapshot, want before", key, value.Value)
+		}
+		n++
+		return true
+	})
+	<-done
+	snapshot.Release()
+
+	if n != 1000 {
+		t.Errorf("the snapshot has %d keys, want 1000", n)
+	}
+}
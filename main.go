@@ -1,12 +1,37 @@
 package main
 
 import (
+	"flag"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/config"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/persistence"
 	"github.com/Himanshu-Negi8/build-your-own-redis-server/server"
 	"log"
 )
 
 func main() {
-	s := server.NewServer("127.0.0.1", 6379, 2000)
+	flag.StringVar(&config.Dir, "dir", config.Dir, "directory of the RDB and append-only files")
+	flag.StringVar(&config.DBFilename, "dbfilename", config.DBFilename, "name of the RDB file")
+	flag.BoolVar(&config.AppendOnly, "appendonly", config.AppendOnly, "log every write to the append-only file, and load the keyspace from it on startup")
+	flag.Func("appendfsync", "flush the append-only file to disk with `policy` always, everysec or no (default \"everysec\")", func(value string) error {
+		if _, err := persistence.ParseFsyncPolicy(value); err != nil {
+			return err
+		}
+		config.AppendFsync = value
+		return nil
+	})
+
+	var opts []server.Option
+	flag.Func("client-output-buffer-limit", "limits on the replies buffered for a slow client as `\"hard soft seconds\"`, like \"256mb 64mb 60\" (default no limit)", func(value string) error {
+		limit, err := server.ParseOutputBufferLimit(value)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, server.WithClientOutputBufferLimit(limit))
+		return nil
+	})
+	flag.Parse()
+
+	s := server.NewServer("127.0.0.1", 6379, 2000, opts...)
 	err := s.RunAsyncServer()
 	if err != nil {
 		log.Fatalf("failed to run server: %v", err)
@@ -0,0 +1,145 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/types"
+)
+
+// minBulkFrameLen is the size of the smallest bulk string frame, an empty one "$0\r\n\r\n".
+const minBulkFrameLen = 6
+
+// CommandParser parses the commands a client sends, arrays of bulk strings, from the bytes read from its
+// connection. Unlike ParseFrames, it keeps its progress in a command between calls, like the multibulklen and
+// bulklen of a Redis client: the elements read so far are consumed, so a large command is parsed once however
+// many reads it arrives in, instead of once per read.
+type CommandParser struct {
+	// args holds the elements of the command being read, nil between commands
+	args []interface{}
+	// remaining is the number of elements of the command still to read
+	remaining int
+	// bulkLen is the length of the next element, -1 until its header is read
+	bulkLen int
+}
+
+// NewCommandParser creates a parser for the commands of a new client.
+func NewCommandParser() *CommandParser {
+	return &CommandParser{bulkLen: -1}
+}
+
+// Parse parses the commands at the start of buf, and returns the complete ones and the number of bytes consumed.
+// The consumed bytes include those of the elements already read of a partial command at the end of buf, which the
+// parser keeps: the caller drops them and passes the bytes that follow, together with the bytes read next.
+// Frames that are not arrays are parsed as a whole by ParseFrame. Any error leaves the parser ready for a new
+// command, but the bytes after a ProtocolError cannot be told apart from commands.
+func (p *CommandParser) Parse(buf []byte) ([]Frame, int, error) {
+	var frames []Frame
+	pos := 0
+	for pos < len(buf) {
+		if p.args == nil && buf[pos] != '*' {
+			value, respType, n, err := ParseFrame(buf[pos:])
+			if errors.Is(err, ErrIncomplete) {
+				break
+			}
+			if err != nil {
+				return frames, pos, err
+			}
+			frames = append(frames, Frame{Value: value, Type: respType})
+			pos += n
+			continue
+		}
+
+		n, done, err := p.parseCommand(buf[pos:])
+		pos += n
+		if err != nil {
+			p.reset()
+			return frames, pos, err
+		}
+		if !done {
+			break
+		}
+		frames = append(frames, Frame{Value: p.args, Type: types.RESPTypeArray})
+		p.reset()
+	}
+	return frames, pos, nil
+}
+
+// parseCommand continues reading the command at the start of buf, and returns the number of bytes consumed and
+// whether the command is complete.
+func (p *CommandParser) parseCommand(buf []byte) (int, bool, error) {
+	pos := 0
+	if p.args == nil {
+		length, n, err := commandLength(buf, MaxMultiBulkLength, "multibulk")
+		if err != nil {
+			return 0, false, ignoreIncomplete(err)
+		}
+		pos = n
+		// A null array is read as an empty one, which is not a command either
+		length = max(length, 0)
+		// Only preallocate the elements the buffered bytes can hold, the length is client controlled
+		p.args = make([]interface{}, 0, min(length, len(buf[pos:])/minBulkFrameLen))
+		p.remaining = length
+	}
+
+	for p.remaining > 0 && pos < len(buf) {
+		if p.bulkLen == -1 {
+			if buf[pos] != '$' {
+				return pos, false, &ProtocolError{Reason: fmt.Sprintf("expected '$', got '%c'", buf[pos])}
+			}
+			length, n, err := commandLength(buf[pos:], MaxBulkLength, "bulk")
+			if err != nil {
+				return pos, false, ignoreIncomplete(err)
+			}
+			if length == -1 {
+				return pos, false, &ProtocolError{Reason: "invalid bulk length"}
+			}
+			pos += n
+			p.bulkLen = length
+		}
+
+		// The data is followed by the trailing \r\n
+		end := pos + p.bulkLen + 2
+		if len(buf) < end {
+			return pos, false, nil
+		}
+		if buf[end-2] != '\r' || buf[end-1] != '\n' {
+			return pos, false, &ProtocolError{Reason: "invalid bulk string terminator"}
+		}
+		p.args = append(p.args, string(buf[pos:end-2]))
+		pos = end
+		p.bulkLen = -1
+		p.remaining--
+	}
+	return pos, p.remaining == 0, nil
+}
+
+// reset prepares the parser for the next command.
+func (p *CommandParser) reset() {
+	p.args = nil
+	p.remaining = 0
+	p.bulkLen = -1
+}
+
+// commandLength parses the length on the header line at the start of buf, which must be -1 or between 0 and limit.
+// kind names the length in the errors, like in Redis.
+func commandLength(buf []byte, limit int, kind string) (int, int, error) {
+	line, n, err := frameLine(buf)
+	if err != nil {
+		return 0, 0, err
+	}
+	length, err := strconv.Atoi(line)
+	if err != nil || length < -1 || length > limit {
+		return 0, 0, &ProtocolError{Reason: "invalid " + kind + " length"}
+	}
+	return length, n, nil
+}
+
+// ignoreIncomplete returns nil for ErrIncomplete, which only means the parser waits for more bytes.
+func ignoreIncomplete(err error) error {
+	if errors.Is(err, ErrIncomplete) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,175 @@
+package parser_test
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/parser"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/types"
+)
+
+func TestCommandParser(t *testing.T) {
+	tests := []struct {
+		name             string
+		input            string
+		expectedFrames   []parser.Frame
+		expectedConsumed int
+		expectedError    error
+	}{
+		{
+			name:  "Pipelined commands",
+			input: "*1\r\n$4\r\nPING\r\n*2\r\n$3\r\nGET\r\n$1\r\na\r\n",
+			expectedFrames: []parser.Frame{
+				{Value: []interface{}{"PING"}, Type: types.RESPTypeArray},
+				{Value: []interface{}{"GET", "a"}, Type: types.RESPTypeArray},
+			},
+			expectedConsumed: 34,
+		},
+		{
+			name:  "Partial command",
+			input: "*1\r\n$4\r\nPING\r\n*2\r\n$3\r\nGET\r\n$1\r",
+			expectedFrames: []parser.Frame{
+				{Value: []interface{}{"PING"}, Type: types.RESPTypeArray},
+			},
+			// The elements read of the partial command are consumed, not the header of the next one
+			expectedConsumed: 27,
+		},
+		{
+			name:             "Partial header",
+			input:            "*2",
+			expectedConsumed: 0,
+		},
+		{
+			name:  "Simple string command",
+			input: "+PING\r\n",
+			expectedFrames: []parser.Frame{
+				{Value: "PING", Type: types.RESPTypeSimpleString},
+			},
+			expectedConsumed: 7,
+		},
+		{
+			name:  "Empty and null arrays",
+			input: "*0\r\n*-1\r\n",
+			expectedFrames: []parser.Frame{
+				{Value: []interface{}{}, Type: types.RESPTypeArray},
+				{Value: []interface{}{}, Type: types.RESPTypeArray},
+			},
+			expectedConsumed: 9,
+		},
+		{
+			name:          "Nested array",
+			input:         "*1\r\n*1\r\n$4\r\nPING\r\n",
+			expectedError: errors.New("Protocol error: expected '$', got '*'"),
+		},
+		{
+			name:          "Integer element",
+			input:         "*2\r\n$3\r\nGET\r\n:1\r\n",
+			expectedError: errors.New("Protocol error: expected '$', got ':'"),
+		},
+		{
+			name:          "Null bulk string element",
+			input:         "*1\r\n$-1\r\n",
+			expectedError: errors.New("Protocol error: invalid bulk length"),
+		},
+		{
+			name:          "Bulk length over the limit",
+			input:         "*1\r\n$9223372036854775807\r\nab",
+			expectedError: errors.New("Protocol error: invalid bulk length"),
+		},
+		{
+			name:          "Multibulk length over the limit",
+			input:         "*100000000000\r\n",
+			expectedError: errors.New("Protocol error: invalid multibulk length"),
+		},
+		{
+			name:          "Missing bulk string terminator",
+			input:         "*1\r\n$4\r\nPINGxx",
+			expectedError: errors.New("Protocol error: invalid bulk string terminator"),
+		},
+		{
+			name:          "Multibulk count line without an end",
+			input:         "*" + strings.Repeat("1", parser.MaxLineLength+1),
+			expectedError: errors.New("Protocol error: too big mbulk count string"),
+		},
+		{
+			name:          "Bulk count line without an end",
+			input:         "*1\r\n$" + strings.Repeat("9", parser.MaxLineLength+1),
+			expectedError: errors.New("Protocol error: too big bulk count string"),
+		},
+		{
+			name:          "Simple string without an end",
+			input:         "+" + strings.Repeat("a", parser.MaxLineLength+1),
+			expectedError: errors.New("Protocol error: too big inline request"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frames, consumed, err := parser.NewCommandParser().Parse([]byte(tt.input))
+
+			if tt.expectedError != nil {
+				if err == nil || err.Error() != tt.expectedError.Error() {
+					t.Fatalf("expected error %v, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if !reflect.DeepEqual(frames, tt.expectedFrames) {
+				t.Errorf("expected frames %v, got %v", tt.expectedFrames, frames)
+			}
+			if consumed != tt.expectedConsumed {
+				t.Errorf("expected %d bytes consumed, got %d", tt.expectedConsumed, consumed)
+			}
+		})
+	}
+}
+
+func TestCommandParserResumes(t *testing.T) {
+	// A large MSET read in small chunks, like a pipelined command arrives from a socket
+	const args = 200000
+	var b strings.Builder
+	b.WriteString("*" + strconv.Itoa(args+1) + "\r\n$4\r\nMSET\r\n")
+	for i := 0; i < args; i++ {
+		arg := "k" + strconv.Itoa(i)
+		b.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n" + arg + "\r\n")
+	}
+	input := []byte(b.String())
+
+	const chunk = 4096
+	p := parser.NewCommandParser()
+	var pending []byte
+	var frames []parser.Frame
+	parsed := 0
+	for start := 0; start < len(input); start += chunk {
+		pending = append(pending, input[start:min(start+chunk, len(input))]...)
+		parsed += len(pending)
+
+		got, consumed, err := p.Parse(pending)
+		if err != nil {
+			t.Fatalf("Parse() failed: %v", err)
+		}
+		frames = append(frames, got...)
+		pending = pending[consumed:]
+	}
+
+	// Every call only sees the bytes of the last read and of an element that was not complete, so the work
+	// is linear in the size of the command
+	if parsed > 2*len(input) {
+		t.Errorf("Parse() was given %d bytes for a command of %d bytes", parsed, len(input))
+	}
+	if len(pending) != 0 {
+		t.Errorf("%d bytes left unconsumed", len(pending))
+	}
+	if len(frames) != 1 {
+		t.Fatalf("expected one command, got %d", len(frames))
+	}
+	command := frames[0].Value.([]interface{})
+	if len(command) != args+1 || command[0] != "MSET" || command[args] != "k"+strconv.Itoa(args-1) {
+		t.Errorf("the command has %d arguments, want %d ending with k%d", len(command), args+1, args-1)
+	}
+}
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
 	"github.com/Himanshu-Negi8/build-your-own-redis-server/types"
@@ -67,6 +68,9 @@ func Parse(r io.Reader) (interface{}, types.RESPType, error) {
 		if length == -1 {
 			return nil, types.RESPTypeBulkString, nil // Null bulk string
 		}
+		if length > MaxBulkLength {
+			return nil, "", &ProtocolError{Reason: "invalid bulk length"}
+		}
 		data := make([]byte, length)
 		if _, err := io.ReadFull(reader, data); err != nil {
 			return nil, "", err
@@ -87,6 +91,9 @@ func Parse(r io.Reader) (interface{}, types.RESPType, error) {
 		if length == -1 {
 			return nil, types.RESPTypeArray, nil // Null array
 		}
+		if length > MaxMultiBulkLength {
+			return nil, "", &ProtocolError{Reason: "invalid multibulk length"}
+		}
 		elements := make([]interface{}, length)
 		for i := 0; i < length; i++ {
 			// Since it's array we need to recursively call ParseRESP to parse each element.
@@ -121,3 +128,178 @@ func readLength(reader *bufio.Reader) (int, error) {
 	}
 	return strconv.Atoi(line)
 }
+
+// MaxBulkLength is the largest bulk string a client may send, like Redis's default proto-max-bulk-len of 512MB.
+const MaxBulkLength = 512 * 1024 * 1024
+
+// MaxMultiBulkLength is the largest number of elements an array sent by a client may hold.
+const MaxMultiBulkLength = 1024 * 1024
+
+// MaxLineLength is the longest line a frame may start with, like PROTO_INLINE_MAX_SIZE of Redis, so that a client
+// cannot make the server buffer a line that never ends.
+const MaxLineLength = 64 * 1024
+
+// minFrameLen is the size of the smallest RESP frame, an empty simple string "+\r\n".
+const minFrameLen = 3
+
+// ProtocolError is returned when a frame breaks the protocol in a way the reader cannot recover from,
+// such as a length over the limits above. The servers reply with it as a "Protocol error".
+type ProtocolError struct {
+	Reason string
+}
+
+func (e *ProtocolError) Error() string {
+	return "Protocol error: " + e.Reason
+}
+
+// ErrIncomplete is returned by ParseFrame when the buffer does not yet hold a complete RESP frame.
+// The caller is expected to keep the bytes and retry once more data has been read.
+var ErrIncomplete = errors.New("incomplete RESP frame")
+
+// ParseFrame parses a single RESP frame from the start of buf.
+// Unlike Parse, it works on an in-memory buffer that may hold a partial frame, which is what a non-blocking
+// reader accumulates across readiness events. It returns the parsed data, the type of the data and the number
+// of bytes the frame occupied, so that any bytes after the frame can be kept for the next command.
+// If buf does not hold a complete frame yet, ErrIncomplete is returned and nothing is consumed.
+func ParseFrame(buf []byte) (interface{}, types.RESPType, int, error) {
+	if len(buf) == 0 {
+		return nil, "", 0, ErrIncomplete
+	}
+
+	switch buf[0] {
+	case '+': // Simple String
+		line, n, err := frameLine(buf)
+		if err != nil {
+			return nil, "", 0, err
+		}
+		return line, types.RESPTypeSimpleString, n, nil
+	case '-': // Error
+		line, n, err := frameLine(buf)
+		if err != nil {
+			return nil, "", 0, err
+		}
+		return fmt.Errorf("redis error: %s", line), types.RESPTypeError, n, nil
+	case ':': // Integer
+		line, n, err := frameLine(buf)
+		if err != nil {
+			return nil, "", 0, err
+		}
+		intValue, err := strconv.Atoi(line)
+		if err != nil {
+			return nil, "", 0, err
+		}
+		return intValue, types.RESPTypeInteger, n, nil
+	case '$': // Bulk String
+		length, n, err := frameLength(buf)
+		if err != nil {
+			return nil, "", 0, err
+		}
+		if length == -1 {
+			return nil, types.RESPTypeBulkString, n, nil // Null bulk string
+		}
+		if length > MaxBulkLength {
+			return nil, "", 0, &ProtocolError{Reason: "invalid bulk length"}
+		}
+		// The data is followed by the trailing \r\n
+		end := n + length + 2
+		if len(buf) < end {
+			return nil, "", 0, ErrIncomplete
+		}
+		if buf[end-2] != '\r' || buf[end-1] != '\n' {
+			return nil, "", 0, errors.New("invalid bulk string terminator")
+		}
+		return string(buf[n : n+length]), types.RESPTypeBulkString, end, nil
+	case '*': // Array
+		length, n, err := frameLength(buf)
+		if err != nil {
+			return nil, "", 0, err
+		}
+		if length == -1 {
+			return nil, types.RESPTypeArray, n, nil // Null array
+		}
+		if length > MaxMultiBulkLength {
+			return nil, "", 0, &ProtocolError{Reason: "invalid multibulk length"}
+		}
+		// Only preallocate the elements the buffered bytes can hold, the length is client controlled
+		elements := make([]interface{}, 0, min(length, len(buf[n:])/minFrameLen))
+		for i := 0; i < length; i++ {
+			elem, _, elemLen, err := ParseFrame(buf[n:])
+			if err != nil {
+				return nil, "", 0, err
+			}
+			elements = append(elements, elem)
+			n += elemLen
+		}
+		return elements, types.RESPTypeArray, n, nil
+	default:
+		return nil, "", 0, errors.New("unknown prefix: " + string(buf[0]))
+	}
+}
+
+// frameLine returns the line following the prefix byte of buf without its \r\n,
+// and the number of bytes up to and including the \r\n.
+func frameLine(buf []byte) (string, int, error) {
+	// The \r\n is only looked for where it may end a line of MaxLineLength bytes
+	idx := bytes.Index(buf[:min(len(buf), MaxLineLength+2)], []byte("\r\n"))
+	if idx == -1 {
+		if len(buf) >= MaxLineLength+2 {
+			return "", 0, lineTooLong(buf[0])
+		}
+		return "", 0, ErrIncomplete
+	}
+	return string(buf[1:idx]), idx + 2, nil
+}
+
+// lineTooLong returns the error of a line longer than MaxLineLength, named after the frame it starts like in Redis.
+func lineTooLong(prefix byte) error {
+	switch prefix {
+	case '*':
+		return &ProtocolError{Reason: "too big mbulk count string"}
+	case '$':
+		return &ProtocolError{Reason: "too big bulk count string"}
+	}
+	return &ProtocolError{Reason: "too big inline request"}
+}
+
+// frameLength calls frameLine and changes the line into a length.
+func frameLength(buf []byte) (int, int, error) {
+	line, n, err := frameLine(buf)
+	if err != nil {
+		return 0, 0, err
+	}
+	length, err := strconv.Atoi(line)
+	if err != nil {
+		return 0, 0, err
+	}
+	if length < -1 {
+		return 0, 0, errors.New("invalid length: " + line)
+	}
+	return length, n, nil
+}
+
+// Frame is a parsed RESP frame together with its type.
+type Frame struct {
+	Value interface{}
+	Type  types.RESPType
+}
+
+// ParseFrames parses every complete RESP frame at the start of buf, which is how pipelined commands arrive.
+// It returns the frames in order and the number of bytes they occupied. A partial frame at the end of buf
+// is not an error: its bytes are simply not counted as consumed. If a malformed frame is found, the frames
+// parsed before it are returned together with the error.
+func ParseFrames(buf []byte) ([]Frame, int, error) {
+	var frames []Frame
+	consumed := 0
+	for consumed < len(buf) {
+		value, respType, n, err := ParseFrame(buf[consumed:])
+		if err != nil {
+			if errors.Is(err, ErrIncomplete) {
+				break
+			}
+			return frames, consumed, err
+		}
+		frames = append(frames, Frame{Value: value, Type: respType})
+		consumed += n
+	}
+	return frames, consumed, nil
+}
@@ -3,6 +3,8 @@ package parser_test
 import (
 	"bytes"
 	"errors"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/Himanshu-Negi8/build-your-own-redis-server/parser"
@@ -91,3 +93,170 @@ func TestParse(t *testing.T) {
 		})
 	}
 }
+
+func TestParseFrame(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          string
+		expectedResult interface{}
+		expectedType   types.RESPType
+		expectedLen    int
+		expectedError  error
+	}{
+		{
+			name:           "Simple String",
+			input:          "+OK\r\n",
+			expectedResult: "OK",
+			expectedType:   types.RESPTypeSimpleString,
+			expectedLen:    5,
+		},
+		{
+			name:           "Array",
+			input:          "*2\r\n$3\r\nfoo\r\n$3\r\nbar\r\n",
+			expectedResult: []interface{}{"foo", "bar"},
+			expectedType:   types.RESPTypeArray,
+			expectedLen:    22,
+		},
+		{
+			name:           "Array followed by the start of the next frame",
+			input:          "*1\r\n$4\r\nPING\r\n*3\r\n$3\r\nSET",
+			expectedResult: []interface{}{"PING"},
+			expectedType:   types.RESPTypeArray,
+			expectedLen:    14,
+		},
+		{
+			name:           "Bulk String with binary data",
+			input:          "$6\r\nfoo\r\nb\r\n",
+			expectedResult: "foo\r\nb",
+			expectedType:   types.RESPTypeBulkString,
+			expectedLen:    12,
+		},
+		{
+			name:          "Incomplete line",
+			input:         "*3\r\n$3\r\nSE",
+			expectedError: parser.ErrIncomplete,
+		},
+		{
+			name:          "Incomplete bulk string",
+			input:         "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$10\r\nvalue",
+			expectedError: parser.ErrIncomplete,
+		},
+		{
+			name:          "Missing bulk string terminator",
+			input:         "$3\r\nfoo",
+			expectedError: parser.ErrIncomplete,
+		},
+		{
+			name:          "Empty buffer",
+			input:         "",
+			expectedError: parser.ErrIncomplete,
+		},
+		{
+			name:          "Unknown Prefix",
+			input:         "!unknown\r\n",
+			expectedError: errors.New("unknown prefix: !"),
+		},
+		{
+			name:          "Bulk length over the limit",
+			input:         "$9223372036854775807\r\nab",
+			expectedError: errors.New("Protocol error: invalid bulk length"),
+		},
+		{
+			name:          "Multibulk length over the limit",
+			input:         "*100000000000\r\n",
+			expectedError: errors.New("Protocol error: invalid multibulk length"),
+		},
+		{
+			name:          "Bulk count line without an end",
+			input:         "$" + strings.Repeat("9", parser.MaxLineLength+1),
+			expectedError: errors.New("Protocol error: too big bulk count string"),
+		},
+		{
+			name:          "Large multibulk length with few buffered bytes",
+			input:         "*1048576\r\n$3\r\nSET\r\n",
+			expectedError: parser.ErrIncomplete,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, respType, n, err := parser.ParseFrame([]byte(tt.input))
+
+			if tt.expectedError != nil {
+				if err == nil || err.Error() != tt.expectedError.Error() {
+					t.Fatalf("expected error %v, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if !reflect.DeepEqual(result, tt.expectedResult) {
+				t.Errorf("expected result %v, got %v", tt.expectedResult, result)
+			}
+			if respType != tt.expectedType {
+				t.Errorf("expected respType %v, got %v", tt.expectedType, respType)
+			}
+			if n != tt.expectedLen {
+				t.Errorf("expected frame length %d, got %d", tt.expectedLen, n)
+			}
+		})
+	}
+}
+
+func TestParseFrames(t *testing.T) {
+	tests := []struct {
+		name             string
+		input            string
+		expectedFrames   []parser.Frame
+		expectedConsumed int
+		expectedError    error
+	}{
+		{
+			name:  "Pipelined commands",
+			input: "*1\r\n$4\r\nPING\r\n*2\r\n$3\r\nGET\r\n$1\r\na\r\n",
+			expectedFrames: []parser.Frame{
+				{Value: []interface{}{"PING"}, Type: types.RESPTypeArray},
+				{Value: []interface{}{"GET", "a"}, Type: types.RESPTypeArray},
+			},
+			expectedConsumed: 34,
+		},
+		{
+			name:  "Trailing partial frame",
+			input: "*1\r\n$4\r\nPING\r\n*2\r\n$3\r\nGE",
+			expectedFrames: []parser.Frame{
+				{Value: []interface{}{"PING"}, Type: types.RESPTypeArray},
+			},
+			expectedConsumed: 14,
+		},
+		{
+			name:  "Malformed frame after a valid one",
+			input: "*1\r\n$4\r\nPING\r\n!bad\r\n",
+			expectedFrames: []parser.Frame{
+				{Value: []interface{}{"PING"}, Type: types.RESPTypeArray},
+			},
+			expectedConsumed: 14,
+			expectedError:    errors.New("unknown prefix: !"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frames, consumed, err := parser.ParseFrames([]byte(tt.input))
+
+			if tt.expectedError != nil {
+				if err == nil || err.Error() != tt.expectedError.Error() {
+					t.Fatalf("expected error %v, got %v", tt.expectedError, err)
+				}
+			} else if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if !reflect.DeepEqual(frames, tt.expectedFrames) {
+				t.Errorf("expected frames %v, got %v", tt.expectedFrames, frames)
+			}
+			if consumed != tt.expectedConsumed {
+				t.Errorf("expected %d bytes consumed, got %d", tt.expectedConsumed, consumed)
+			}
+		})
+	}
+}
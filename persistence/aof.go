@@ -0,0 +1,364 @@
+package persistence
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/keyspace"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/parser"
+)
+
+// FsyncPolicy is how often the append-only file is flushed to disk, the appendfsync setting of Redis.
+type FsyncPolicy int
+
+const (
+	// FsyncAlways flushes the file after every command, so that no acknowledged write is ever lost
+	FsyncAlways FsyncPolicy = iota
+	// FsyncEverySec flushes the file once per second in the background, losing at most a second of writes
+	FsyncEverySec
+	// FsyncNo leaves flushing to the operating system
+	FsyncNo
+)
+
+// fsyncInterval is how often the file is flushed with FsyncEverySec.
+const fsyncInterval = time.Second
+
+// ParseFsyncPolicy parses a policy named like the values of appendfsync: always, everysec or no.
+func ParseFsyncPolicy(s string) (FsyncPolicy, error) {
+	switch s {
+	case "always":
+		return FsyncAlways, nil
+	case "everysec":
+		return FsyncEverySec, nil
+	case "no":
+		return FsyncNo, nil
+	}
+	return 0, fmt.Errorf("invalid appendfsync policy %q", s)
+}
+
+func (p FsyncPolicy) String() string {
+	switch p {
+	case FsyncAlways:
+		return "always"
+	case FsyncEverySec:
+		return "everysec"
+	}
+	return "no"
+}
+
+// AOF is an append-only file, which logs the write commands in RESP form so that they can be replayed on startup.
+// The file may start with an RDB preamble, holding the keyspace at the time the file was created, like the
+// files Redis writes with aof-use-rdb-preamble.
+type AOF struct {
+	mu     sync.Mutex
+	f      *os.File
+	policy FsyncPolicy
+	// unsynced is set when commands were written since the file was last flushed
+	unsynced bool
+	// err is the last error writing or flushing the file, reported by INFO
+	err  error
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// OpenAOF opens the append-only file at path to append commands to it. A missing file is created, starting with
+// an RDB preamble of the keyspace, so that the keys loaded from elsewhere, such as an RDB file, are not lost
+// when the server restarts from the append-only file.
+func OpenAOF(path string, policy FsyncPolicy, cache keyspace.Keyspace) (*AOF, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		if cache.Len() > 0 {
+			snapshot := cache.Snapshot()
+			err := writeFile(path, snapshot)
+			snapshot.Release()
+			if err != nil {
+				return nil, err
+			}
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &AOF{f: f, policy: policy, done: make(chan struct{})}
+	if policy == FsyncEverySec {
+		a.wg.Add(1)
+		go a.fsyncLoop()
+	}
+	return a, nil
+}
+
+// Append writes the commands to the file, each given as its name and arguments.
+func (a *AOF) Append(commands [][]string) error {
+	var buf []byte
+	for _, args := range commands {
+		buf = appendCommand(buf, args)
+	}
+	if len(buf) == 0 {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.f.Write(buf); err != nil {
+		a.err = err
+		return err
+	}
+	if a.policy == FsyncAlways {
+		if err := a.f.Sync(); err != nil {
+			a.err = err
+			return err
+		}
+	} else {
+		a.unsynced = true
+	}
+	a.err = nil
+	return nil
+}
+
+// appendCommand appends the RESP encoding of a command to buf.
+func appendCommand(buf []byte, args []string) []byte {
+	if len(args) == 0 {
+		return buf
+	}
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+	return buf
+}
+
+// fsyncLoop flushes the file every fsyncInterval until the file is closed. The file is flushed without holding
+// the lock, so that the commands appended meanwhile do not wait for the disk.
+func (a *AOF) fsyncLoop() {
+	defer a.wg.Done()
+	ticker := time.NewTicker(fsyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.done:
+			return
+		case <-ticker.C:
+			a.mu.Lock()
+			unsynced := a.unsynced
+			a.unsynced = false
+			a.mu.Unlock()
+			if !unsynced {
+				continue
+			}
+			if err := a.f.Sync(); err != nil {
+				log.Printf("failed to flush the append-only file: %v", err)
+				a.mu.Lock()
+				a.err = err
+				a.mu.Unlock()
+			}
+		}
+	}
+}
+
+// Close flushes the file to disk and closes it.
+func (a *AOF) Close() error {
+	close(a.done)
+	a.wg.Wait()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.f.Sync(); err != nil {
+		a.f.Close()
+		return err
+	}
+	return a.f.Close()
+}
+
+// Err returns the last error writing the file, nil if the last write succeeded.
+func (a *AOF) Err() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.err
+}
+
+var (
+	// appendOnly is the append-only file the writes are logged to, nil when it is disabled
+	appendOnly atomic.Pointer[AOF]
+	// appendMu keeps the writes from running concurrently while they are logged, so that the file holds them
+	// in the order they modified the keyspace
+	appendMu sync.Mutex
+)
+
+// EnableAOF makes LogWrite append the writes to the file, or stops logging them when a is nil.
+func EnableAOF(a *AOF) {
+	appendOnly.Store(a)
+}
+
+// AppendOnly returns the append-only file the writes are logged to, nil when it is disabled.
+func AppendOnly() *AOF {
+	return appendOnly.Load()
+}
+
+// LogWrite runs a write command and appends the commands it returns to the append-only file, if it is enabled.
+// The write returns the commands that reproduce its effect when they are replayed, which are not always the
+// command itself: a relative expiration time must be logged as an absolute one, for instance.
+func LogWrite(write func() [][]string) {
+	a := appendOnly.Load()
+	if a == nil {
+		write()
+		return
+	}
+
+	appendMu.Lock()
+	defer appendMu.Unlock()
+	// The file may have been closed while the write waited for the lock
+	if a = appendOnly.Load(); a == nil {
+		write()
+		return
+	}
+	if err := a.Append(write()); err != nil {
+		log.Printf("failed to write to the append-only file: %v", err)
+	}
+}
+
+// CloseAOF stops logging the writes and closes the append-only file once the writes being logged are in it,
+// flushing it to disk, so that no write acknowledged to a client is lost when the server shuts down.
+func CloseAOF() error {
+	appendMu.Lock()
+	defer appendMu.Unlock()
+	a := appendOnly.Swap(nil)
+	if a == nil {
+		return nil
+	}
+	return a.Close()
+}
+
+// LoadAOF replays the append-only file at path into the keyspace and returns the number of commands replayed.
+// An RDB preamble is loaded into the keyspace directly, and every command is passed to replay, given as its name
+// and arguments. A file whose last command is incomplete, as left by a crash in the middle of a write, is
+// truncated to its last complete command, like Redis does with aof-load-truncated. A missing file is not an error.
+// The writes of the replayed commands are in the file already, so they do not count as changes. No key expires
+// while the file is loaded, so that the commands replay the way they ran, like in Redis.
+func LoadAOF(path string, cache keyspace.Keyspace, replay func(args []string) error) (int, error) {
+	cache.SetLoading(true)
+	defer cache.SetLoading(false)
+	defer func(changes int64) {
+		statusMu.Lock()
+		defer statusMu.Unlock()
+		status.Changes = changes
+	}(Status().Changes)
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	offset := int64(0)
+	if preamble, err := r.Peek(5); err == nil && string(preamble) == "REDIS" {
+		d := &decoder{r: r}
+		if _, err := d.load(cache); err != nil {
+			return 0, err
+		}
+		offset = d.offset
+	}
+
+	replayed, db := 0, 0
+	var pending []byte
+	buf := make([]byte, 64*1024)
+	for {
+		n, readErr := r.Read(buf)
+		pending = append(pending, buf[:n]...)
+
+		for len(pending) > 0 {
+			value, _, size, err := parser.ParseFrame(pending)
+			if errors.Is(err, parser.ErrIncomplete) {
+				break
+			}
+			if err != nil {
+				return replayed, &LoadError{Offset: offset, Reason: fmt.Sprintf("invalid command in the append-only file: %v", err)}
+			}
+			args, ok := commandArgs(value)
+			if !ok {
+				return replayed, &LoadError{Offset: offset, Reason: "the append-only file holds a value that is not a command"}
+			}
+
+			switch strings.ToUpper(args[0]) {
+			case "SELECT":
+				// Files written by Redis select the database of the commands that follow, and the commands of
+				// databases other than 0 are skipped since the server only has one database
+				if len(args) != 2 {
+					return replayed, &LoadError{Offset: offset, Reason: "invalid SELECT command"}
+				}
+				if db, err = strconv.Atoi(args[1]); err != nil {
+					return replayed, &LoadError{Offset: offset, Reason: "invalid SELECT command"}
+				}
+			case "MULTI", "EXEC":
+				// Transactions are logged by Redis between MULTI and EXEC, whose commands are replayed in order
+			default:
+				if db == 0 {
+					if err := replay(args); err != nil {
+						return replayed, &LoadError{Offset: offset, Reason: err.Error()}
+					}
+					replayed++
+				}
+			}
+			offset += int64(size)
+			pending = pending[size:]
+		}
+
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return replayed, readErr
+		}
+	}
+
+	if len(pending) > 0 {
+		log.Printf("the append-only file %s ends with an incomplete command, truncating it from %d to %d bytes",
+			path, offset+int64(len(pending)), offset)
+		if err := os.Truncate(path, offset); err != nil {
+			return replayed, err
+		}
+	}
+	return replayed, nil
+}
+
+// commandArgs converts a command read from the append-only file to its name and arguments.
+func commandArgs(value interface{}) ([]string, bool) {
+	elems, ok := value.([]interface{})
+	if !ok || len(elems) == 0 {
+		return nil, false
+	}
+	args := make([]string, len(elems))
+	for i, elem := range elems {
+		if args[i], ok = elem.(string); !ok {
+			return nil, false
+		}
+	}
+	return args, true
+}
@@ -0,0 +1,202 @@
+package persistence_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/keyspace"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/persistence"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/types"
+)
+
+// replayAll loads the append-only file and returns the commands it replayed.
+func replayAll(t *testing.T, path string, cache keyspace.Keyspace) [][]string {
+	t.Helper()
+	var commands [][]string
+	before := persistence.Status().Changes
+	n, err := persistence.LoadAOF(path, cache, func(args []string) error {
+		commands = append(commands, args)
+		// The commands count their changes when they are replayed, like when they ran
+		persistence.AddChanges(1)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("LoadAOF() failed: %v", err)
+	}
+	if n != len(commands) {
+		t.Errorf("LoadAOF() = %d, want %d commands", n, len(commands))
+	}
+	if changes := persistence.Status().Changes - before; changes != 0 {
+		t.Errorf("LoadAOF() counted %d changes, want none", changes)
+	}
+	return commands
+}
+
+func TestParseFsyncPolicy(t *testing.T) {
+	for _, name := range []string{"always", "everysec", "no"} {
+		policy, err := persistence.ParseFsyncPolicy(name)
+		if err != nil || policy.String() != name {
+			t.Errorf("ParseFsyncPolicy(%q) = %v, %v", name, policy, err)
+		}
+	}
+	if _, err := persistence.ParseFsyncPolicy("sometimes"); err == nil {
+		t.Errorf("ParseFsyncPolicy(sometimes) did not fail")
+	}
+}
+
+func TestAOFAppendAndLoad(t *testing.T) {
+	commands := [][]string{{"SET", "k", "v"}, {"RPUSH", "l", "a", "", "b\r\nc"}, {"DEL", "k"}}
+
+	for _, policy := range []persistence.FsyncPolicy{persistence.FsyncAlways, persistence.FsyncEverySec, persistence.FsyncNo} {
+		t.Run(policy.String(), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "appendonly.aof")
+			aof, err := persistence.OpenAOF(path, policy, keyspace.New())
+			if err != nil {
+				t.Fatalf("OpenAOF() failed: %v", err)
+			}
+			if err := aof.Append(commands[:2]); err != nil {
+				t.Fatalf("Append() failed: %v", err)
+			}
+			if err := aof.Append(commands[2:]); err != nil {
+				t.Fatalf("Append() failed: %v", err)
+			}
+			if err := aof.Close(); err != nil {
+				t.Fatalf("Close() failed: %v", err)
+			}
+
+			data, _ := os.ReadFile(path)
+			if want := "*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$1\r\nv\r\n"; string(data[:len(want)]) != want {
+				t.Errorf("the file starts with %q, want %q", data[:len(want)], want)
+			}
+			if got := replayAll(t, path, keyspace.New()); !reflect.DeepEqual(got, commands) {
+				t.Errorf("replayed %q, want %q", got, commands)
+			}
+		})
+	}
+}
+
+func TestCloseAOF(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	aof, err := persistence.OpenAOF(path, persistence.FsyncEverySec, keyspace.New())
+	if err != nil {
+		t.Fatalf("OpenAOF() failed: %v", err)
+	}
+	persistence.EnableAOF(aof)
+	defer persistence.EnableAOF(nil)
+
+	persistence.LogWrite(func() [][]string { return [][]string{{"SET", "k", "v"}} })
+	if err := persistence.CloseAOF(); err != nil {
+		t.Fatalf("CloseAOF() failed: %v", err)
+	}
+	if persistence.AppendOnly() != nil {
+		t.Errorf("the append-only file is still enabled after CloseAOF()")
+	}
+
+	// The writes that follow run without being logged
+	ran := false
+	persistence.LogWrite(func() [][]string {
+		ran = true
+		return [][]string{{"DEL", "k"}}
+	})
+	if !ran {
+		t.Errorf("LogWrite() did not run the write after CloseAOF()")
+	}
+	if got := replayAll(t, path, keyspace.New()); !reflect.DeepEqual(got, [][]string{{"SET", "k", "v"}}) {
+		t.Errorf("replayed %q, want the SET logged before CloseAOF()", got)
+	}
+	if err := persistence.CloseAOF(); err != nil {
+		t.Errorf("CloseAOF() without an append-only file failed: %v", err)
+	}
+}
+
+func TestAOFPreamble(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	cache := keyspace.New()
+	cache.Set("k", types.CustomValue{Value: "v", ValueExpiration: -1})
+
+	// The keys loaded before the file is created are written as an RDB preamble
+	aof, err := persistence.OpenAOF(path, persistence.FsyncNo, cache)
+	if err != nil {
+		t.Fatalf("OpenAOF() failed: %v", err)
+	}
+	aof.Append([][]string{{"SET", "other", "v"}})
+	aof.Close()
+
+	loaded := keyspace.New()
+	if got := replayAll(t, path, loaded); !reflect.DeepEqual(got, [][]string{{"SET", "other", "v"}}) {
+		t.Errorf("replayed %q, want the SET of other", got)
+	}
+	if value, ok := loaded.Get("k"); !ok || value.Value != "v" {
+		t.Errorf("k was not loaded from the preamble")
+	}
+
+	// Reopening an existing file appends to it
+	aof, err = persistence.OpenAOF(path, persistence.FsyncNo, loaded)
+	if err != nil {
+		t.Fatalf("OpenAOF() failed: %v", err)
+	}
+	aof.Append([][]string{{"DEL", "k"}})
+	aof.Close()
+	if got := replayAll(t, path, keyspace.New()); len(got) != 2 {
+		t.Errorf("replayed %q, want 2 commands", got)
+	}
+}
+
+func TestAOFTruncatedTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	complete := "*2\r\n$4\r\nINCR\r\n$1\r\nn\r\n"
+	if err := os.WriteFile(path, []byte(complete+"*2\r\n$4\r\nINCR\r\n$1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := replayAll(t, path, keyspace.New()); !reflect.DeepEqual(got, [][]string{{"INCR", "n"}}) {
+		t.Errorf("replayed %q, want a single INCR", got)
+	}
+	if data, _ := os.ReadFile(path); string(data) != complete {
+		t.Errorf("the file was truncated to %q, want %q", data, complete)
+	}
+}
+
+func TestAOFRedisFile(t *testing.T) {
+	// Files written by Redis select the database and wrap transactions in MULTI and EXEC
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	data := "*2\r\n$6\r\nSELECT\r\n$1\r\n0\r\n*1\r\n$5\r\nMULTI\r\n*3\r\n$3\r\nSET\r\n$1\r\na\r\n$1\r\n1\r\n*1\r\n$4\r\nEXEC\r\n" +
+		"*2\r\n$6\r\nSELECT\r\n$1\r\n3\r\n*3\r\n$3\r\nSET\r\n$1\r\nb\r\n$1\r\n2\r\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := replayAll(t, path, keyspace.New()); !reflect.DeepEqual(got, [][]string{{"SET", "a", "1"}}) {
+		t.Errorf("replayed %q, want the SET of database 0 only", got)
+	}
+}
+
+func TestAOFErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		offset int64
+	}{
+		{name: "not a command", data: "*1\r\n$4\r\nPING\r\n+OK\r\n", offset: 14},
+		{name: "malformed frame", data: "*1\r\n$4\r\nPING\r\n*1\r\n$x\r\n", offset: 14},
+		{name: "corrupt preamble", data: "REDIS0009\xfe", offset: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "appendonly.aof")
+			if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := persistence.LoadAOF(path, keyspace.New(), func(args []string) error { return nil })
+			var loadErr *persistence.LoadError
+			if !errors.As(err, &loadErr) || loadErr.Offset != tt.offset {
+				t.Errorf("LoadAOF() error = %v, want a LoadError at offset %d", err, tt.offset)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package persistence
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/keyspace"
+)
+
+// ErrSaveInProgress is returned when a save is requested while a background save is running.
+var ErrSaveInProgress = errors.New("background save already in progress")
+
+// SaveStatus describes the saves since the server started, as reported by LASTSAVE and INFO.
+type SaveStatus struct {
+	// LastSave is the time of the last successful save, or the start of the server if there was none
+	LastSave time.Time
+	// Changes is the number of changes to the keys of the keyspace since the last successful save
+	Changes int64
+	// Saves is the number of successful saves
+	Saves int
+	// BgsaveInProgress is set while a background save runs, which started at BgsaveStart
+	BgsaveInProgress bool
+	BgsaveStart      time.Time
+	// LastBgsaveErr is the error of the last background save, nil if it succeeded or there was none
+	LastBgsaveErr error
+	// LastBgsaveDuration is the time the last background save took, or -1 if there was none
+	LastBgsaveDuration time.Duration
+	// lastBgsaveTry is the time the last background save started, which delays the retries of automatic saves
+	lastBgsaveTry time.Time
+}
+
+var (
+	statusMu sync.Mutex
+	status   = SaveStatus{LastSave: time.Now(), LastBgsaveDuration: -1}
+)
+
+// Status returns the current state of the saves.
+func Status() SaveStatus {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	return status
+}
+
+// AddChanges counts the keys a write command changed, or the elements it changed in a collection, which decide
+// when the save rules trigger a background save. Commands that leave the keyspace unchanged count no changes.
+func AddChanges(n int64) {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	status.Changes += n
+}
+
+// saved records a successful save at the given time, of a snapshot taken after the given number of changes.
+// The status must be locked.
+func saved(at time.Time, changes int64) {
+	status.LastSave = at
+	status.Saves++
+	// The changes made while the file was written are not part of it, and still count for the next save
+	status.Changes -= changes
+}
+
+// BackgroundSave starts writing the keyspace to the RDB file at path in the background, and returns without
+// waiting for the file to be written. The file holds the keyspace as it is when BackgroundSave is called, however
+// commands modify it meanwhile, like the forked child of BGSAVE in Redis. Only one background save runs at a time,
+// and BackgroundSave fails with ErrSaveInProgress while there is one.
+func BackgroundSave(path string, cache keyspace.Keyspace) error {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	return backgroundSave(path, cache)
+}
+
+// backgroundSave starts a background save. The status must be locked.
+func backgroundSave(path string, cache keyspace.Keyspace) error {
+	if status.BgsaveInProgress {
+		return ErrSaveInProgress
+	}
+	start := time.Now()
+	status.BgsaveInProgress = true
+	status.BgsaveStart = start
+	status.lastBgsaveTry = start
+
+	// The snapshot is taken before returning, so that the commands that follow are not part of the file
+	changes := status.Changes
+	snapshot := cache.Snapshot()
+	go func() {
+		err := writeFile(path, snapshot)
+		snapshot.Release()
+		if err != nil {
+			log.Printf("background save to %s failed: %v", path, err)
+		}
+
+		end := time.Now()
+		statusMu.Lock()
+		defer statusMu.Unlock()
+		status.BgsaveInProgress = false
+		status.LastBgsaveErr = err
+		status.LastBgsaveDuration = end.Sub(start)
+		if err == nil {
+			saved(end, changes)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,30 @@
+package persistence
+
+// crc64Table is the lookup table of the CRC-64/Jones checksum that ends RDB files.
+var crc64Table = makeCRC64Table(0x95ac9329ac4bc9b5)
+
+// makeCRC64Table builds the lookup table of a reflected CRC-64 for the given reversed polynomial.
+func makeCRC64Table(poly uint64) *[256]uint64 {
+	table := new([256]uint64)
+	for i := range table {
+		crc := uint64(i)
+		for j := 0; j < 8; j++ {
+			if crc&1 == 1 {
+				crc = crc>>1 ^ poly
+			} else {
+				crc >>= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}
+
+// crc64 updates the CRC-64/Jones checksum crc with p. Redis starts from 0 and does not invert the checksum
+// before or after, unlike hash/crc64 of the standard library, which is why the checksum is computed here.
+func crc64(crc uint64, p []byte) uint64 {
+	for _, b := range p {
+		crc = crc64Table[byte(crc)^b] ^ crc>>8
+	}
+	return crc
+}
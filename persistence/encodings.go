@@ -0,0 +1,351 @@
+package persistence
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Decoders of the compact encodings Redis stores small collections in. RDB files hold these encodings
+// as strings, which are decoded here into their elements.
+
+var errTruncated = errors.New("truncated encoding")
+
+// blob reads the consecutive fields of a compact encoding, failing instead of reading past its end.
+type blob struct {
+	buf []byte
+	pos int
+}
+
+func (b *blob) next(n int) ([]byte, error) {
+	if n < 0 || n > len(b.buf)-b.pos {
+		return nil, errTruncated
+	}
+	p := b.buf[b.pos : b.pos+n]
+	b.pos += n
+	return p, nil
+}
+
+func (b *blob) byte() (byte, error) {
+	p, err := b.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return p[0], nil
+}
+
+// int24 decodes a little endian signed 24-bit integer.
+func int24(p []byte) int64 {
+	return int64(int32(uint32(p[0])<<8|uint32(p[1])<<16|uint32(p[2])<<24) >> 8)
+}
+
+// ziplistEntries decodes a ziplist, the encoding of small lists, hashes and sorted sets before Redis 7.0.
+func ziplistEntries(zl []byte) ([]string, error) {
+	// The header holds the size of the ziplist, the offset of its last entry and its number of entries
+	b := &blob{buf: zl}
+	if _, err := b.next(10); err != nil {
+		return nil, err
+	}
+
+	var entries []string
+	for {
+		flag, err := b.byte()
+		if err != nil {
+			return nil, err
+		}
+		if flag == 0xff {
+			return entries, nil
+		}
+
+		// Every entry starts with the length of the previous one, which is skipped
+		if flag == 0xfe {
+			if _, err := b.next(4); err != nil {
+				return nil, err
+			}
+		}
+
+		enc, err := b.byte()
+		if err != nil {
+			return nil, err
+		}
+		var entry string
+		var p []byte
+		switch {
+		case enc>>6 == 0:
+			p, err = b.next(int(enc & 0x3f))
+			entry = string(p)
+		case enc>>6 == 1:
+			var low byte
+			if low, err = b.byte(); err == nil {
+				p, err = b.next(int(enc&0x3f)<<8 | int(low))
+				entry = string(p)
+			}
+		case enc == 0x80:
+			if p, err = b.next(4); err == nil {
+				p, err = b.next(int(binary.BigEndian.Uint32(p)))
+				entry = string(p)
+			}
+		case enc == 0xc0:
+			if p, err = b.next(2); err == nil {
+				entry = strconv.FormatInt(int64(int16(binary.LittleEndian.Uint16(p))), 10)
+			}
+		case enc == 0xd0:
+			if p, err = b.next(4); err == nil {
+				entry = strconv.FormatInt(int64(int32(binary.LittleEndian.Uint32(p))), 10)
+			}
+		case enc == 0xe0:
+			if p, err = b.next(8); err == nil {
+				entry = strconv.FormatInt(int64(binary.LittleEndian.Uint64(p)), 10)
+			}
+		case enc == 0xf0:
+			if p, err = b.next(3); err == nil {
+				entry = strconv.FormatInt(int24(p), 10)
+			}
+		case enc == 0xfe:
+			if p, err = b.next(1); err == nil {
+				entry = strconv.FormatInt(int64(int8(p[0])), 10)
+			}
+		case enc >= 0xf1 && enc <= 0xfd:
+			// Integers from 0 to 12 are stored in the encoding itself
+			entry = strconv.Itoa(int(enc&0x0f) - 1)
+		default:
+			return nil, fmt.Errorf("invalid ziplist entry encoding %#x", enc)
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// listpackEntries decodes a listpack, the encoding of small lists, hashes, sets and sorted sets since Redis 7.0.
+func listpackEntries(lp []byte) ([]string, error) {
+	// The header holds the size of the listpack and its number of entries
+	b := &blob{buf: lp}
+	if _, err := b.next(6); err != nil {
+		return nil, err
+	}
+
+	var entries []string
+	for {
+		start := b.pos
+		enc, err := b.byte()
+		if err != nil {
+			return nil, err
+		}
+		if enc == 0xff {
+			return entries, nil
+		}
+
+		var entry string
+		var p []byte
+		switch {
+		case enc&0x80 == 0:
+			entry = strconv.Itoa(int(enc))
+		case enc&0xc0 == 0x80:
+			p, err = b.next(int(enc & 0x3f))
+			entry = string(p)
+		case enc&0xe0 == 0xc0:
+			if p, err = b.next(1); err == nil {
+				// A 13-bit two's complement integer
+				n := int64(enc&0x1f)<<8 | int64(p[0])
+				if n >= 1<<12 {
+					n -= 1 << 13
+				}
+				entry = strconv.FormatInt(n, 10)
+			}
+		case enc&0xf0 == 0xe0:
+			if p, err = b.next(1); err == nil {
+				p, err = b.next(int(enc&0x0f)<<8 | int(p[0]))
+				entry = string(p)
+			}
+		case enc == 0xf0:
+			if p, err = b.next(4); err == nil {
+				p, err = b.next(int(binary.LittleEndian.Uint32(p)))
+				entry = string(p)
+			}
+		case enc == 0xf1:
+			if p, err = b.next(2); err == nil {
+				entry = strconv.FormatInt(int64(int16(binary.LittleEndian.Uint16(p))), 10)
+			}
+		case enc == 0xf2:
+			if p, err = b.next(3); err == nil {
+				entry = strconv.FormatInt(int24(p), 10)
+			}
+		case enc == 0xf3:
+			if p, err = b.next(4); err == nil {
+				entry = strconv.FormatInt(int64(int32(binary.LittleEndian.Uint32(p))), 10)
+			}
+		case enc == 0xf4:
+			if p, err = b.next(8); err == nil {
+				entry = strconv.FormatInt(int64(binary.LittleEndian.Uint64(p)), 10)
+			}
+		default:
+			return nil, fmt.Errorf("invalid listpack entry encoding %#x", enc)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Every entry ends with its own length, used to walk the listpack backwards, which is skipped
+		if _, err := b.next(backlenSize(b.pos - start)); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// backlenSize returns the number of bytes the length of a listpack entry of n bytes is stored in.
+func backlenSize(n int) int {
+	switch {
+	case n <= 127:
+		return 1
+	case n < 16383:
+		return 2
+	case n < 2097151:
+		return 3
+	case n < 268435455:
+		return 4
+	}
+	return 5
+}
+
+// intsetEntries decodes an intset, the encoding of small sets of integers.
+func intsetEntries(is []byte) ([]string, error) {
+	b := &blob{buf: is}
+	header, err := b.next(8)
+	if err != nil {
+		return nil, err
+	}
+	size := int(binary.LittleEndian.Uint32(header))
+	count := int(binary.LittleEndian.Uint32(header[4:]))
+	if size != 2 && size != 4 && size != 8 {
+		return nil, fmt.Errorf("invalid intset integer size %d", size)
+	}
+
+	data, err := b.next(size * count)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]string, count)
+	for i := range entries {
+		p := data[i*size:]
+		switch size {
+		case 2:
+			entries[i] = strconv.FormatInt(int64(int16(binary.LittleEndian.Uint16(p))), 10)
+		case 4:
+			entries[i] = strconv.FormatInt(int64(int32(binary.LittleEndian.Uint32(p))), 10)
+		default:
+			entries[i] = strconv.FormatInt(int64(binary.LittleEndian.Uint64(p)), 10)
+		}
+	}
+	return entries, nil
+}
+
+// zipmapEntries decodes a zipmap, the encoding of small hashes before Redis 2.6, into fields and values.
+func zipmapEntries(zm []byte) ([]string, error) {
+	// The header holds the number of fields
+	b := &blob{buf: zm}
+	if _, err := b.next(1); err != nil {
+		return nil, err
+	}
+
+	readLength := func() (int, bool, error) {
+		n, err := b.byte()
+		switch {
+		case err != nil:
+			return 0, false, err
+		case n == 0xff:
+			return 0, true, nil
+		case n < 254:
+			return int(n), false, nil
+		case n == 254:
+			p, err := b.next(4)
+			if err != nil {
+				return 0, false, err
+			}
+			return int(binary.LittleEndian.Uint32(p)), false, nil
+		}
+		return 0, false, fmt.Errorf("invalid zipmap length %#x", n)
+	}
+
+	var entries []string
+	for {
+		n, end, err := readLength()
+		if err != nil {
+			return nil, err
+		}
+		if end {
+			return entries, nil
+		}
+		field, err := b.next(n)
+		if err != nil {
+			return nil, err
+		}
+
+		if n, end, err = readLength(); err != nil || end {
+			return nil, errTruncated
+		}
+		// The value is followed by unused bytes, left by updates to shorter values
+		free, err := b.byte()
+		if err != nil {
+			return nil, err
+		}
+		value, err := b.next(n)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := b.next(int(free)); err != nil {
+			return nil, err
+		}
+		entries = append(entries, string(field), string(value))
+	}
+}
+
+// lzfDecompress decompresses the LZF compressed data, which must expand to exactly length bytes.
+func lzfDecompress(in []byte, length int) ([]byte, error) {
+	out := make([]byte, 0, length)
+	for ip := 0; ip < len(in); {
+		ctrl := int(in[ip])
+		ip++
+
+		// A literal run of ctrl+1 bytes
+		if ctrl < 1<<5 {
+			if ip+ctrl+1 > len(in) || len(out)+ctrl+1 > length {
+				return nil, errors.New("invalid LZF literal run")
+			}
+			out = append(out, in[ip:ip+ctrl+1]...)
+			ip += ctrl + 1
+			continue
+		}
+
+		// A back reference, copying n bytes starting off+1 bytes before the end of the output
+		n := ctrl >> 5
+		if n == 7 {
+			if ip >= len(in) {
+				return nil, errTruncated
+			}
+			n += int(in[ip])
+			ip++
+		}
+		if ip >= len(in) {
+			return nil, errTruncated
+		}
+		ref := len(out) - (ctrl&0x1f)<<8 - int(in[ip]) - 1
+		ip++
+		n += 2
+		if ref < 0 || len(out)+n > length {
+			return nil, errors.New("invalid LZF back reference")
+		}
+		// The reference may overlap the bytes it produces, so they are copied one at a time
+		for i := 0; i < n; i++ {
+			out = append(out, out[ref+i])
+		}
+	}
+
+	if len(out) != length {
+		return nil, fmt.Errorf("LZF data expands to %d bytes instead of %d", len(out), length)
+	}
+	return out, nil
+}
@@ -0,0 +1,65 @@
+// Package persistence saves the keyspace to disk in the RDB format of Redis and loads it back, so that the
+// files it writes can be loaded by Redis and the files Redis writes can be loaded by the server.
+package persistence
+
+// Opcodes, value types and string encodings of the RDB format.
+const (
+	// rdbVersion is the version of the files written, and maxRDBVersion the latest version that can be loaded.
+	rdbVersion    = 9
+	maxRDBVersion = 12
+
+	opSlotInfo      = 0xf4
+	opFunction2     = 0xf5
+	opFunctionPreGA = 0xf6
+	opModuleAux     = 0xf7
+	opIdle          = 0xf8
+	opFreq          = 0xf9
+	opAux           = 0xfa
+	opResizeDB      = 0xfb
+	opExpireTimeMs  = 0xfc
+	opExpireTime    = 0xfd
+	opSelectDB      = 0xfe
+	opEOF           = 0xff
+
+	typeString           = 0
+	typeList             = 1
+	typeSet              = 2
+	typeZSet             = 3
+	typeHash             = 4
+	typeZSet2            = 5
+	typeModulePreGA      = 6
+	typeModule2          = 7
+	typeHashZipmap       = 9
+	typeListZiplist      = 10
+	typeSetIntset        = 11
+	typeZSetZiplist      = 12
+	typeHashZiplist      = 13
+	typeListQuicklist    = 14
+	typeStreamListpacks  = 15
+	typeHashListpack     = 16
+	typeZSetListpack     = 17
+	typeListQuicklist2   = 18
+	typeStreamListpacks2 = 19
+	typeSetListpack      = 20
+	typeStreamListpacks3 = 21
+
+	// Lengths are encoded on 6 bits, 14 bits, 32 bits or 64 bits, depending on their two most significant bits.
+	len6Bit  = 0
+	len14Bit = 1
+	len32Bit = 0x80
+	len64Bit = 0x81
+	// lenEncoded marks a string stored in a special encoding, given by the 6 low bits.
+	lenEncoded = 3
+
+	encInt8  = 0
+	encInt16 = 1
+	encInt32 = 2
+	encLZF   = 3
+
+	// The nodes of a quicklist2 hold either a single element or a listpack of elements.
+	quicklistNodePlain  = 1
+	quicklistNodePacked = 2
+)
+
+// redisVersion is the version of Redis the RDB files claim to be written by, in their redis-ver aux field.
+const redisVersion = "7.0.0"
@@ -0,0 +1,552 @@
+package persistence
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/datatypes"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/keyspace"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/types"
+)
+
+// maxStringLength is the longest string accepted in an RDB file, the default proto-max-bulk-len of Redis.
+// It keeps a corrupt length from allocating unbounded memory.
+const maxStringLength = 512 * 1024 * 1024
+
+// LoadError reports an RDB file that cannot be loaded, because it is corrupt or uses a feature the server
+// does not support, along with the offset in the file where the problem was found.
+type LoadError struct {
+	Offset int64
+	Reason string
+}
+
+func (e *LoadError) Error() string {
+	return fmt.Sprintf("invalid RDB file at offset %d: %s", e.Offset, e.Reason)
+}
+
+// Load reads the RDB file at path into the keyspace and returns the number of keys loaded.
+// A missing file is not an error, it leaves the keyspace empty.
+func Load(path string, cache keyspace.Keyspace) (int, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	return Read(f, cache)
+}
+
+// Read decodes an RDB file from r into the keyspace and returns the number of keys loaded. It accepts the
+// files written by Redis up to RDB version 12, including the compact encodings of small collections.
+// Keys that have already expired are skipped, unless the keyspace is loading an append-only file whose commands
+// may still modify them, and so are the keys of databases other than 0, since the server only has one database.
+func Read(r io.Reader, cache keyspace.Keyspace) (int, error) {
+	d := &decoder{r: bufio.NewReader(r)}
+	return d.load(cache)
+}
+
+// load decodes an RDB file into the keyspace, stopping right after its checksum so that the data following it,
+// such as the commands of an append-only file, can be read from d.r.
+func (d *decoder) load(cache keyspace.Keyspace) (int, error) {
+	header, err := d.read(9)
+	if err != nil {
+		return 0, err
+	}
+	if string(header[:5]) != "REDIS" {
+		return 0, d.fail("not an RDB file")
+	}
+	version, err := strconv.Atoi(string(header[5:]))
+	if err != nil || version < 1 || version > maxRDBVersion {
+		return 0, d.fail("unsupported RDB version %q", header[5:])
+	}
+
+	now := time.Now().UnixMilli()
+	db, loaded := uint64(0), 0
+	expire := int64(-1)
+	for {
+		op, err := d.readByte()
+		if err != nil {
+			return loaded, err
+		}
+
+		switch op {
+		case opAux:
+			// Metadata such as the version of Redis that wrote the file, which the server has no use for
+			if _, err := d.readString(); err != nil {
+				return loaded, err
+			}
+			if _, err := d.readString(); err != nil {
+				return loaded, err
+			}
+		case opResizeDB:
+			if _, err := d.readLen(); err != nil {
+				return loaded, err
+			}
+			if _, err := d.readLen(); err != nil {
+				return loaded, err
+			}
+		case opSlotInfo:
+			for i := 0; i < 3; i++ {
+				if _, err := d.readLen(); err != nil {
+					return loaded, err
+				}
+			}
+		case opSelectDB:
+			if db, err = d.readLen(); err != nil {
+				return loaded, err
+			}
+		case opExpireTimeMs:
+			p, err := d.read(8)
+			if err != nil {
+				return loaded, err
+			}
+			expire = int64(binary.LittleEndian.Uint64(p))
+		case opExpireTime:
+			p, err := d.read(4)
+			if err != nil {
+				return loaded, err
+			}
+			expire = int64(binary.LittleEndian.Uint32(p)) * 1000
+		case opIdle:
+			// The LRU and LFU information of the next key is not used by the server
+			if _, err := d.readLen(); err != nil {
+				return loaded, err
+			}
+		case opFreq:
+			if _, err := d.readByte(); err != nil {
+				return loaded, err
+			}
+		case opFunction2:
+			// Function libraries are not supported by the server, and skipped
+			if _, err := d.readString(); err != nil {
+				return loaded, err
+			}
+		case opFunctionPreGA:
+			return loaded, d.fail("functions from a pre-release of Redis 7.0 are not supported")
+		case opModuleAux:
+			return loaded, d.fail("module data is not supported")
+		case opEOF:
+			return loaded, d.verifyChecksum(version)
+		default:
+			key, err := d.readString()
+			if err != nil {
+				return loaded, err
+			}
+			value, err := d.readValue(op)
+			if err != nil {
+				return loaded, err
+			}
+
+			value.ValueExpiration = expire
+			expired := expire != -1 && expire <= now && !cache.Loading()
+			expire = -1
+			if db != 0 || expired {
+				continue
+			}
+			cache.Set(key, value)
+			loaded++
+		}
+	}
+}
+
+// decoder reads the elements of an RDB file, keeping the checksum of what it read and its offset in the file.
+type decoder struct {
+	r      *bufio.Reader
+	offset int64
+	crc    uint64
+}
+
+// fail returns a LoadError at the current offset.
+func (d *decoder) fail(format string, args ...interface{}) error {
+	return &LoadError{Offset: d.offset, Reason: fmt.Sprintf(format, args...)}
+}
+
+func (d *decoder) read(n int) ([]byte, error) {
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(d.r, buf); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, d.fail("unexpected end of file")
+		}
+		return nil, err
+	}
+	d.crc = crc64(d.crc, buf)
+	d.offset += int64(n)
+	return buf, nil
+}
+
+func (d *decoder) readByte() (byte, error) {
+	p, err := d.read(1)
+	if err != nil {
+		return 0, err
+	}
+	return p[0], nil
+}
+
+// readLength reads a length, or the encoding of a special string when encoded is set.
+func (d *decoder) readLength() (n uint64, encoded bool, err error) {
+	b, err := d.readByte()
+	if err != nil {
+		return 0, false, err
+	}
+
+	switch b >> 6 {
+	case len6Bit:
+		return uint64(b & 0x3f), false, nil
+	case len14Bit:
+		low, err := d.readByte()
+		return uint64(b&0x3f)<<8 | uint64(low), false, err
+	case lenEncoded:
+		return uint64(b & 0x3f), true, nil
+	}
+
+	switch b {
+	case len32Bit:
+		p, err := d.read(4)
+		if err != nil {
+			return 0, false, err
+		}
+		return uint64(binary.BigEndian.Uint32(p)), false, nil
+	case len64Bit:
+		p, err := d.read(8)
+		if err != nil {
+			return 0, false, err
+		}
+		return binary.BigEndian.Uint64(p), false, nil
+	}
+	return 0, false, d.fail("invalid length encoding %#x", b)
+}
+
+// readLen reads a length that cannot be a special string encoding.
+func (d *decoder) readLen() (uint64, error) {
+	n, encoded, err := d.readLength()
+	if err == nil && encoded {
+		return 0, d.fail("unexpected string encoding where a length was expected")
+	}
+	return n, err
+}
+
+// readString reads a string in any of its encodings: raw, integer or LZF compressed.
+func (d *decoder) readString() (string, error) {
+	n, encoded, err := d.readLength()
+	if err != nil {
+		return "", err
+	}
+
+	if !encoded {
+		if n > maxStringLength {
+			return "", d.fail("string of %d bytes is too long", n)
+		}
+		p, err := d.read(int(n))
+		return string(p), err
+	}
+
+	switch n {
+	case encInt8:
+		b, err := d.readByte()
+		return strconv.FormatInt(int64(int8(b)), 10), err
+	case encInt16:
+		p, err := d.read(2)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatInt(int64(int16(binary.LittleEndian.Uint16(p))), 10), nil
+	case encInt32:
+		p, err := d.read(4)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatInt(int64(int32(binary.LittleEndian.Uint32(p))), 10), nil
+	case encLZF:
+		compressed, err := d.readLen()
+		if err != nil {
+			return "", err
+		}
+		length, err := d.readLen()
+		if err != nil {
+			return "", err
+		}
+		if compressed > maxStringLength || length > maxStringLength {
+			return "", d.fail("compressed string of %d bytes is too long", length)
+		}
+		p, err := d.read(int(compressed))
+		if err != nil {
+			return "", err
+		}
+		s, err := lzfDecompress(p, int(length))
+		if err != nil {
+			return "", d.fail("%v", err)
+		}
+		return string(s), nil
+	}
+	return "", d.fail("invalid string encoding %d", n)
+}
+
+// readScore reads the score of a sorted set member, stored as a string in the sorted sets of type 3.
+func (d *decoder) readScore() (float64, error) {
+	n, err := d.readByte()
+	if err != nil {
+		return 0, err
+	}
+	switch n {
+	case 253:
+		return math.NaN(), nil
+	case 254:
+		return math.Inf(1), nil
+	case 255:
+		return math.Inf(-1), nil
+	}
+	p, err := d.read(int(n))
+	if err != nil {
+		return 0, err
+	}
+	score, err := strconv.ParseFloat(string(p), 64)
+	if err != nil {
+		return 0, d.fail("invalid score %q", p)
+	}
+	return score, nil
+}
+
+// readCompact reads a string holding a compact encoding and decodes it into its elements.
+func (d *decoder) readCompact(decode func([]byte) ([]string, error), name string) ([]string, error) {
+	s, err := d.readString()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := decode([]byte(s))
+	if err != nil {
+		return nil, d.fail("invalid %s: %v", name, err)
+	}
+	return entries, nil
+}
+
+// readValue reads a value of the given RDB type.
+func (d *decoder) readValue(typ byte) (types.CustomValue, error) {
+	switch typ {
+	case typeString:
+		s, err := d.readString()
+		return types.CustomValue{Value: s}, err
+
+	case typeList:
+		n, err := d.readLen()
+		if err != nil {
+			return types.CustomValue{}, err
+		}
+		elements := make([]string, 0, min(n, 1024))
+		for i := uint64(0); i < n; i++ {
+			element, err := d.readString()
+			if err != nil {
+				return types.CustomValue{}, err
+			}
+			elements = append(elements, element)
+		}
+		return listValue(elements), nil
+
+	case typeListZiplist:
+		elements, err := d.readCompact(ziplistEntries, "ziplist")
+		if err != nil {
+			return types.CustomValue{}, err
+		}
+		return listValue(elements), nil
+
+	case typeListQuicklist, typeListQuicklist2:
+		nodes, err := d.readLen()
+		if err != nil {
+			return types.CustomValue{}, err
+		}
+		var elements []string
+		for i := uint64(0); i < nodes; i++ {
+			// quicklist nodes are ziplists, quicklist2 nodes are a listpack or a single plain element
+			container := uint64(quicklistNodePacked)
+			if typ == typeListQuicklist2 {
+				if container, err = d.readLen(); err != nil {
+					return types.CustomValue{}, err
+				}
+			}
+			switch {
+			case container == quicklistNodePlain:
+				element, err := d.readString()
+				if err != nil {
+					return types.CustomValue{}, err
+				}
+				elements = append(elements, element)
+			case container != quicklistNodePacked:
+				return types.CustomValue{}, d.fail("invalid quicklist node container %d", container)
+			case typ == typeListQuicklist:
+				entries, err := d.readCompact(ziplistEntries, "ziplist")
+				if err != nil {
+					return types.CustomValue{}, err
+				}
+				elements = append(elements, entries...)
+			default:
+				entries, err := d.readCompact(listpackEntries, "listpack")
+				if err != nil {
+					return types.CustomValue{}, err
+				}
+				elements = append(elements, entries...)
+			}
+		}
+		return listValue(elements), nil
+
+	case typeSet:
+		n, err := d.readLen()
+		if err != nil {
+			return types.CustomValue{}, err
+		}
+		set := datatypes.NewSet()
+		for i := uint64(0); i < n; i++ {
+			member, err := d.readString()
+			if err != nil {
+				return types.CustomValue{}, err
+			}
+			set.Add(member)
+		}
+		return types.CustomValue{Type: types.ValueTypeSet, Set: set}, nil
+
+	case typeSetIntset, typeSetListpack:
+		decode, name := intsetEntries, "intset"
+		if typ == typeSetListpack {
+			decode, name = listpackEntries, "listpack"
+		}
+		members, err := d.readCompact(decode, name)
+		if err != nil {
+			return types.CustomValue{}, err
+		}
+		set := datatypes.NewSet()
+		for _, member := range members {
+			set.Add(member)
+		}
+		return types.CustomValue{Type: types.ValueTypeSet, Set: set}, nil
+
+	case typeZSet, typeZSet2:
+		n, err := d.readLen()
+		if err != nil {
+			return types.CustomValue{}, err
+		}
+		zset := datatypes.NewSortedSet()
+		for i := uint64(0); i < n; i++ {
+			member, err := d.readString()
+			if err != nil {
+				return types.CustomValue{}, err
+			}
+			var score float64
+			if typ == typeZSet2 {
+				p, err := d.read(8)
+				if err != nil {
+					return types.CustomValue{}, err
+				}
+				score = math.Float64frombits(binary.LittleEndian.Uint64(p))
+			} else if score, err = d.readScore(); err != nil {
+				return types.CustomValue{}, err
+			}
+			if math.IsNaN(score) {
+				return types.CustomValue{}, d.fail("score of member %q is not a number", member)
+			}
+			zset.Add(member, score)
+		}
+		return types.CustomValue{Type: types.ValueTypeZSet, ZSet: zset}, nil
+
+	case typeZSetZiplist, typeZSetListpack:
+		decode, name := ziplistEntries, "ziplist"
+		if typ == typeZSetListpack {
+			decode, name = listpackEntries, "listpack"
+		}
+		entries, err := d.readCompact(decode, name)
+		if err != nil {
+			return types.CustomValue{}, err
+		}
+		if len(entries)%2 != 0 {
+			return types.CustomValue{}, d.fail("sorted set %s has a member without a score", name)
+		}
+		zset := datatypes.NewSortedSet()
+		for i := 0; i < len(entries); i += 2 {
+			score, err := strconv.ParseFloat(entries[i+1], 64)
+			if err != nil || math.IsNaN(score) {
+				return types.CustomValue{}, d.fail("invalid score %q in sorted set %s", entries[i+1], name)
+			}
+			zset.Add(entries[i], score)
+		}
+		return types.CustomValue{Type: types.ValueTypeZSet, ZSet: zset}, nil
+
+	case typeHash:
+		n, err := d.readLen()
+		if err != nil {
+			return types.CustomValue{}, err
+		}
+		hash := datatypes.NewHash()
+		for i := uint64(0); i < n; i++ {
+			field, err := d.readString()
+			if err != nil {
+				return types.CustomValue{}, err
+			}
+			value, err := d.readString()
+			if err != nil {
+				return types.CustomValue{}, err
+			}
+			hash.Set(field, value)
+		}
+		return types.CustomValue{Type: types.ValueTypeHash, Hash: hash}, nil
+
+	case typeHashZipmap, typeHashZiplist, typeHashListpack:
+		decode, name := zipmapEntries, "zipmap"
+		switch typ {
+		case typeHashZiplist:
+			decode, name = ziplistEntries, "ziplist"
+		case typeHashListpack:
+			decode, name = listpackEntries, "listpack"
+		}
+		entries, err := d.readCompact(decode, name)
+		if err != nil {
+			return types.CustomValue{}, err
+		}
+		if len(entries)%2 != 0 {
+			return types.CustomValue{}, d.fail("hash %s has a field without a value", name)
+		}
+		hash := datatypes.NewHash()
+		for i := 0; i < len(entries); i += 2 {
+			hash.Set(entries[i], entries[i+1])
+		}
+		return types.CustomValue{Type: types.ValueTypeHash, Hash: hash}, nil
+
+	case typeModulePreGA, typeModule2:
+		return types.CustomValue{}, d.fail("module values are not supported")
+	case typeStreamListpacks, typeStreamListpacks2, typeStreamListpacks3:
+		return types.CustomValue{}, d.fail("stream values are not supported")
+	}
+	return types.CustomValue{}, d.fail("unknown value type %d", typ)
+}
+
+// listValue returns a value holding a list of the elements.
+func listValue(elements []string) types.CustomValue {
+	list := datatypes.NewList()
+	for _, element := range elements {
+		list.PushBack(element)
+	}
+	return types.CustomValue{Type: types.ValueTypeList, List: list}
+}
+
+// verifyChecksum reads the checksum that ends the files since version 5 and compares it with the checksum
+// of what was read. A checksum of 0 means the file was written with checksums disabled.
+func (d *decoder) verifyChecksum(version int) error {
+	if version < 5 {
+		return nil
+	}
+	expected := d.crc
+	p, err := d.read(8)
+	if err != nil {
+		return err
+	}
+	if checksum := binary.LittleEndian.Uint64(p); checksum != 0 && checksum != expected {
+		return d.fail("wrong checksum %#016x, expected %#016x", checksum, expected)
+	}
+	return nil
+}
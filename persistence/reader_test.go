@@ -0,0 +1,265 @@
+package persistence_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/datatypes"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/keyspace"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/persistence"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/types"
+)
+
+// rdbFile frames a database body as an RDB file of the given version, ending it with the EOF opcode and its
+// checksum, as Redis would write it.
+func rdbFile(version string, body ...byte) []byte {
+	rdb := append([]byte("REDIS"+version), body...)
+	rdb = append(rdb, 0xff)
+	return binary.LittleEndian.AppendUint64(rdb, jonesChecksum(rdb))
+}
+
+// decode reads an RDB file into a new keyspace.
+func decode(t *testing.T, rdb []byte) keyspace.Keyspace {
+	t.Helper()
+	cache := keyspace.New()
+	if _, err := persistence.Read(bytes.NewReader(rdb), cache); err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	return cache
+}
+
+func TestReadRoundTrip(t *testing.T) {
+	cache := keyspace.New()
+	cache.Set("string", types.CustomValue{Value: "hello", ValueExpiration: -1})
+	cache.Set("integer", types.CustomValue{Value: "-100000", ValueExpiration: 4102444800000})
+	cache.Set("long", types.CustomValue{Value: strings.Repeat("x", 20000), ValueExpiration: -1})
+
+	list := datatypes.NewList()
+	for _, element := range []string{"a", "1", "b"} {
+		list.PushBack(element)
+	}
+	cache.Set("list", types.CustomValue{Type: types.ValueTypeList, List: list, ValueExpiration: -1})
+
+	hash := datatypes.NewHash()
+	hash.Set("f", "v")
+	hash.Set("n", "42")
+	cache.Set("hash", types.CustomValue{Type: types.ValueTypeHash, Hash: hash, ValueExpiration: -1})
+
+	zset := datatypes.NewSortedSet()
+	zset.Add("m", 1.5)
+	zset.Add("zero", 0)
+	cache.Set("zset", types.CustomValue{Type: types.ValueTypeZSet, ZSet: zset, ValueExpiration: -1})
+
+	intset := datatypes.NewSet()
+	intset.Add("3")
+	intset.Add("-2147483648")
+	cache.Set("intset", types.CustomValue{Type: types.ValueTypeSet, Set: intset, ValueExpiration: -1})
+
+	set := datatypes.NewSet()
+	set.Add("a")
+	set.Add("b")
+	cache.Set("set", types.CustomValue{Type: types.ValueTypeSet, Set: set, ValueExpiration: -1})
+
+	loaded := keyspace.New()
+	n, err := persistence.Read(bytes.NewReader(encode(t, cache)), loaded)
+	if err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if n != cache.Len() || loaded.Len() != cache.Len() {
+		t.Fatalf("Read() loaded %d keys, the keyspace has %d, want %d", n, loaded.Len(), cache.Len())
+	}
+
+	cache.ForEach(func(key string, want types.CustomValue) bool {
+		got, ok := loaded.Get(key)
+		if !ok {
+			t.Errorf("key %q was not loaded", key)
+			return true
+		}
+		if got.Type != want.Type || got.Value != want.Value || got.ValueExpiration != want.ValueExpiration {
+			t.Errorf("key %q = %v %q expiring at %d, want %v %q expiring at %d",
+				key, got.Type, got.Value, got.ValueExpiration, want.Type, want.Value, want.ValueExpiration)
+		}
+		return true
+	})
+
+	value, _ := loaded.Get("list")
+	if got := value.List.Range(0, -1); !reflect.DeepEqual(got, []string{"a", "1", "b"}) {
+		t.Errorf("list = %q, want [a 1 b]", got)
+	}
+	value, _ = loaded.Get("hash")
+	if v, _ := value.Hash.Get("n"); value.Hash.Len() != 2 || v != "42" {
+		t.Errorf("hash has %d fields and n = %q, want 2 fields and n = 42", value.Hash.Len(), v)
+	}
+	value, _ = loaded.Get("zset")
+	if score, _ := value.ZSet.Score("m"); value.ZSet.Len() != 2 || score != 1.5 {
+		t.Errorf("sorted set has %d members and m = %v, want 2 members and m = 1.5", value.ZSet.Len(), score)
+	}
+	value, _ = loaded.Get("intset")
+	if !value.Set.Intset() || !value.Set.Contains("-2147483648") || value.Set.Len() != 2 {
+		t.Errorf("intset was not loaded as an intset holding -2147483648 and 3")
+	}
+	value, _ = loaded.Get("set")
+	if !value.Set.Contains("a") || !value.Set.Contains("b") || value.Set.Len() != 2 {
+		t.Errorf("set was not loaded with a and b")
+	}
+}
+
+func TestReadRedisEncodings(t *testing.T) {
+	// Compact encodings written by Redis but never by this server
+	t.Run("hash listpack", func(t *testing.T) {
+		listpack := []byte{
+			0x13, 0x00, 0x00, 0x00, 0x04, 0x00,
+			0x81, 'f', 0x02, 0x81, 'v', 0x02, 0x81, 'n', 0x02, 0xc3, 0xe8, 0x02,
+			0xff,
+		}
+		cache := decode(t, rdbFile("0011", append([]byte{0x10, 0x01, 'h', byte(len(listpack))}, listpack...)...))
+
+		value, ok := cache.Get("h")
+		if !ok || value.Type != types.ValueTypeHash {
+			t.Fatalf("h was not loaded as a hash")
+		}
+		if v, _ := value.Hash.Get("n"); value.Hash.Len() != 2 || v != "1000" {
+			t.Errorf("hash has %d fields and n = %q, want 2 fields and n = 1000", value.Hash.Len(), v)
+		}
+	})
+
+	t.Run("LZF string", func(t *testing.T) {
+		cache := decode(t, rdbFile("0009", 0x00, 0x01, 's', 0xc3, 0x05, 0x1e, 0x00, 'a', 0xe0, 0x14, 0x00))
+
+		if value, _ := cache.Get("s"); value.Value != strings.Repeat("a", 30) {
+			t.Errorf("s = %q, want 30 a", value.Value)
+		}
+	})
+
+	t.Run("ziplist quicklist", func(t *testing.T) {
+		ziplist := []byte{
+			0x14, 0x00, 0x00, 0x00, 0x0f, 0x00, 0x00, 0x00, 0x03, 0x00,
+			0x00, 0x01, 'a', 0x03, 0xf6, 0x02, 0xc0, 0x2c, 0x01,
+			0xff,
+		}
+		cache := decode(t, rdbFile("0009", append([]byte{0x0e, 0x01, 'l', 0x01, byte(len(ziplist))}, ziplist...)...))
+
+		value, _ := cache.Get("l")
+		if value.Type != types.ValueTypeList {
+			t.Fatalf("l was not loaded as a list")
+		}
+		if got := value.List.Range(0, -1); !reflect.DeepEqual(got, []string{"a", "5", "300"}) {
+			t.Errorf("list = %q, want [a 5 300]", got)
+		}
+	})
+
+	t.Run("listpack quicklist with a plain node", func(t *testing.T) {
+		listpack := []byte{0x0d, 0x00, 0x00, 0x00, 0x02, 0x00, 0x81, 'x', 0x02, 0x81, 'y', 0x02, 0xff}
+		body := append([]byte{0x12, 0x01, 'l', 0x02, 0x02, byte(len(listpack))}, listpack...)
+		body = append(body, 0x01, 0x03, 'b', 'i', 'g')
+		cache := decode(t, rdbFile("0011", body...))
+
+		value, _ := cache.Get("l")
+		if got := value.List.Range(0, -1); !reflect.DeepEqual(got, []string{"x", "y", "big"}) {
+			t.Errorf("list = %q, want [x y big]", got)
+		}
+	})
+
+	t.Run("sorted set listpack", func(t *testing.T) {
+		listpack := []byte{0x0f, 0x00, 0x00, 0x00, 0x02, 0x00, 0x81, 'm', 0x02, 0x83, '1', '.', '5', 0x04, 0xff}
+		cache := decode(t, rdbFile("0011", append([]byte{0x11, 0x01, 'z', byte(len(listpack))}, listpack...)...))
+
+		value, _ := cache.Get("z")
+		if score, ok := value.ZSet.Score("m"); !ok || score != 1.5 {
+			t.Errorf("score of m = %v, want 1.5", score)
+		}
+	})
+}
+
+func TestReadExpiration(t *testing.T) {
+	future := uint32(time.Now().Add(time.Hour).Unix())
+	past := uint64(time.Now().Add(-time.Hour).UnixMilli())
+
+	body := []byte{0xfe, 0x00, 0xfd}
+	body = binary.LittleEndian.AppendUint32(body, future)
+	body = append(body, 0x00, 0x01, 'a', 0x01, 'v', 0xfc)
+	body = binary.LittleEndian.AppendUint64(body, past)
+	body = append(body, 0x00, 0x01, 'b', 0x01, 'v')
+	// Keys of other databases are skipped, since the server only has database 0
+	body = append(body, 0xfe, 0x01, 0x00, 0x01, 'c', 0x01, 'v')
+
+	cache := keyspace.New()
+	n, err := persistence.Read(bytes.NewReader(rdbFile("0009", body...)), cache)
+	if err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("Read() loaded %d keys, want 1", n)
+	}
+	if at, ok := cache.ExpireTime("a"); !ok || at != int64(future)*1000 {
+		t.Errorf("a expires at %d, want %d", at, int64(future)*1000)
+	}
+	if _, ok := cache.Get("b"); ok {
+		t.Errorf("the expired key b was loaded")
+	}
+	if _, ok := cache.Get("c"); ok {
+		t.Errorf("the key c of database 1 was loaded")
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	valid := rdbFile("0009", 0x00, 0x01, 'k', 0x01, 'v')
+	corrupt := bytes.Clone(valid)
+	corrupt[len(corrupt)-1] ^= 0xff
+
+	tests := []struct {
+		name   string
+		rdb    []byte
+		reason string
+	}{
+		{name: "not an RDB file", rdb: []byte("*1\r\n$4\r\nPING\r\n"), reason: "not an RDB file"},
+		{name: "future version", rdb: rdbFile("0099"), reason: "unsupported RDB version"},
+		{name: "wrong checksum", rdb: corrupt, reason: "wrong checksum"},
+		{name: "truncated", rdb: valid[:len(valid)-12], reason: "unexpected end of file"},
+		{name: "stream", rdb: rdbFile("0011", 0x15, 0x01, 's'), reason: "stream values are not supported"},
+		{name: "unknown type", rdb: rdbFile("0011", 0x30, 0x01, 'k'), reason: "unknown value type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := persistence.Read(bytes.NewReader(tt.rdb), keyspace.New())
+			var loadErr *persistence.LoadError
+			if !errors.As(err, &loadErr) || !strings.Contains(loadErr.Reason, tt.reason) {
+				t.Errorf("Read() error = %v, want a LoadError about %q", err, tt.reason)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "dump.rdb")
+
+	if n, err := persistence.Load(path, keyspace.New()); n != 0 || err != nil {
+		t.Fatalf("Load() of a missing file = %d, %v, want 0, nil", n, err)
+	}
+
+	cache := keyspace.New()
+	cache.Set("k", types.CustomValue{Value: "v", ValueExpiration: -1})
+	if err := persistence.Save(path, cache); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("Save() did not write the file: %v", err)
+	}
+
+	loaded := keyspace.New()
+	if n, err := persistence.Load(path, loaded); n != 1 || err != nil {
+		t.Fatalf("Load() = %d, %v, want 1, nil", n, err)
+	}
+	if value, _ := loaded.Get("k"); value.Value != "v" {
+		t.Errorf("k = %q, want v", value.Value)
+	}
+}
@@ -0,0 +1,97 @@
+package persistence
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/keyspace"
+)
+
+// bgsaveRetryDelay is how long automatic saves wait after a failed background save before trying again.
+const bgsaveRetryDelay = 5 * time.Second
+
+// SaveRule triggers a background save once there were at least Changes writes and Seconds passed since the
+// last successful save, like a save point "save <seconds> <changes>" of redis.conf.
+type SaveRule struct {
+	Seconds int64
+	Changes int64
+}
+
+// DefaultSaveRules are the save points of Redis when redis.conf has none.
+var DefaultSaveRules = []SaveRule{{Seconds: 3600, Changes: 1}, {Seconds: 300, Changes: 100}, {Seconds: 60, Changes: 10000}}
+
+var saveRules = DefaultSaveRules
+
+// ParseSaveRules parses save points in the format of the save parameter of CONFIG SET, pairs of seconds and
+// changes such as "900 1 300 10". An empty string disables automatic saves.
+func ParseSaveRules(s string) ([]SaveRule, error) {
+	fields := strings.Fields(s)
+	if len(fields)%2 != 0 {
+		return nil, fmt.Errorf("invalid save parameters %q", s)
+	}
+
+	rules := make([]SaveRule, 0, len(fields)/2)
+	for i := 0; i < len(fields); i += 2 {
+		seconds, err := strconv.ParseInt(fields[i], 10, 64)
+		if err != nil || seconds < 1 {
+			return nil, fmt.Errorf("invalid save parameters %q", s)
+		}
+		changes, err := strconv.ParseInt(fields[i+1], 10, 64)
+		if err != nil || changes < 0 {
+			return nil, fmt.Errorf("invalid save parameters %q", s)
+		}
+		rules = append(rules, SaveRule{Seconds: seconds, Changes: changes})
+	}
+	return rules, nil
+}
+
+// FormatSaveRules formats save points the way ParseSaveRules parses them.
+func FormatSaveRules(rules []SaveRule) string {
+	parts := make([]string, 0, 2*len(rules))
+	for _, rule := range rules {
+		parts = append(parts, strconv.FormatInt(rule.Seconds, 10), strconv.FormatInt(rule.Changes, 10))
+	}
+	return strings.Join(parts, " ")
+}
+
+// SaveRules returns the save points in effect.
+func SaveRules() []SaveRule {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	return saveRules
+}
+
+// SetSaveRules replaces the save points. No rule disables automatic saves.
+func SetSaveRules(rules []SaveRule) {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	saveRules = rules
+}
+
+// AutoSave starts a background save of the keyspace to the RDB file at path when one of the save points is
+// reached, and reports whether it did. The servers call it periodically. After a failed background save,
+// it waits for bgsaveRetryDelay before trying again, so that a full disk does not make it retry endlessly.
+func AutoSave(path string, cache keyspace.Keyspace) bool {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+
+	if status.BgsaveInProgress {
+		return false
+	}
+	now := time.Now()
+	if status.LastBgsaveErr != nil && now.Sub(status.lastBgsaveTry) <= bgsaveRetryDelay {
+		return false
+	}
+
+	for _, rule := range saveRules {
+		if status.Changes < rule.Changes || now.Sub(status.LastSave) < time.Duration(rule.Seconds)*time.Second {
+			continue
+		}
+		log.Printf("%d changes in %d seconds. Saving...", rule.Changes, rule.Seconds)
+		return backgroundSave(path, cache) == nil
+	}
+	return false
+}
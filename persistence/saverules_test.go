@@ -0,0 +1,79 @@
+package persistence_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/keyspace"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/persistence"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/types"
+)
+
+func TestParseSaveRules(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []persistence.SaveRule
+		wantErr bool
+	}{
+		{input: "900 1 300 10", want: []persistence.SaveRule{{Seconds: 900, Changes: 1}, {Seconds: 300, Changes: 10}}},
+		{input: "", want: []persistence.SaveRule{}},
+		{input: "900", wantErr: true},
+		{input: "0 1", wantErr: true},
+		{input: "900 -1", wantErr: true},
+		{input: "900 x", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := persistence.ParseSaveRules(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSaveRules(%q) error = %v, want error %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseSaveRules(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+		if !tt.wantErr && persistence.FormatSaveRules(got) != tt.input {
+			t.Errorf("FormatSaveRules(%v) = %q, want %q", got, persistence.FormatSaveRules(got), tt.input)
+		}
+	}
+}
+
+func TestAutoSave(t *testing.T) {
+	defer persistence.SetSaveRules(persistence.DefaultSaveRules)
+	persistence.SetSaveRules([]persistence.SaveRule{{Seconds: 1, Changes: 2}})
+
+	path := filepath.Join(t.TempDir(), "dump.rdb")
+	cache := keyspace.New()
+	cache.Set("k", types.CustomValue{Value: "v", ValueExpiration: -1})
+
+	// Let the save point's time pass since the last save, which may have been made by another test
+	time.Sleep(1100 * time.Millisecond)
+	persistence.AddChanges(1)
+	if persistence.AutoSave(path, cache) {
+		t.Fatalf("AutoSave() saved after a single change")
+	}
+	persistence.AddChanges(1)
+	if !persistence.AutoSave(path, cache) {
+		t.Fatalf("AutoSave() did not save after 2 changes")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for persistence.Status().BgsaveInProgress {
+		if time.Now().After(deadline) {
+			t.Fatalf("the background save did not complete")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("AutoSave() did not write the file: %v", err)
+	}
+	if status := persistence.Status(); status.Changes != 0 || status.LastBgsaveErr != nil {
+		t.Errorf("after the save: %d changes and error %v, want 0 changes and no error", status.Changes, status.LastBgsaveErr)
+	}
+	if persistence.AutoSave(path, cache) {
+		t.Errorf("AutoSave() saved again without changes")
+	}
+}
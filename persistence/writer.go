@@ -0,0 +1,298 @@
+package persistence
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/keyspace"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/types"
+)
+
+// Save writes the keyspace to the RDB file at path, from a snapshot so that the file holds the keyspace
+// as it was at a single point in time even when commands modify it meanwhile.
+// It fails with ErrSaveInProgress while a background save is running, like SAVE in Redis.
+func Save(path string, cache keyspace.Keyspace) error {
+	statusMu.Lock()
+	if status.BgsaveInProgress {
+		statusMu.Unlock()
+		return ErrSaveInProgress
+	}
+	changes := status.Changes
+	snapshot := cache.Snapshot()
+	statusMu.Unlock()
+
+	defer snapshot.Release()
+	if err := writeFile(path, snapshot); err != nil {
+		return err
+	}
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	saved(time.Now(), changes)
+	return nil
+}
+
+// writeFile writes the source to the RDB file at path. The source is written to a temporary file in the same
+// directory, which then replaces path, so that a failed save never leaves a truncated file behind.
+func writeFile(path string, src Source) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, "temp-*.rdb")
+	if err != nil {
+		return err
+	}
+	// Removing the temporary file fails harmlessly once it has been renamed
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	if err := Write(w, src); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	// The file must be on disk before it replaces the previous one
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Source is what an RDB file is written from: a keyspace or a snapshot of it.
+type Source interface {
+	ForEach(fn func(key string, value types.CustomValue) bool)
+}
+
+// Write encodes the keys of the source in the RDB format to w.
+func Write(w io.Writer, src Source) error {
+	e := &encoder{w: w}
+
+	e.write([]byte(fmt.Sprintf("REDIS%04d", rdbVersion)))
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	e.writeAux("redis-ver", redisVersion)
+	e.writeAux("redis-bits", strconv.Itoa(strconv.IntSize))
+	e.writeAux("ctime", strconv.FormatInt(time.Now().Unix(), 10))
+	e.writeAux("used-mem", strconv.FormatUint(mem.Alloc, 10))
+	e.writeAux("aof-base", "0")
+
+	// The sizes announced by RESIZEDB only let the loader presize its tables, so they may be slightly off
+	// when the source is a keyspace whose keys change while it is written
+	keys, expires := 0, 0
+	src.ForEach(func(key string, value types.CustomValue) bool {
+		keys++
+		if value.HasExpiration() {
+			expires++
+		}
+		return true
+	})
+	e.writeByte(opSelectDB)
+	e.writeLength(0)
+	e.writeByte(opResizeDB)
+	e.writeLength(uint64(keys))
+	e.writeLength(uint64(expires))
+
+	src.ForEach(func(key string, value types.CustomValue) bool {
+		e.writeEntry(key, value)
+		return e.err == nil
+	})
+
+	e.writeByte(opEOF)
+	// The checksum covers everything before it
+	checksum := make([]byte, 8)
+	binary.LittleEndian.PutUint64(checksum, e.crc)
+	e.write(checksum)
+	return e.err
+}
+
+// encoder writes the elements of an RDB file and keeps the checksum of what it wrote.
+// The first error is kept in err, and makes every later write a no-op.
+type encoder struct {
+	w   io.Writer
+	crc uint64
+	err error
+}
+
+func (e *encoder) write(p []byte) {
+	if e.err != nil {
+		return
+	}
+	e.crc = crc64(e.crc, p)
+	_, e.err = e.w.Write(p)
+}
+
+func (e *encoder) writeByte(b byte) {
+	e.write([]byte{b})
+}
+
+// writeLength writes a length in the smallest of its encodings.
+func (e *encoder) writeLength(n uint64) {
+	switch {
+	case n < 1<<6:
+		e.writeByte(len6Bit<<6 | byte(n))
+	case n < 1<<14:
+		e.write([]byte{len14Bit<<6 | byte(n>>8), byte(n)})
+	case n <= math.MaxUint32:
+		buf := make([]byte, 5)
+		buf[0] = len32Bit
+		binary.BigEndian.PutUint32(buf[1:], uint32(n))
+		e.write(buf)
+	default:
+		buf := make([]byte, 9)
+		buf[0] = len64Bit
+		binary.BigEndian.PutUint64(buf[1:], n)
+		e.write(buf)
+	}
+}
+
+// writeString writes a string, as an integer when it is the representation of one that fits in 32 bits,
+// like Redis does.
+func (e *encoder) writeString(s string) {
+	if len(s) <= 11 {
+		if n, err := strconv.ParseInt(s, 10, 32); err == nil && strconv.FormatInt(n, 10) == s {
+			e.writeInteger(n)
+			return
+		}
+	}
+	e.writeLength(uint64(len(s)))
+	e.write([]byte(s))
+}
+
+// writeInteger writes an integer that fits in 32 bits as an integer encoded string.
+func (e *encoder) writeInteger(n int64) {
+	switch {
+	case n >= math.MinInt8 && n <= math.MaxInt8:
+		e.write([]byte{lenEncoded<<6 | encInt8, byte(n)})
+	case n >= math.MinInt16 && n <= math.MaxInt16:
+		buf := []byte{lenEncoded<<6 | encInt16, 0, 0}
+		binary.LittleEndian.PutUint16(buf[1:], uint16(n))
+		e.write(buf)
+	default:
+		buf := []byte{lenEncoded<<6 | encInt32, 0, 0, 0, 0}
+		binary.LittleEndian.PutUint32(buf[1:], uint32(n))
+		e.write(buf)
+	}
+}
+
+// writeAux writes an aux field, the metadata found at the start of an RDB file.
+func (e *encoder) writeAux(key, value string) {
+	e.writeByte(opAux)
+	e.writeString(key)
+	e.writeString(value)
+}
+
+// writeEntry writes a key, preceded by its expiration time if it has one, and its value.
+func (e *encoder) writeEntry(key string, value types.CustomValue) {
+	if value.HasExpiration() {
+		buf := make([]byte, 9)
+		buf[0] = opExpireTimeMs
+		binary.LittleEndian.PutUint64(buf[1:], uint64(value.ValueExpiration))
+		e.write(buf)
+	}
+
+	switch value.Type {
+	case types.ValueTypeString:
+		e.writeByte(typeString)
+		e.writeString(key)
+		e.writeString(value.Value)
+
+	case types.ValueTypeList:
+		e.writeByte(typeList)
+		e.writeString(key)
+		e.writeLength(uint64(value.List.Len()))
+		value.List.Iterate(false, func(_ int, element string) bool {
+			e.writeString(element)
+			return true
+		})
+
+	case types.ValueTypeSet:
+		if value.Set.Intset() {
+			e.writeByte(typeSetIntset)
+			e.writeString(key)
+			e.writeIntset(value.Set.Members())
+			return
+		}
+		e.writeByte(typeSet)
+		e.writeString(key)
+		e.writeLength(uint64(value.Set.Len()))
+		value.Set.Iterate(func(member string) bool {
+			e.writeString(member)
+			return true
+		})
+
+	case types.ValueTypeZSet:
+		e.writeByte(typeZSet2)
+		e.writeString(key)
+		e.writeLength(uint64(value.ZSet.Len()))
+		score := make([]byte, 8)
+		value.ZSet.Iterate(func(member string, s float64) bool {
+			e.writeString(member)
+			binary.LittleEndian.PutUint64(score, math.Float64bits(s))
+			e.write(score)
+			return true
+		})
+
+	case types.ValueTypeHash:
+		e.writeByte(typeHash)
+		e.writeString(key)
+		e.writeLength(uint64(value.Hash.Len()))
+		value.Hash.Iterate(func(field, v string) bool {
+			e.writeString(field)
+			e.writeString(v)
+			return true
+		})
+
+	default:
+		e.err = fmt.Errorf("cannot save key %q of unknown type %d", key, value.Type)
+	}
+}
+
+// writeIntset writes the members of a set in the intset encoding: the size of the integers, the number of
+// integers, then the integers in ascending order, all little endian. members must be sorted integers.
+func (e *encoder) writeIntset(members []string) {
+	ints := make([]int64, len(members))
+	size := 2
+	for i, member := range members {
+		ints[i], _ = strconv.ParseInt(member, 10, 64)
+		switch {
+		case ints[i] < math.MinInt32 || ints[i] > math.MaxInt32:
+			size = 8
+		case (ints[i] < math.MinInt16 || ints[i] > math.MaxInt16) && size < 4:
+			size = 4
+		}
+	}
+
+	buf := make([]byte, 8+size*len(ints))
+	binary.LittleEndian.PutUint32(buf, uint32(size))
+	binary.LittleEndian.PutUint32(buf[4:], uint32(len(ints)))
+	for i, n := range ints {
+		p := buf[8+size*i:]
+		switch size {
+		case 2:
+			binary.LittleEndian.PutUint16(p, uint16(n))
+		case 4:
+			binary.LittleEndian.PutUint32(p, uint32(n))
+		default:
+			binary.LittleEndian.PutUint64(p, uint64(n))
+		}
+	}
+	e.writeLength(uint64(len(buf)))
+	e.write(buf)
+}
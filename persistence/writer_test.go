@@ -0,0 +1,163 @@
+package persistence_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc64"
+	"testing"
+
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/datatypes"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/keyspace"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/persistence"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/types"
+)
+
+// jonesChecksum computes the CRC-64/Jones checksum of Redis with the standard library, undoing the inversions
+// hash/crc64 applies before and after the update, to check the checksum written by persistence independently.
+func jonesChecksum(p []byte) uint64 {
+	return ^crc64.Update(^uint64(0), crc64.MakeTable(0x95ac9329ac4bc9b5), p)
+}
+
+func TestJonesChecksum(t *testing.T) {
+	if got := jonesChecksum([]byte("123456789")); got != 0xe9c6d914c4b8d9ca {
+		t.Fatalf("checksum of 123456789 = %#x, want 0xe9c6d914c4b8d9ca", got)
+	}
+}
+
+// encode returns the RDB encoding of the keyspace.
+func encode(t *testing.T, cache keyspace.Keyspace) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := persistence.Write(&buf, cache); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// checkFrame checks the header, the EOF opcode and the checksum of an RDB file.
+func checkFrame(t *testing.T, rdb []byte) {
+	t.Helper()
+	if !bytes.HasPrefix(rdb, []byte("REDIS0009")) {
+		t.Fatalf("the file starts with %q, want REDIS0009", rdb[:9])
+	}
+	body, checksum := rdb[:len(rdb)-8], binary.LittleEndian.Uint64(rdb[len(rdb)-8:])
+	if body[len(body)-1] != 0xff {
+		t.Errorf("the file does not end with the EOF opcode")
+	}
+	if want := jonesChecksum(body); checksum != want {
+		t.Errorf("checksum = %#x, want %#x", checksum, want)
+	}
+}
+
+func TestWriteString(t *testing.T) {
+	cache := keyspace.New()
+	cache.Set("k", types.CustomValue{Value: "12", ValueExpiration: -1})
+	rdb := encode(t, cache)
+	checkFrame(t, rdb)
+
+	for _, aux := range []string{"redis-ver", "redis-bits", "ctime", "used-mem"} {
+		if !bytes.Contains(rdb, append([]byte{0xfa, byte(len(aux))}, aux...)) {
+			t.Errorf("the file has no %s aux field", aux)
+		}
+	}
+
+	// SELECTDB 0, RESIZEDB of 1 key without expiration, then the string k holding 12 encoded as an 8-bit integer
+	want := []byte{0xfe, 0x00, 0xfb, 0x01, 0x00, 0x00, 0x01, 'k', 0xc0, 12, 0xff}
+	if got := rdb[len(rdb)-8-len(want) : len(rdb)-8]; !bytes.Equal(got, want) {
+		t.Errorf("the database is encoded as % x, want % x", got, want)
+	}
+}
+
+func TestWriteEncodings(t *testing.T) {
+	tests := []struct {
+		name  string
+		value types.CustomValue
+		want  []byte
+	}{
+		{
+			name:  "16-bit integer",
+			value: types.CustomValue{Value: "-1000", ValueExpiration: -1},
+			want:  []byte{0x00, 0x01, 'k', 0xc1, 0x18, 0xfc},
+		},
+		{
+			name:  "32-bit integer",
+			value: types.CustomValue{Value: "100000", ValueExpiration: -1},
+			want:  []byte{0x00, 0x01, 'k', 0xc2, 0xa0, 0x86, 0x01, 0x00},
+		},
+		{
+			name:  "integer not in its canonical form",
+			value: types.CustomValue{Value: "007", ValueExpiration: -1},
+			want:  []byte{0x00, 0x01, 'k', 0x03, '0', '0', '7'},
+		},
+		{
+			name:  "14-bit length",
+			value: types.CustomValue{Value: string(bytes.Repeat([]byte{'a'}, 100)), ValueExpiration: -1},
+			want:  append([]byte{0x00, 0x01, 'k', 0x40, 100}, bytes.Repeat([]byte{'a'}, 100)...),
+		},
+		{
+			name:  "expiration time",
+			value: types.CustomValue{Value: "v", ValueExpiration: 4102444800000},
+			want:  []byte{0xfc, 0x00, 0xd8, 0xc3, 0x2c, 0xbb, 0x03, 0x00, 0x00, 0x00, 0x01, 'k', 0x01, 'v'},
+		},
+		{
+			name: "list",
+			value: func() types.CustomValue {
+				list := datatypes.NewList()
+				list.PushBack("a")
+				list.PushBack("b")
+				return types.CustomValue{Type: types.ValueTypeList, List: list, ValueExpiration: -1}
+			}(),
+			want: []byte{0x01, 0x01, 'k', 0x02, 0x01, 'a', 0x01, 'b'},
+		},
+		{
+			name: "intset",
+			value: func() types.CustomValue {
+				set := datatypes.NewSet()
+				set.Add("2")
+				set.Add("-1")
+				return types.CustomValue{Type: types.ValueTypeSet, Set: set, ValueExpiration: -1}
+			}(),
+			want: []byte{0x0b, 0x01, 'k', 0x0c, 0x02, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0xff, 0xff, 0x02, 0x00},
+		},
+		{
+			name: "set",
+			value: func() types.CustomValue {
+				set := datatypes.NewSet()
+				set.Add("a")
+				return types.CustomValue{Type: types.ValueTypeSet, Set: set, ValueExpiration: -1}
+			}(),
+			want: []byte{0x02, 0x01, 'k', 0x01, 0x01, 'a'},
+		},
+		{
+			name: "hash",
+			value: func() types.CustomValue {
+				hash := datatypes.NewHash()
+				hash.Set("f", "v")
+				return types.CustomValue{Type: types.ValueTypeHash, Hash: hash, ValueExpiration: -1}
+			}(),
+			want: []byte{0x04, 0x01, 'k', 0x01, 0x01, 'f', 0x01, 'v'},
+		},
+		{
+			name: "sorted set",
+			value: func() types.CustomValue {
+				zset := datatypes.NewSortedSet()
+				zset.Add("m", 1.5)
+				return types.CustomValue{Type: types.ValueTypeZSet, ZSet: zset, ValueExpiration: -1}
+			}(),
+			want: []byte{0x05, 0x01, 'k', 0x01, 0x01, 'm', 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf8, 0x3f},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := keyspace.New()
+			cache.Set("k", tt.value)
+			rdb := encode(t, cache)
+			checkFrame(t, rdb)
+
+			if got := rdb[len(rdb)-9-len(tt.want) : len(rdb)-9]; !bytes.Equal(got, tt.want) {
+				t.Errorf("the key is encoded as % x, want % x", got, tt.want)
+			}
+		})
+	}
+}
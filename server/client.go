@@ -0,0 +1,53 @@
+package server
+
+import (
+	"time"
+
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/parser"
+)
+
+// client holds the per-connection state of a client served by the event loop.
+type client struct {
+	fd int // file descriptor of the client connection
+	// readBuf accumulates the bytes read from the connection across readiness events until commandParser
+	// consumes them. The bytes of an element that is not complete yet are kept for the next event.
+	readBuf []byte
+	// commandParser keeps the progress in the command being read between readiness events
+	commandParser *parser.CommandParser
+	// writeBuf holds the replies that could not be written yet because the socket was full
+	writeBuf []byte
+	// writeInterest reports whether the client is subscribed to write events to drain writeBuf
+	writeInterest bool
+	// softLimitReachedAt is the time writeBuf first went above the soft output buffer limit
+	softLimitReachedAt time.Time
+}
+
+func newClient(fd int) *client {
+	return &client{
+		fd:            fd,
+		commandParser: parser.NewCommandParser(),
+	}
+}
+
+// consume drops the first n bytes of the read buffer once they have been dispatched.
+func (c *client) consume(n int) {
+	remaining := copy(c.readBuf, c.readBuf[n:])
+	c.readBuf = c.readBuf[:remaining]
+}
+
+// exceedsOutputBufferLimit reports whether the pending replies of the client are over the given limit.
+func (c *client) exceedsOutputBufferLimit(limit OutputBufferLimit, now time.Time) bool {
+	pending := len(c.writeBuf)
+	if limit.Hard > 0 && pending > limit.Hard {
+		return true
+	}
+
+	if limit.Soft <= 0 || pending <= limit.Soft {
+		c.softLimitReachedAt = time.Time{}
+		return false
+	}
+	if c.softLimitReachedAt.IsZero() {
+		c.softLimitReachedAt = now
+	}
+	return now.Sub(c.softLimitReachedAt) > limit.SoftSeconds
+}
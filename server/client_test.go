@@ -0,0 +1,147 @@
+package server
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/iomultiplexer"
+)
+
+// failingMultiplexer is an IOMultiplexer whose subscriptions cannot be changed.
+type failingMultiplexer struct{}
+
+func (failingMultiplexer) Subscribe(event iomultiplexer.Event) error {
+	return errors.New("subscribe failed")
+}
+
+func (failingMultiplexer) Unsubscribe(event iomultiplexer.Event) error {
+	return errors.New("unsubscribe failed")
+}
+
+func (failingMultiplexer) Modify(event iomultiplexer.Event) error {
+	return errors.New("modify failed")
+}
+
+func (failingMultiplexer) Poll(timeout time.Duration) ([]iomultiplexer.Event, error) {
+	return nil, nil
+}
+
+func (failingMultiplexer) Close() error {
+	return nil
+}
+
+// newTestClient connects a client of s to a socket that nobody reads, so its replies pile up once the
+// socket buffer is full.
+func newTestClient(t *testing.T, s *server) *client {
+	t.Helper()
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatalf("socketpair: %v", err)
+	}
+	t.Cleanup(func() {
+		syscall.Close(fds[1])
+	})
+	if err := syscall.SetNonblock(fds[0], true); err != nil {
+		t.Fatalf("set nonblock: %v", err)
+	}
+
+	c := newClient(fds[0])
+	s.clients[c.fd] = c
+	return c
+}
+
+func TestExceedsOutputBufferLimit(t *testing.T) {
+	limit := OutputBufferLimit{Hard: 100, Soft: 10, SoftSeconds: time.Second}
+	now := time.Now()
+	c := newClient(-1)
+
+	c.writeBuf = make([]byte, 10)
+	if c.exceedsOutputBufferLimit(limit, now) {
+		t.Errorf("a buffer at the soft limit exceeds it")
+	}
+
+	c.writeBuf = make([]byte, 11)
+	if c.exceedsOutputBufferLimit(limit, now) {
+		t.Errorf("a buffer just over the soft limit is disconnected right away")
+	}
+	if c.exceedsOutputBufferLimit(limit, now.Add(time.Second)) {
+		t.Errorf("a buffer over the soft limit for exactly SoftSeconds exceeds it")
+	}
+	if !c.exceedsOutputBufferLimit(limit, now.Add(2*time.Second)) {
+		t.Errorf("a buffer over the soft limit for longer than SoftSeconds does not exceed it")
+	}
+
+	// Going back under the soft limit restarts the timer
+	c.writeBuf = make([]byte, 5)
+	c.exceedsOutputBufferLimit(limit, now.Add(2*time.Second))
+	c.writeBuf = make([]byte, 11)
+	if c.exceedsOutputBufferLimit(limit, now.Add(3*time.Second)) {
+		t.Errorf("the soft limit timer was not reset")
+	}
+
+	c.writeBuf = make([]byte, 101)
+	if !c.exceedsOutputBufferLimit(limit, now) {
+		t.Errorf("a buffer over the hard limit does not exceed it")
+	}
+	if (&client{writeBuf: make([]byte, 1<<20)}).exceedsOutputBufferLimit(OutputBufferLimit{}, now) {
+		t.Errorf("a zero limit is enforced")
+	}
+}
+
+func TestFlushClient(t *testing.T) {
+	t.Run("closes a client over the hard limit", func(t *testing.T) {
+		s := NewServer("127.0.0.1", 0, 1, WithClientOutputBufferLimit(OutputBufferLimit{Hard: 1024}))
+		s.multiplexer = failingMultiplexer{}
+		c := newTestClient(t, s)
+
+		c.writeBuf = make([]byte, 16<<20)
+		if err := s.flushClient(c); err != nil {
+			t.Fatalf("flushClient: %v", err)
+		}
+		if _, ok := s.clients[c.fd]; ok {
+			t.Errorf("a client over the hard limit is still connected")
+		}
+	})
+
+	t.Run("closes a client whose subscription cannot be modified", func(t *testing.T) {
+		s := NewServer("127.0.0.1", 0, 1)
+		s.multiplexer = failingMultiplexer{}
+		c := newTestClient(t, s)
+
+		c.writeBuf = make([]byte, 16<<20)
+		if err := s.flushClient(c); err == nil {
+			t.Fatalf("flushClient did not report the failed Modify")
+		}
+		if _, ok := s.clients[c.fd]; ok {
+			t.Errorf("a client that cannot be watched for write events is still connected")
+		}
+	})
+
+	t.Run("keeps the replies the socket cannot take yet", func(t *testing.T) {
+		s := NewServer("127.0.0.1", 0, 1)
+		multiplexer, err := iomultiplexer.New(1)
+		if err != nil {
+			t.Fatalf("iomultiplexer.New: %v", err)
+		}
+		defer multiplexer.Close()
+		s.multiplexer = multiplexer
+		c := newTestClient(t, s)
+		defer syscall.Close(c.fd)
+		if err := multiplexer.Subscribe(iomultiplexer.Event{Fd: c.fd, Op: iomultiplexer.OpRead}); err != nil {
+			t.Fatalf("Subscribe: %v", err)
+		}
+
+		c.writeBuf = make([]byte, 16<<20)
+		if err := s.flushClient(c); err != nil {
+			t.Fatalf("flushClient: %v", err)
+		}
+		if len(c.writeBuf) == 0 || !c.writeInterest {
+			t.Errorf("pending replies = %d, write interest = %v, want replies waiting for write events", len(c.writeBuf), c.writeInterest)
+		}
+		if _, ok := s.clients[c.fd]; !ok {
+			t.Errorf("a client without a limit was disconnected")
+		}
+	})
+}
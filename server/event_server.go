@@ -1,38 +1,61 @@
 package server
 
 import (
-	"bytes"
 	"errors"
 	"log"
 	"net"
+	"os"
+	"os/signal"
 	"syscall"
 	"time"
 
-	"github.com/Himanshu-Negi8/build-your-own-redis-server/handler"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/config"
 	"github.com/Himanshu-Negi8/build-your-own-redis-server/iomultiplexer"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/keyspace"
 	"github.com/Himanshu-Negi8/build-your-own-redis-server/parser"
-	"github.com/Himanshu-Negi8/build-your-own-redis-server/types"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/persistence"
 )
 
+// cronInterval is how often the event loop runs its periodic tasks, such as the active expire cycle.
+// It is also the timeout of each poll, so the tasks run even when no client is active.
+const cronInterval = 100 * time.Millisecond
+
 type server struct {
 	serverFD    int    // file descriptor of the server
 	host        string // ip address only
 	port        int    // port number only
 	maxClients  int    // maximum number of clients that can connect to the server
 	multiplexer iomultiplexer.IOMultiplexer
-	cache       map[string]types.CustomValue
+	cache       keyspace.Keyspace
+	clients     map[int]*client // state of the connected clients keyed by their file descriptor
+	// outputBufferLimit is the limit on the replies buffered for a slow client before it is disconnected
+	outputBufferLimit OutputBufferLimit
+	// lastCron is the time the periodic tasks last ran
+	lastCron time.Time
+	// shutdown receives the signals that stop the server
+	shutdown chan os.Signal
 }
 
-func NewServer(host string, port, maxClients int) *server {
-	return &server{
+func NewServer(host string, port, maxClients int, opts ...Option) *server {
+	s := &server{
 		host:       host,
 		port:       port,
 		maxClients: maxClients,
-		cache:      make(map[string]types.CustomValue),
+		cache:      keyspace.New(),
+		clients:    make(map[int]*client),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
 func (s *server) RunAsyncServer() error {
+	// The keyspace is loaded before the socket is created, so no client sees it partially loaded
+	if err := loadData(s.cache); err != nil {
+		return err
+	}
+
 	serverFD, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM, 0)
 	if err != nil {
 		// handle the error
@@ -87,13 +110,26 @@ func (s *server) RunAsyncServer() error {
 		return err
 	}
 
+	s.shutdown = make(chan os.Signal, 1)
+	signal.Notify(s.shutdown, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(s.shutdown)
+
 	err = s.eventLoop()
 	return err
 }
 
+// eventLoop serves the clients until the server receives SIGINT or SIGTERM, and then flushes the append-only file
+// to disk before returning.
 func (s *server) eventLoop() error {
 	for {
-		events, err := s.multiplexer.Poll(time.Millisecond * 100)
+		select {
+		case sig := <-s.shutdown:
+			log.Printf("received %v, shutting down", sig)
+			return persistence.CloseAOF()
+		default:
+		}
+
+		events, err := s.multiplexer.Poll(cronInterval)
 		if err != nil {
 			if errors.Is(err, syscall.EINTR) {
 				continue
@@ -107,18 +143,35 @@ func (s *server) eventLoop() error {
 					log.Println("failed to accept client connection", "error", err)
 				}
 			} else {
-				err := s.handleClientEvent(event)
-				if err != nil {
-					log.Println("failed to handle client event", "error", err)
+				if event.Op&iomultiplexer.OpWrite != 0 {
+					if err := s.handleClientWritable(event); err != nil {
+						log.Println("failed to write to client", "error", err)
+					}
+				}
+				if event.Op&iomultiplexer.OpRead != 0 {
+					if err := s.handleClientEvent(event); err != nil {
+						log.Println("failed to handle client event", "error", err)
+					}
 				}
-
 			}
 		}
 
+		if time.Since(s.lastCron) >= cronInterval {
+			s.serverCron()
+			s.lastCron = time.Now()
+		}
 	}
 
 }
 
+// serverCron runs the periodic tasks of the event loop between two polls.
+func (s *server) serverCron() {
+	// Reclaim the memory of expired keys that are not accessed anymore
+	keyspace.ActiveExpireCycle(s.cache, keyspace.ExpireCycleTimeLimit)
+	// Start a background save when a save point is reached
+	persistence.AutoSave(config.RDBPath(), s.cache)
+}
+
 // acceptClientConnection accepts a new client connection and subscribes to read events on the connection.
 func (s *server) acceptClientConnection() error {
 	fd, _, err := syscall.Accept(s.serverFD)
@@ -127,17 +180,33 @@ func (s *server) acceptClientConnection() error {
 	}
 
 	if err := syscall.SetNonblock(fd, true); err != nil {
+		syscall.Close(fd)
 		return err
 	}
 
-	return s.multiplexer.Subscribe(iomultiplexer.Event{
+	if err := s.multiplexer.Subscribe(iomultiplexer.Event{
 		Fd: fd,
 		Op: iomultiplexer.OpRead,
-	})
+	}); err != nil {
+		syscall.Close(fd)
+		return err
+	}
+
+	s.clients[fd] = newClient(fd)
+	return nil
 }
 
 // handleClientEvent reads commands from the client connection and responds to the client. It also handles disconnections.
+// The bytes read are accumulated in the client's read buffer, and commands are only dispatched once complete
+// RESP frames are available. Every complete frame is executed in order, which supports pipelining, and the
+// replies are queued in the client's output buffer and flushed in a single write.
 func (s *server) handleClientEvent(event iomultiplexer.Event) error {
+	c, ok := s.clients[event.Fd]
+	if !ok {
+		// The client was disconnected while handling an earlier event of the same poll
+		return nil
+	}
+
 	// Read from the file descriptor
 	buf := make([]byte, 4096)
 	n, err := syscall.Read(event.Fd, buf)
@@ -146,25 +215,132 @@ func (s *server) handleClientEvent(event iomultiplexer.Event) error {
 			// No data available, return nil
 			return nil
 		}
-		// Handle other read errors
+		// Handle other read errors by dropping the connection
+		s.closeClient(c)
 		return err
 	}
+	if n == 0 {
+		// The client closed the connection
+		s.closeClient(c)
+		return nil
+	}
+	c.readBuf = append(c.readBuf, buf[:n]...)
 
-	r := bytes.NewReader(buf[:n])
-	result, respType, err := parser.Parse(r)
-	if err != nil {
-		log.Printf("Error parsing RESP: %v", err)
-		response := []byte("-ERR invalid command\r\n")
-		syscall.Write(event.Fd, response)
-		return err
+	frames, consumed, parseErr := c.commandParser.Parse(c.readBuf)
+	c.consume(consumed)
+
+	response := handleFrames(frames, s.cache)
+	if parseErr != nil {
+		log.Printf("Error parsing RESP: %v", parseErr)
+		// The buffer cannot be resynchronised after a protocol error, so drop what was read
+		c.readBuf = c.readBuf[:0]
+		response = append(response, parseErrorReply(parseErr)...)
+	}
+	if len(response) == 0 {
+		// Wait for the rest of the frame
+		return parseErr
 	}
 
-	// Process the command based on its type
-	response := handler.HandleCommands(result, respType, s.cache)
-	_, err = syscall.Write(event.Fd, response)
-	if err != nil {
+	c.writeBuf = append(c.writeBuf, response...)
+	if err := s.flushClient(c); err != nil {
 		return err
 	}
 
+	var protoErr *parser.ProtocolError
+	if errors.As(parseErr, &protoErr) {
+		// Whatever follows a protocol error, such as an oversized length, cannot be told apart from commands,
+		// so like Redis the connection is closed after the error reply
+		if s.clients[c.fd] == c {
+			s.closeClient(c)
+		}
+	}
+
+	return parseErr
+}
+
+// handleClientWritable resumes writing the pending replies of a client once its socket has room again.
+func (s *server) handleClientWritable(event iomultiplexer.Event) error {
+	c, ok := s.clients[event.Fd]
+	if !ok {
+		// The client was disconnected while handling an earlier event of the same poll
+		return nil
+	}
+
+	return s.flushClient(c)
+}
+
+// flushClient writes as much of the client's output buffer as the socket accepts.
+// When the socket is full, write events are added to the client's subscription so the rest is written once
+// it drains, and they are removed again once the buffer is empty. A client whose pending replies go over the
+// output buffer limit, whose connection fails, or whose subscription cannot be changed is disconnected.
+func (s *server) flushClient(c *client) error {
+	for len(c.writeBuf) > 0 {
+		n, err := syscall.Write(c.fd, c.writeBuf)
+		if err != nil {
+			if errors.Is(err, syscall.EAGAIN) {
+				break
+			}
+			s.closeClient(c)
+			return err
+		}
+		c.writeBuf = c.writeBuf[n:]
+	}
+
+	if len(c.writeBuf) == 0 {
+		// Release the memory of the replies that were written
+		c.writeBuf = nil
+		c.softLimitReachedAt = time.Time{}
+		if c.writeInterest {
+			if err := s.multiplexer.Modify(iomultiplexer.Event{
+				Fd: c.fd,
+				Op: iomultiplexer.OpRead,
+			}); err != nil {
+				// A client whose events cannot be watched would never be served again
+				s.closeClient(c)
+				return err
+			}
+			c.writeInterest = false
+		}
+		return nil
+	}
+
+	if c.exceedsOutputBufferLimit(s.outputBufferLimit, time.Now()) {
+		log.Println("closing client that exceeded the output buffer limit", "fd", c.fd, "pending", len(c.writeBuf))
+		s.closeClient(c)
+		return nil
+	}
+
+	if !c.writeInterest {
+		if err := s.multiplexer.Modify(iomultiplexer.Event{
+			Fd: c.fd,
+			Op: iomultiplexer.OpRead | iomultiplexer.OpWrite,
+		}); err != nil {
+			s.closeClient(c)
+			return err
+		}
+		c.writeInterest = true
+	}
+
 	return nil
 }
+
+// closeClient unsubscribes the client connection from the multiplexer, closes it and drops its state.
+// Any pending replies are discarded.
+func (s *server) closeClient(c *client) {
+	delete(s.clients, c.fd)
+
+	op := iomultiplexer.OpRead
+	if c.writeInterest {
+		op |= iomultiplexer.OpWrite
+	}
+	if err := s.multiplexer.Unsubscribe(iomultiplexer.Event{
+		Fd: c.fd,
+		Op: op,
+	}); err != nil {
+		log.Println("failed to unsubscribe client", "error", err)
+	}
+
+	if err := syscall.Close(c.fd); err != nil {
+		log.Println("failed to close client connection", "error", err)
+	}
+}
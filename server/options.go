@@ -0,0 +1,83 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Option configures optional settings of the event loop server.
+type Option func(*server)
+
+// OutputBufferLimit is the client-output-buffer-limit of the event loop server.
+// A client is disconnected as soon as its pending replies exceed Hard bytes, or when they stay
+// above Soft bytes for longer than SoftSeconds. A zero value disables the corresponding limit.
+type OutputBufferLimit struct {
+	Hard        int
+	Soft        int
+	SoftSeconds time.Duration
+}
+
+// WithClientOutputBufferLimit sets the limit on the replies buffered for a client
+// that does not read them fast enough.
+func WithClientOutputBufferLimit(limit OutputBufferLimit) Option {
+	return func(s *server) {
+		s.outputBufferLimit = limit
+	}
+}
+
+// ParseOutputBufferLimit parses a client-output-buffer-limit in the format of redis.conf: the hard limit, the soft
+// limit and the seconds the soft limit may be exceeded for, separated by spaces, like "256mb 64mb 60". The limits
+// accept the k, kb, m, mb, g and gb units, where k is 1000 bytes and kb is 1024 bytes.
+func ParseOutputBufferLimit(s string) (OutputBufferLimit, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 3 {
+		return OutputBufferLimit{}, fmt.Errorf("invalid client output buffer limit %q: want <hard> <soft> <soft seconds>", s)
+	}
+
+	hard, err := parseMemory(fields[0])
+	if err != nil {
+		return OutputBufferLimit{}, fmt.Errorf("invalid client output buffer hard limit: %w", err)
+	}
+	soft, err := parseMemory(fields[1])
+	if err != nil {
+		return OutputBufferLimit{}, fmt.Errorf("invalid client output buffer soft limit: %w", err)
+	}
+	seconds, err := strconv.Atoi(fields[2])
+	if err != nil || seconds < 0 {
+		return OutputBufferLimit{}, fmt.Errorf("invalid client output buffer soft seconds %q", fields[2])
+	}
+
+	return OutputBufferLimit{Hard: hard, Soft: soft, SoftSeconds: time.Duration(seconds) * time.Second}, nil
+}
+
+// memoryUnits are the units of a memory size in redis.conf.
+var memoryUnits = []struct {
+	suffix     string
+	multiplier int
+}{
+	// The two letter units are matched first, so that "kb" is not read as a "k" size
+	{"kb", 1 << 10}, {"mb", 1 << 20}, {"gb", 1 << 30},
+	{"k", 1000}, {"m", 1000 * 1000}, {"g", 1000 * 1000 * 1000},
+	{"b", 1},
+}
+
+// parseMemory parses a memory size with an optional unit, like "64mb".
+func parseMemory(s string) (int, error) {
+	lower := strings.ToLower(s)
+	multiplier := 1
+	for _, unit := range memoryUnits {
+		if number, ok := strings.CutSuffix(lower, unit.suffix); ok {
+			lower, multiplier = number, unit.multiplier
+			break
+		}
+	}
+
+	n, err := strconv.Atoi(lower)
+	if err != nil || n < 0 || n > math.MaxInt/multiplier {
+		return 0, fmt.Errorf("invalid memory size %q", s)
+	}
+	return n * multiplier, nil
+}
@@ -0,0 +1,71 @@
+package server_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/server"
+)
+
+func TestParseOutputBufferLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected server.OutputBufferLimit
+		wantErr  bool
+	}{
+		{
+			name:     "Disabled",
+			input:    "0 0 0",
+			expected: server.OutputBufferLimit{},
+		},
+		{
+			name:     "Binary units",
+			input:    "256mb 64MB 60",
+			expected: server.OutputBufferLimit{Hard: 256 << 20, Soft: 64 << 20, SoftSeconds: 60 * time.Second},
+		},
+		{
+			name:     "Decimal units and bytes",
+			input:    "1k 512b 5",
+			expected: server.OutputBufferLimit{Hard: 1000, Soft: 512, SoftSeconds: 5 * time.Second},
+		},
+		{
+			name:    "Missing soft seconds",
+			input:   "256mb 64mb",
+			wantErr: true,
+		},
+		{
+			name:    "Unknown unit",
+			input:   "1x 0 0",
+			wantErr: true,
+		},
+		{
+			name:    "Negative limit",
+			input:   "-1 0 0",
+			wantErr: true,
+		},
+		{
+			name:    "Overflowing limit",
+			input:   "9223372036854775807gb 0 0",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit, err := server.ParseOutputBufferLimit(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", limit)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if limit != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, limit)
+			}
+		})
+	}
+}
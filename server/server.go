@@ -1,23 +1,32 @@
 package server
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net"
 	"os"
+	"os/signal"
 	"sync"
+	"syscall"
+	"time"
 
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/config"
 	"github.com/Himanshu-Negi8/build-your-own-redis-server/handler"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/keyspace"
 	"github.com/Himanshu-Negi8/build-your-own-redis-server/parser"
-	"github.com/Himanshu-Negi8/build-your-own-redis-server/types"
+	"github.com/Himanshu-Negi8/build-your-own-redis-server/persistence"
 )
 
 const (
 	acceptors = 10
 	workers   = 10
+	// activeExpireInterval is how often expired keys that are not accessed anymore are reclaimed
+	activeExpireInterval = 100 * time.Millisecond
+	// autoSaveInterval is how often the save points are checked
+	autoSaveInterval = 100 * time.Millisecond
 )
 
 func RunServer() {
@@ -28,10 +37,18 @@ func RunServer() {
 	}
 	defer l.Close()
 
-	cache := make(map[string]types.CustomValue)
+	cache := keyspace.New()
+	if err := loadData(cache); err != nil {
+		log.Fatalf("failed to load the data: %v", err)
+	}
 	connCh := make(chan net.Conn, 1000)
-	ctx, cancel := context.WithCancel(context.Background())
+	// The server stops on SIGINT or SIGTERM. Closing the listener wakes up the acceptors waiting for connections
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
 
 	wg := &sync.WaitGroup{}
 
@@ -44,8 +61,66 @@ func RunServer() {
 		go worker(ctx, connCh, cache)
 	}
 
+	go activeExpire(ctx, cache)
+	go autoSave(ctx, cache)
+
 	wg.Wait()
+	if err := persistence.CloseAOF(); err != nil {
+		log.Printf("failed to close the append-only file: %v", err)
+	}
+}
+
+// loadData loads the keyspace persisted by a previous run of the server, from the append-only file when it is
+// enabled or else from the RDB file.
+func loadData(cache keyspace.Keyspace) error {
+	if !config.AppendOnly {
+		return loadSnapshot(cache)
+	}
+	return openAppendOnly(cache)
+}
+
+// openAppendOnly replays the append-only file into the keyspace, or loads the RDB file when there is no
+// append-only file yet, and then logs the writes to the append-only file.
+func openAppendOnly(cache keyspace.Keyspace) error {
+	policy, err := persistence.ParseFsyncPolicy(config.AppendFsync)
+	if err != nil {
+		return err
+	}
+
+	path := config.AOFPath()
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		if err := loadSnapshot(cache); err != nil {
+			return err
+		}
+	} else {
+		replayed, err := persistence.LoadAOF(path, cache, func(args []string) error {
+			return handler.Replay(args, cache)
+		})
+		if err != nil {
+			return fmt.Errorf("loading %s: %w", path, err)
+		}
+		log.Printf("replayed %d commands from %s", replayed, path)
+	}
 
+	aof, err := persistence.OpenAOF(path, policy, cache)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", path, err)
+	}
+	persistence.EnableAOF(aof)
+	return nil
+}
+
+// loadSnapshot loads the RDB file written by SAVE into the keyspace, so that the data survives restarts.
+func loadSnapshot(cache keyspace.Keyspace) error {
+	path := config.RDBPath()
+	loaded, err := persistence.Load(path, cache)
+	if err != nil {
+		return fmt.Errorf("loading %s: %w", path, err)
+	}
+	if loaded > 0 {
+		log.Printf("loaded %d keys from %s", loaded, path)
+	}
+	return nil
 }
 
 func acceptor(ctx context.Context, listener net.Listener, connCh chan net.Conn, wg *sync.WaitGroup) {
@@ -65,7 +140,37 @@ func acceptor(ctx context.Context, listener net.Listener, connCh chan net.Conn,
 	}
 }
 
-func worker(ctx context.Context, connCh chan net.Conn, cache map[string]types.CustomValue) {
+// activeExpire runs the active expire cycle in the background until the context is cancelled.
+func activeExpire(ctx context.Context, cache keyspace.Keyspace) {
+	ticker := time.NewTicker(activeExpireInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			keyspace.ActiveExpireCycle(cache, keyspace.ExpireCycleTimeLimit)
+		}
+	}
+}
+
+// autoSave starts a background save whenever a save point is reached, until the context is cancelled.
+func autoSave(ctx context.Context, cache keyspace.Keyspace) {
+	ticker := time.NewTicker(autoSaveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			persistence.AutoSave(config.RDBPath(), cache)
+		}
+	}
+}
+
+func worker(ctx context.Context, connCh chan net.Conn, cache keyspace.Keyspace) {
 	for {
 		select {
 		case <-ctx.Done():
@@ -78,17 +183,20 @@ func worker(ctx context.Context, connCh chan net.Conn, cache map[string]types.Cu
 	}
 }
 
-func handleConnectionRequest(ctx context.Context, conn net.Conn, cache map[string]types.CustomValue) {
+func handleConnectionRequest(ctx context.Context, conn net.Conn, cache keyspace.Keyspace) {
 	defer conn.Close()
 
+	// pending accumulates the bytes read from the connection until the command parser consumes them
+	var pending []byte
+	commandParser := parser.NewCommandParser()
+	buf := make([]byte, 8196)
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		default:
-			buf := make([]byte, 8196)
 			// Read the client's input
-			_, err := conn.Read(buf)
+			n, err := conn.Read(buf)
 			if err != nil {
 				if errors.Is(err, io.EOF) {
 					// Client closed the connection
@@ -98,21 +206,44 @@ func handleConnectionRequest(ctx context.Context, conn net.Conn, cache map[strin
 				fmt.Println("Error reading from connection:", err)
 				return
 			}
-
-			// Parse the RESP command
-			r := bytes.NewReader(buf)
-			result, respType, err := parser.Parse(r)
-			fmt.Println(respType)
-			if err != nil {
-				fmt.Println("Error parsing RESP:", err)
-				conn.Write([]byte("-ERR invalid command\r\n"))
-				continue
+			pending = append(pending, buf[:n]...)
+
+			// Parse every complete RESP command, so that pipelined commands are all executed
+			frames, consumed, parseErr := commandParser.Parse(pending)
+			pending = pending[consumed:]
+
+			// Process the commands and write all the replies back at once
+			response := handleFrames(frames, cache)
+			if parseErr != nil {
+				fmt.Println("Error parsing RESP:", parseErr)
+				pending = nil
+				response = append(response, parseErrorReply(parseErr)...)
+			}
+			if len(response) > 0 {
+				conn.Write(response)
+			}
+			var protoErr *parser.ProtocolError
+			if errors.As(parseErr, &protoErr) {
+				return
 			}
-
-			// Process the command based on its type
-			response := handler.HandleCommands(result, respType, cache)
-			fmt.Println(string(response))
-			conn.Write(response)
 		}
 	}
 }
+
+// parseErrorReply returns the error reply sent to a client whose input could not be parsed.
+func parseErrorReply(err error) []byte {
+	var protoErr *parser.ProtocolError
+	if errors.As(err, &protoErr) {
+		return []byte("-ERR " + protoErr.Error() + "\r\n")
+	}
+	return []byte("-ERR invalid command\r\n")
+}
+
+// handleFrames executes the parsed commands in order and returns their replies concatenated.
+func handleFrames(frames []parser.Frame, cache keyspace.Keyspace) []byte {
+	var response []byte
+	for _, frame := range frames {
+		response = append(response, handler.HandleCommands(frame.Value, frame.Type, cache)...)
+	}
+	return response
+}
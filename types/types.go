@@ -1,5 +1,7 @@
 package types
 
+import "github.com/Himanshu-Negi8/build-your-own-redis-server/datatypes"
+
 type RESPType string
 
 const (
@@ -10,7 +12,69 @@ const (
 	RESPTypeArray        RESPType = "RESPTypeArray"
 )
 
+// ValueType is the Redis type of a value stored in the keyspace.
+type ValueType uint8
+
+const (
+	// ValueTypeString is the zero ValueType, so a CustomValue holds a string unless told otherwise.
+	ValueTypeString ValueType = iota
+	ValueTypeList
+	ValueTypeHash
+	ValueTypeZSet
+	ValueTypeSet
+)
+
+// String returns the name of the type, as replied by TYPE.
+func (t ValueType) String() string {
+	switch t {
+	case ValueTypeList:
+		return "list"
+	case ValueTypeHash:
+		return "hash"
+	case ValueTypeZSet:
+		return "zset"
+	case ValueTypeSet:
+		return "set"
+	default:
+		return "string"
+	}
+}
+
+// CustomValue is a value stored in the keyspace. Type selects which of Value, List, Hash, ZSet and Set
+// holds the data.
 type CustomValue struct {
+	Type            ValueType
 	Value           string
+	List            *datatypes.List
+	Hash            *datatypes.Hash
+	ZSet            *datatypes.SortedSet
+	Set             *datatypes.Set
 	ValueExpiration int64
 }
+
+// Clone returns a copy of the value that shares no mutable state with it.
+func (v CustomValue) Clone() CustomValue {
+	if v.List != nil {
+		v.List = v.List.Clone()
+	}
+	if v.Hash != nil {
+		v.Hash = v.Hash.Clone()
+	}
+	if v.ZSet != nil {
+		v.ZSet = v.ZSet.Clone()
+	}
+	if v.Set != nil {
+		v.Set = v.Set.Clone()
+	}
+	return v
+}
+
+// HasExpiration reports whether the value has an expiration time set.
+func (v CustomValue) HasExpiration() bool {
+	return v.ValueExpiration > 0
+}
+
+// IsExpired reports whether the expiration time of the value, if any, is not after now, in unix milliseconds.
+func (v CustomValue) IsExpired(now int64) bool {
+	return v.HasExpiration() && v.ValueExpiration <= now
+}